@@ -0,0 +1,78 @@
+package httpsign
+
+import (
+	"net/http"
+	"strings"
+)
+
+const acceptSignatureHeader = "Accept-Signature"
+
+// AcceptSignature is a signature the client asks for in the Accept-Signature
+// header of RFC 9421 section 5.1. KeyID and Algorithm are empty when the
+// client leaves them to the signer.
+type AcceptSignature struct {
+	// Label is the label of the requested signature
+	Label string
+	// KeyID is the keyid parameter
+	KeyID KeyID
+	// Algorithm is the alg parameter, translated to the name of the crypto
+	// package algorithm, e.g. rsa-sha256 for rsa-v1_5-sha256
+	Algorithm string
+	// Components are the components the client asks to be covered
+	Components []string
+}
+
+// ParseAcceptSignature parses the Accept-Signature header of the request, in
+// the order of preference of the client. It returns no signature and no
+// error when the header is missing.
+func ParseAcceptSignature(r *http.Request) ([]AcceptSignature, error) {
+	input := strings.Join(r.Header.Values(acceptSignatureHeader), ", ")
+	if input == "" {
+		return nil, nil
+	}
+	members, err := parseDictionary(input)
+	if err != nil {
+		return nil, ErrInvalidAcceptSignature
+	}
+
+	accepted := make([]AcceptSignature, 0, len(members))
+	for _, m := range members {
+		if !m.isList {
+			return nil, ErrInvalidAcceptSignature
+		}
+		a := AcceptSignature{Label: m.key}
+		for _, item := range m.list {
+			component, ok := item.value.(string)
+			if !ok {
+				return nil, ErrInvalidAcceptSignature
+			}
+			a.Components = append(a.Components, component)
+		}
+		for _, p := range m.params {
+			value, ok := p.value.(string)
+			switch p.key {
+			case rfc9421KeyID:
+				if !ok {
+					return nil, ErrInvalidAcceptSignature
+				}
+				a.KeyID = KeyID(value)
+			case rfc9421Alg:
+				if !ok {
+					return nil, ErrInvalidAcceptSignature
+				}
+				if name, ok := rfc9421Algorithms[value]; ok {
+					value = name
+				}
+				a.Algorithm = value
+			}
+		}
+		accepted = append(accepted, a)
+	}
+	return accepted, nil
+}
+
+// accepts reports whether the client accepts the signatures of s
+func (a *AcceptSignature) accepts(s *Signer) bool {
+	return (a.KeyID == "" || a.KeyID == s.keyID) &&
+		(a.Algorithm == "" || a.Algorithm == s.secret.Algorithm.Name())
+}
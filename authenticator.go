@@ -2,14 +2,20 @@ package httpsign
 
 import (
 	"bytes"
-	"encoding/base64"
-	"fmt"
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
 	"net/http"
+	"net/url"
+	"regexp"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/gin-gonic/gin"
 
+	"github.com/stremovskyy/httpsign/crypto"
 	"github.com/stremovskyy/httpsign/validator"
 )
 
@@ -17,27 +23,132 @@ const (
 	requestTarget = "(request-target)"
 	date          = "date"
 	digest        = "digest"
+	contentDigest = "content-digest"
 	host          = "host"
+	created       = "(created)"
+	expires       = "(expires)"
+
+	queryParamPrefix = "(query-param:"
+	trailerPrefix    = "(trailer:"
+
+	forwardedProtoHeader = "X-Forwarded-Proto"
 )
 
 var defaultRequiredHeaders = []string{requestTarget, date, digest}
 
+// defaultMaxCoveredHeaders is the default max number of headers a signature
+// could cover
+const defaultMaxCoveredHeaders = 64
+
 // Authenticator is the gin authenticator middleware.
 type Authenticator struct {
-	secrets    Secrets
+	secrets    SecretProvider
 	validators []validator.Validator
 	headers    []string
 	debug      bool
+	logger     Logger
+	metrics    MetricsHooks
+	strictAlgo bool
+	format     SignatureFormat
+	encoding   SignatureEncoding
+	// debugFormat is the format of the messages, see WithDebugFormat
+	debugFormat DebugFormat
+	// canonicalizer builds the signing strings, the one of format by default
+	canonicalizer Canonicalizer
+	// statusMapper maps the failure reasons to HTTP status codes
+	statusMapper func(FailureReason) int
+	// preferAuthorization reads the signature from the Authorization header
+	// before the Signature header
+	preferAuthorization bool
+	// digestForBodyOnly only requires the digest for requests with a body
+	digestForBodyOnly bool
+	// requireDigestForBody requires the requests with a body to cover the
+	// digest
+	requireDigestForBody bool
+	// maxCoveredHeaders is the max number of headers covered by a signature
+	maxCoveredHeaders int
+	// strictHeaderValues signs the header values byte for byte
+	strictHeaderValues bool
+	// queryParams are the (query-param:name) fields required on top of headers
+	queryParams []string
+	// requestTarget resolves the request target signed by the client
+	requestTarget func(*http.Request) string
+	// limiter limits the verification failures
+	limiter FailureLimiter
+	// mandatoryHeaders must be covered whatever the required headers
+	mandatoryHeaders []string
+	// ifPresentHeaders must be covered when the request has them, see
+	// WithHeadersRequiredIfPresent
+	ifPresentHeaders []string
+	// skippers select the requests the middlewares do not verify
+	skippers []func(*http.Request) bool
+	// digestHeader is the lowercased name of the digest header, see
+	// validator.NewDigestValidatorWithHeader
+	digestHeader string
+	// digestHeaders are the digest headers the signatures may cover, the
+	// digestHeader or both digest and content-digest, see
+	// validator.WithCoveredDigestHeader
+	digestHeaders []string
+	// maxBodySize is the MaxBodySize of the digest validator, the bodies
+	// read by the middlewares are capped to it
+	maxBodySize int64
+	// signaturePolicy selects the RFC 9421 signatures to verify
+	signaturePolicy SignaturePolicy
+	// requestTargetMode selects the parts of the URI in (request-target)
+	requestTargetMode RequestTargetMode
+	// methodCase is the case of the method in (request-target)
+	methodCase MethodCase
+	// requireTLS rejects the requests not received over TLS
+	requireTLS bool
+	// trustForwardedProto trusts X-Forwarded-Proto for requireTLS
+	trustForwardedProto bool
+	// ipAllowList restricts keyIDs to source IPs
+	ipAllowList IPAllowList
+	// trustForwardedFor trusts X-Forwarded-For for ipAllowList
+	trustForwardedFor bool
+	// keyIDResolver resolves the keyID from elsewhere than the signature
+	keyIDResolver func(r *http.Request) (KeyID, bool)
+	// allowedAlgorithms are the names of the algorithms accepted, all of
+	// them when nil
+	allowedAlgorithms map[string]struct{}
+	// beforeVerify are the hooks run by the gin middlewares before the
+	// verification
+	beforeVerify []func(c *gin.Context) error
 }
 
 // Option is the option to the Authenticator constructor.
 type Option func(*Authenticator)
 
+// WithSecretProvider configures the Authenticator to look the secrets up
+// with provider instead of the Secrets given to NewAuthenticator, e.g. from a
+// database. Secrets are looked up on each request, so revoked keys are
+// rejected at once.
+func WithSecretProvider(provider SecretProvider) Option {
+	return func(a *Authenticator) {
+		a.secrets = provider
+	}
+}
+
 // WithValidator configures the Authenticator to use custom validator.
-// The default validators are time based and digest.
+// The default validators are time based and digest. Calling it without
+// validators, or with a nil or empty slice, keeps the defaults; use
+// WithNoValidators to disable them.
 func WithValidator(validators ...validator.Validator) Option {
 	return func(a *Authenticator) {
-		a.validators = validators
+		if len(validators) == 0 {
+			a.validators = nil
+			return
+		}
+		a.validators = append([]validator.Validator(nil), validators...)
+	}
+}
+
+// WithNoValidators configures the Authenticator to use no validator at all,
+// not even the default date and digest ones, e.g. behind a gateway which
+// already validated them.
+func WithNoValidators() Option {
+	return func(a *Authenticator) {
+		a.validators = []validator.Validator{}
 	}
 }
 
@@ -50,16 +161,345 @@ func WithRequiredHeaders(headers []string) Option {
 	}
 }
 
+// WithSignatureFormat configures the format of the signatures accepted by the
+// Authenticator. The default is Cavage.
+func WithSignatureFormat(format SignatureFormat) Option {
+	return func(a *Authenticator) {
+		a.format = format
+	}
+}
+
+// WithAllowedAlgorithms configures the Authenticator to only accept the
+// signatures with the algorithms names, e.g. to forbid hmac-sha1 whatever
+// the secrets are configured with. The signatures of other algorithms, and
+// the secrets of other algorithms, are rejected with ErrAlgorithmNotAllowed.
+func WithAllowedAlgorithms(names ...string) Option {
+	return func(a *Authenticator) {
+		a.allowedAlgorithms = make(map[string]struct{}, len(names))
+		for _, name := range names {
+			a.allowedAlgorithms[strings.ToLower(name)] = struct{}{}
+		}
+	}
+}
+
+// WithKeyIDResolver configures the Authenticator to read the keyID with
+// resolver, e.g. from a X-Key-Id header, for the clients which could not set
+// it in the signature. The keyID of the signature is used when resolver
+// returns false. When both are present they must be equal, the request is
+// rejected with ErrKeyIDMismatch otherwise.
+func WithKeyIDResolver(resolver func(r *http.Request) (KeyID, bool)) Option {
+	return func(a *Authenticator) {
+		a.keyIDResolver = resolver
+	}
+}
+
+// WithCanonicalizer configures how the Authenticator builds the signing
+// strings, instead of the Canonicalizer of the signature format, see
+// WithSignatureFormat.
+func WithCanonicalizer(c Canonicalizer) Option {
+	return func(a *Authenticator) {
+		a.canonicalizer = c
+	}
+}
+
+// WithSignaturePolicy configures which of the RFC 9421 signatures of a
+// request are verified, e.g. AllSignatures when a gateway adds its own
+// signature to the one of the client. Each signature is verified with the
+// secret of its keyID. The default is FirstSignature.
+func WithSignaturePolicy(policy SignaturePolicy) Option {
+	return func(a *Authenticator) {
+		a.signaturePolicy = policy
+	}
+}
+
+// WithSignatureEncoding configures how the signature parameter of the Cavage
+// signatures is encoded. The default is Base64. It has no effect on RFC 9421
+// signatures.
+func WithSignatureEncoding(encoding SignatureEncoding) Option {
+	return func(a *Authenticator) {
+		a.encoding = encoding
+	}
+}
+
+// WithErrorStatusMapper configures the HTTP status code the middlewares respond
+// with for each FailureReason. By default every authentication failure is
+// answered with 401 Unauthorized, so clients could not tell which check failed,
+// and ReasonInternal with 500 Internal Server Error.
+func WithErrorStatusMapper(mapper func(FailureReason) int) Option {
+	return func(a *Authenticator) {
+		a.statusMapper = mapper
+	}
+}
+
+// WithPreferAuthorizationHeader configures the Authenticator to read the
+// signature from the Authorization header first when a request has both an
+// Authorization: Signature and a Signature header. By default the Signature
+// header is preferred, and Authorization is only used in its absence.
+func WithPreferAuthorizationHeader(prefer bool) Option {
+	return func(a *Authenticator) {
+		a.preferAuthorization = prefer
+	}
+}
+
+// WithDigestRequiredForBody configures the Authenticator to require the digest
+// header only for requests with a body, that is a non-zero Content-Length or a
+// chunked body. Bodiless requests, like most GET, are accepted without digest
+// and the DigestValidator is skipped for them, unless they have a Digest
+// header anyway.
+func WithDigestRequiredForBody(required bool) Option {
+	return func(a *Authenticator) {
+		a.digestForBodyOnly = required
+	}
+}
+
+// WithRequireDigestForBody configures the Authenticator to reject the requests
+// with a body whose signature does not cover the digest header, whatever the
+// required headers, so that the body could not be changed without breaking
+// the signature. The error wraps ErrHeaderNotEnough. The digest itself is
+// checked by the DigestValidator. See WithDigestRequiredForBody to not require
+// the digest of bodiless requests.
+func WithRequireDigestForBody(required bool) Option {
+	return func(a *Authenticator) {
+		a.requireDigestForBody = required
+	}
+}
+
+// WithMaxCoveredHeaders configures the max number of headers a signature
+// could cover, the signatures covering more are rejected with
+// ErrTooManyHeaders before any other check. The default is 64, n <= 0 removes
+// the limit.
+func WithMaxCoveredHeaders(n int) Option {
+	return func(a *Authenticator) {
+		a.maxCoveredHeaders = n
+	}
+}
+
+// WithStrictHeaderValues configures the Authenticator to use the header values
+// byte for byte in the signing string. By default the values are normalized:
+// the obsolete line folding is replaced by a space and the surrounding
+// whitespace is trimmed, so that intermediaries padding values do not break
+// signatures. The Signer must use the same mode, see WithSignerStrictHeaderValues.
+// RFC 9421 signatures are always normalized.
+func WithStrictHeaderValues(strict bool) Option {
+	return func(a *Authenticator) {
+		a.strictHeaderValues = strict
+	}
+}
+
+// WithRequiredQueryParams adds the given query parameters to the fields that
+// the client have to include in the signing string. See QueryParamHeader.
+func WithRequiredQueryParams(names ...string) Option {
+	return func(a *Authenticator) {
+		for _, name := range names {
+			a.queryParams = append(a.queryParams, QueryParamHeader(name))
+		}
+	}
+}
+
+// WithRequestTargetResolver configures the Authenticator to build the
+// request target of the signing string, the path and the query, with resolve
+// instead of r.URL.RequestURI(). Behind a reverse proxy rewriting the path,
+// resolve could rebuild the target signed by the client from the
+// X-Forwarded-* headers. An empty target falls back to r.URL.RequestURI(). It
+// applies to (request-target) and to the RFC 9421 @target-uri,
+// @request-target, @path and @query components.
+func WithRequestTargetResolver(resolve func(r *http.Request) string) Option {
+	return func(a *Authenticator) {
+		a.requestTarget = resolve
+	}
+}
+
+// RequestTargetMode selects the parts of the request URI signed as the
+// (request-target) field.
+type RequestTargetMode int
+
+const (
+	// PathAndQuery signs the path and the query. This is the default.
+	PathAndQuery RequestTargetMode = iota
+	// PathOnly signs the path without the query, e.g. for clients whose
+	// query parameters are reordered by caches. The query parameters are
+	// then not protected by the signature, unless covered with
+	// QueryParamHeader.
+	PathOnly
+)
+
+// WithRequestTargetMode configures the parts of the request URI in the
+// (request-target) field of the Cavage and JWS signatures. The Signer must
+// use the same mode, see WithSignerRequestTargetMode. The default is
+// PathAndQuery.
+func WithRequestTargetMode(mode RequestTargetMode) Option {
+	return func(a *Authenticator) {
+		a.requestTargetMode = mode
+	}
+}
+
+// MethodCase is the case of the method in the (request-target) field.
+type MethodCase int
+
+const (
+	// Lower lowercases the method, as specified by draft-cavage. This is the
+	// default.
+	Lower MethodCase = iota
+	// Preserve uses the method as received, e.g. POST, for the partners
+	// signing with the uppercase method.
+	Preserve
+)
+
+// WithRequestTargetMethodCase configures the case of the method in the
+// (request-target) field of the Cavage and JWS signatures. The Signer must
+// use the same case, see WithSignerRequestTargetMethodCase. The default is
+// Lower.
+func WithRequestTargetMethodCase(methodCase MethodCase) Option {
+	return func(a *Authenticator) {
+		a.methodCase = methodCase
+	}
+}
+
+// WithBeforeVerify configures the gin middlewares to call fn before verifying
+// the requests, e.g. to set context values used by a SecretProvider or the
+// validators. The hooks run in the order they are configured, after the
+// skippers and before the signature is parsed, the validators run and the
+// failures are counted by the FailureLimiter. A non-nil error aborts the
+// request, OptionalAuthenticated included: a *VerifyError with its reason,
+// any other error with ReasonRejected, both mapped to a status code like the
+// verification failures. The hooks are not run by Verify and Middleware.
+func WithBeforeVerify(fn func(c *gin.Context) error) Option {
+	return func(a *Authenticator) {
+		a.beforeVerify = append(a.beforeVerify, fn)
+	}
+}
+
+// WithRequireTLS configures the Authenticator to reject the requests not
+// received over TLS with ReasonTLSRequired, before any other check: the
+// signatures sent in plaintext could be captured and replayed. Behind a
+// proxy terminating TLS, see WithTrustForwardedProto.
+func WithRequireTLS(require bool) Option {
+	return func(a *Authenticator) {
+		a.requireTLS = require
+	}
+}
+
+// WithTrustForwardedProto configures WithRequireTLS to accept the requests
+// with a X-Forwarded-Proto: https header. Only enable it behind a proxy
+// setting this header, clients could send it otherwise.
+func WithTrustForwardedProto(trust bool) Option {
+	return func(a *Authenticator) {
+		a.trustForwardedProto = trust
+	}
+}
+
+// WithFailureLimiter configures the Authenticator to consult l before
+// verifying each request and to report the failures to it, e.g. a
+// TokenBucketLimiter. The rejected requests fail with ReasonTooManyFailures.
+// Internal errors are not reported as failures.
+func WithFailureLimiter(l FailureLimiter) Option {
+	return func(a *Authenticator) {
+		a.limiter = l
+	}
+}
+
+// WithMandatoryHeaders configures headers every signature must cover on top
+// of the required headers, as a baseline policy. Unlike the required headers
+// they are not replaced by AuthenticatedWithHeaders. The error of a signature
+// not covering one of them wraps ErrHeaderNotEnough and names the header.
+func WithMandatoryHeaders(headers ...string) Option {
+	return func(a *Authenticator) {
+		a.mandatoryHeaders = append(a.mandatoryHeaders, headers...)
+	}
+}
+
+// WithHeadersRequiredIfPresent configures headers the signatures must cover
+// only when the request has them, e.g. content-type, so that it cannot be
+// swapped after signing while the requests without it are still accepted. The
+// headers also listed in the required headers are required only when present
+// too. The error of a signature not covering one of them wraps
+// ErrHeaderNotEnough and names the header. A header sent empty is present.
+// The pseudo headers, like (created), and the derived components, like
+// @method, are not headers and are ignored: use WithRequiredHeaders for them.
+func WithHeadersRequiredIfPresent(headers ...string) Option {
+	return func(a *Authenticator) {
+		for _, h := range headers {
+			if strings.HasPrefix(h, "(") || strings.HasPrefix(h, "@") {
+				continue
+			}
+			a.ifPresentHeaders = append(a.ifPresentHeaders, strings.ToLower(h))
+		}
+	}
+}
+
+// WithSkipper configures the middlewares to let the requests for which skip
+// returns true through without verification, e.g. health checks. Verify still
+// verifies them. Several skippers could be configured, a request is skipped
+// when any of them returns true.
+func WithSkipper(skip func(r *http.Request) bool) Option {
+	return func(a *Authenticator) {
+		a.skippers = append(a.skippers, skip)
+	}
+}
+
+// WithSkipPaths configures the middlewares to let the requests for paths
+// through without verification, see WithSkipper. Like the patterns of
+// http.ServeMux, a path ending with a slash, e.g. /debug/, matches all the
+// paths it prefixes, other paths, e.g. /healthz, match exactly.
+func WithSkipPaths(paths ...string) Option {
+	return WithSkipper(func(r *http.Request) bool {
+		for _, p := range paths {
+			if r.URL.Path == p || (strings.HasSuffix(p, "/") && strings.HasPrefix(r.URL.Path, p)) {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// WithStrictAlgorithm configures the Authenticator to require the signature
+// header to declare an algorithm matching exactly the algorithm of the secret.
+// By default a missing algorithm parameter is accepted and the algorithm of
+// the secret is used.
+func WithStrictAlgorithm(strict bool) Option {
+	return func(a *Authenticator) {
+		a.strictAlgo = strict
+	}
+}
+
+// WithDebug configures the Authenticator to print the verification errors to
+// stdout, unless a Logger is configured with WithLogger. The middlewares also
+// set the X-Signature-Verify-Ms response header to the verification duration
+// in milliseconds.
 func WithDebug(debug bool) Option {
 	return func(a *Authenticator) {
 		a.debug = debug
 	}
 }
 
+// WithDebugFormat configures the format of the messages printed in debug mode
+// or reported to the Logger: Text, the default, or JSON for log aggregation.
+func WithDebugFormat(format DebugFormat) Option {
+	return func(a *Authenticator) {
+		a.debugFormat = format
+	}
+}
+
+// WithLogger configures the Authenticator to report the verification errors
+// to l.
+func WithLogger(l Logger) Option {
+	return func(a *Authenticator) {
+		a.logger = l
+	}
+}
+
+// WithMetrics configures the Authenticator to report the outcome of each
+// verification to hooks.
+func WithMetrics(hooks MetricsHooks) Option {
+	return func(a *Authenticator) {
+		a.metrics = hooks
+	}
+}
+
 // NewAuthenticator creates a new Authenticator instance with
 // given allowed permissions and required header and secret keys.
 func NewAuthenticator(secretKeys Secrets, options ...Option) *Authenticator {
-	var a = &Authenticator{secrets: secretKeys}
+	var a = &Authenticator{secrets: secretKeys, maxCoveredHeaders: defaultMaxCoveredHeaders}
 
 	for _, fn := range options {
 		fn(a)
@@ -72,125 +512,960 @@ func NewAuthenticator(secretKeys Secrets, options ...Option) *Authenticator {
 		}
 	}
 
+	a.digestHeader = digest
+	for _, v := range a.validators {
+		if dv, ok := v.(*validator.DigestValidator); ok {
+			a.digestHeader = strings.ToLower(dv.Header())
+			a.maxBodySize = dv.MaxBodySize
+			if dv.CoveredHeader {
+				a.digestHeaders = []string{digest, contentDigest}
+			}
+		}
+	}
+	if a.digestHeaders == nil {
+		a.digestHeaders = []string{a.digestHeader}
+	}
+
 	if len(a.headers) == 0 {
 		a.headers = defaultRequiredHeaders
+		if a.format == RFC9421 {
+			a.headers = defaultRFC9421RequiredHeaders
+		} else if a.digestHeader != digest {
+			a.headers = []string{requestTarget, date, a.digestHeader}
+		}
+	}
+	if len(a.queryParams) > 0 {
+		a.headers = append(append([]string{}, a.headers...), a.queryParams...)
+	}
+	if a.canonicalizer == nil {
+		a.canonicalizer = newCanonicalizer(a.format, CavageCanonicalizer{
+			Strict:            a.strictHeaderValues,
+			RequestTargetMode: a.requestTargetMode,
+			MethodCase:        a.methodCase,
+		})
 	}
 
 	return a
 }
 
+// MustNewAuthenticator is NewAuthenticator panicking when the algorithm of a
+// secret is missing or unknown, see Secrets.Validate, so that the
+// misconfigurations are found at startup rather than by the requests. Only
+// Secrets are checked, not the other SecretProvider.
+func MustNewAuthenticator(secretKeys Secrets, options ...Option) *Authenticator {
+	a := NewAuthenticator(secretKeys, options...)
+	if secrets, ok := a.secrets.(Secrets); ok {
+		if err := secrets.Validate(); err != nil {
+			panic(err)
+		}
+	}
+	return a
+}
+
 // Authenticated returns a gin middleware which permits given permissions in parameter.
 func (a *Authenticator) Authenticated() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		sigHeader, err := NewSignatureHeader(c.Request)
-		if err != nil {
-			c.AbortWithError(http.StatusUnauthorized, err)
-			a.printErrorMessage(err)
+		if a.skip(c.Request) {
+			c.Next()
 			return
 		}
-		for _, v := range a.validators {
-			if err := v.Validate(c.Request); err != nil {
-				c.AbortWithError(http.StatusBadRequest, err)
-				a.printErrorMessage(err)
-				return
-			}
-		}
-		if !a.isValidHeader(sigHeader.headers) {
-			c.AbortWithError(http.StatusBadRequest, ErrHeaderNotEnough)
-			a.printErrorMessage(ErrHeaderNotEnough)
+		if err := a.runBeforeVerify(c); err != nil {
+			abortWithError(c, err)
 			return
 		}
-
-		secret, err := a.getSecret(sigHeader.keyID, sigHeader.algorithm)
+		a.limitBody(c.Writer, c.Request)
+		start := time.Now()
+		sigHeaders, err := a.verifyRequest(c.Request.Context(), c.Request)
+		a.setVerifyDuration(c, time.Since(start))
 		if err != nil {
-			c.AbortWithError(http.StatusBadRequest, err)
-			a.printErrorMessage(err)
+			abortWithError(c, err)
 			return
 		}
+		setContextSignature(c, sigHeaders)
+		c.Next()
+	}
+}
 
-		signString, err := constructSignMessage(c.Request, sigHeader.headers)
-		if err != nil {
-			c.AbortWithError(http.StatusBadRequest, err)
-			a.printErrorMessage(err)
-			return
+// abortWithError aborts c with the status code of err, the reason of err is
+// the meta of the gin error.
+func abortWithError(c *gin.Context, err error) {
+	status := StatusCode(err)
+	var reason FailureReason
+	var verr *VerifyError
+	if errors.As(err, &verr) {
+		err, reason = verr.Err, verr.Reason
+	}
+	_ = c.AbortWithError(status, toGinError(err, reason))
+}
+
+// runBeforeVerify runs the hooks of WithBeforeVerify, the error of the first
+// failing one is returned as a *VerifyError.
+func (a *Authenticator) runBeforeVerify(c *gin.Context) error {
+	for _, fn := range a.beforeVerify {
+		err := fn(c)
+		if err == nil {
+			continue
+		}
+		// The hooks may return shared errors, which are copied rather than
+		// changed
+		verr := newVerifyError(ReasonRejected, err)
+		if hookErr, ok := err.(*VerifyError); ok {
+			copied := *hookErr
+			verr = &copied
 		}
+		verr.StatusCode = a.statusCode(verr.Reason)
+		a.printErrorMessage(c.Request, verr)
+		return verr
+	}
+	return nil
+}
 
-		signature, err := secret.Algorithm.Sign(signString, secret.Key)
-		if err != nil {
-			c.AbortWithError(http.StatusInternalServerError, err)
-			a.printErrorMessage(err)
+// OptionalAuthenticated returns a gin middleware verifying the signature of
+// the requests like Authenticated, but never aborting them: unsigned requests
+// and requests with an invalid signature reach the handlers too. The
+// ContextKeyAuthenticated value tells them apart, see IsAuthenticated, and
+// the context values of the signature are only set for valid signatures.
+func (a *Authenticator) OptionalAuthenticated() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if a.skip(c.Request) {
+			c.Set(ContextKeyAuthenticated, false)
+			c.Next()
 			return
 		}
-
-		signatureBase64 := base64.StdEncoding.EncodeToString(signature)
-		if signatureBase64 != sigHeader.signature {
-			c.AbortWithError(http.StatusUnauthorized, ErrInvalidSign)
-			a.printErrorMessage(ErrInvalidSign)
+		if err := a.runBeforeVerify(c); err != nil {
+			abortWithError(c, err)
 			return
 		}
+		a.limitBody(c.Writer, c.Request)
+		start := time.Now()
+		sigHeaders, err := a.verifyRequest(c.Request.Context(), c.Request)
+		a.setVerifyDuration(c, time.Since(start))
+		if err != nil {
+			c.Set(ContextKeyAuthenticated, false)
+		} else {
+			setContextSignature(c, sigHeaders)
+		}
 		c.Next()
 	}
 }
 
-func (a *Authenticator) printErrorMessage(err error) {
-	if a.debug {
-		fmt.Printf("%s [HTTP_SIGN] [ERROR] %s\n", time.Now().Format(time.StampMilli), err.Error())
+// AuthenticatedWithHeaders returns a gin middleware like Authenticated, but
+// requiring headers instead of the required headers of the Authenticator.
+// Secrets, validators and the other options are shared, so route groups could
+// require different headers from a single Authenticator. headers is the
+// complete list, use QueryParamHeader to require query parameters. The
+// headers of WithMandatoryHeaders are still required.
+func (a *Authenticator) AuthenticatedWithHeaders(headers []string) gin.HandlerFunc {
+	route := *a
+	route.headers = headers
+	return route.Authenticated()
+}
+
+// limitBody caps the body of r to the MaxBodySize of the digest validator
+// with http.MaxBytesReader, so that chunked bodies without a Content-Length
+// are not read past the limit either.
+func (a *Authenticator) limitBody(w http.ResponseWriter, r *http.Request) {
+	if a.maxBodySize > 0 && r.Body != nil && r.Body != http.NoBody {
+		r.Body = http.MaxBytesReader(w, r.Body, a.maxBodySize)
 	}
 }
 
-// isValidHeader check if all server required header is in header list
-func (a *Authenticator) isValidHeader(headers []string) bool {
-	m := len(headers)
-	for _, h := range a.headers {
-		i := 0
-		for i = 0; i < m; i++ {
-			if h == headers[i] {
-				break
+// Verify checks the signature of the request: it parses the signature header,
+// runs the validators, checks the required headers and compares the signature
+// with the one computed from the secret. Any failure is returned as *VerifyError.
+func (a *Authenticator) Verify(r *http.Request) error {
+	return a.VerifyContext(r.Context(), r)
+}
+
+// VerifyContext is Verify with a context, which is passed to the secret lookup
+// and to the validators implementing validator.ContextValidator. Verification
+// stops with ReasonInternal when ctx is done.
+func (a *Authenticator) VerifyContext(ctx context.Context, r *http.Request) error {
+	_, err := a.verifyRequest(ctx, r)
+	return err
+}
+
+// VerifySignatureOnly checks the cryptographic signature of r, e.g. for a
+// diagnostic endpoint telling the clients whether they sign correctly
+// regardless of their clock. The required headers and the signature are
+// checked like Verify does, but none of the validators run and the expires
+// parameter is ignored: neither the date window nor the digest of the body
+// are checked, and nonces are neither checked nor stored.
+//
+// It is unsafe for authentication: replayed requests and tampered bodies
+// pass. Use Verify or the middlewares to authenticate the requests.
+func (a *Authenticator) VerifySignatureOnly(r *http.Request) error {
+	_, err := a.verifyRequest(context.WithValue(r.Context(), signatureOnlyKey{}, true), r)
+	return err
+}
+
+type signatureOnlyKey struct{}
+
+// isSignatureOnly reports whether ctx verifies only the signature, see
+// VerifySignatureOnly
+func isSignatureOnly(ctx context.Context) bool {
+	signatureOnly, _ := ctx.Value(signatureOnlyKey{}).(bool)
+	return signatureOnly
+}
+
+// verifyRequest verifies r and returns its verified signature headers
+func (a *Authenticator) verifyRequest(ctx context.Context, r *http.Request) ([]*SignatureHeader, error) {
+	start := time.Now()
+	sigHeaders, err := a.verify(ctx, r)
+	if err != nil {
+		reason := ReasonInternal
+		if verr, ok := err.(*VerifyError); ok {
+			verr.StatusCode = a.statusCode(verr.Reason)
+			reason = verr.Reason
+		}
+		if a.metrics != nil {
+			a.metrics.OnFailure(reason, time.Since(start))
+		}
+		a.printErrorMessage(r, err)
+		return nil, err
+	}
+	if a.metrics != nil {
+		a.metrics.OnSuccess(sigHeaders[0].keyID, time.Since(start))
+	}
+	return sigHeaders, nil
+}
+
+func (a *Authenticator) verify(ctx context.Context, r *http.Request) ([]*SignatureHeader, error) {
+	if a.requireTLS && !a.isTLS(r) {
+		return nil, newVerifyError(ReasonTLSRequired, ErrTLSRequired)
+	}
+	sigHeaders, err := a.parseSignatureHeaders(r)
+	var keyID KeyID
+	if err == nil {
+		keyID = sigHeaders[0].keyID
+	}
+	limiter := a.limiter
+	if isHistorical(ctx) {
+		limiter = nil
+	}
+	if limiter != nil && !limiter.Allow(r, keyID) {
+		return nil, newVerifyError(ReasonTooManyFailures, ErrTooManyFailures)
+	}
+	if err != nil {
+		reason := ReasonMalformedSignature
+		if err == ErrNoSignature {
+			reason = ReasonMissingSignature
+		}
+		err = newVerifyError(reason, err)
+	} else {
+		sigHeaders, err = a.verifySignatureHeaders(ctx, r, sigHeaders)
+	}
+	if err != nil {
+		verr, ok := err.(*VerifyError)
+		if ok {
+			verr.keyID = keyID
+		}
+		if limiter != nil && ok && verr.Reason != ReasonInternal {
+			// Only a bad signature proves the keyID was checked with its
+			// secrets
+			if verr.Reason != ReasonBadSignature {
+				keyID = ""
 			}
+			limiter.Failed(r, keyID)
 		}
-		if i == m {
+		return nil, err
+	}
+	return sigHeaders, nil
+}
+
+// verifySignatureHeaders verifies the signatures of r according to the
+// SignaturePolicy and returns the verified ones. The validators checking the
+// request rather than a signature run once, before the signatures are
+// verified, and their failure rejects the request whatever the policy.
+func (a *Authenticator) verifySignatureHeaders(ctx context.Context, r *http.Request, sigHeaders []*SignatureHeader) ([]*SignatureHeader, error) {
+	if len(sigHeaders) == 1 {
+		sigHeader, err := a.verifySignatureHeader(ctx, r, sigHeaders[0], validateAll)
+		if err != nil {
+			return nil, err
+		}
+		return []*SignatureHeader{sigHeader}, nil
+	}
+
+	if err := a.runValidators(ctx, r, sigHeaders[0], a.skipDigest(r), validateRequest); err != nil {
+		return nil, err
+	}
+	var (
+		verified []*SignatureHeader
+		firstErr error
+	)
+	for _, h := range sigHeaders {
+		sigHeader, err := a.verifySignatureHeader(ctx, r, h, validateSignature)
+		if err != nil {
+			if a.signaturePolicy == AllSignatures {
+				return nil, err
+			}
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		verified = append(verified, sigHeader)
+	}
+	if len(verified) == 0 {
+		return nil, firstErr
+	}
+	return verified, nil
+}
+
+// validatorScope selects the validators run by runValidators
+type validatorScope int
+
+const (
+	// validateAll runs all the validators
+	validateAll validatorScope = iota
+	// validateRequest runs the validators checking the request
+	validateRequest
+	// validateSignature runs the validator.SignatureValidator
+	validateSignature
+)
+
+// skipDigest reports whether the digest of r is not checked, see
+// WithDigestRequiredForBody
+func (a *Authenticator) skipDigest(r *http.Request) bool {
+	if !a.digestForBodyOnly || hasBody(r) {
+		return false
+	}
+	for _, h := range a.digestHeaders {
+		if r.Header.Get(h) != "" {
 			return false
 		}
 	}
 	return true
 }
 
-func (a *Authenticator) getSecret(keyID KeyID, algorithm string) (*Secret, error) {
-	secret, ok := a.secrets[keyID]
-	if !ok {
-		return nil, ErrInvalidKeyID
+// runValidators runs the validators of scope on r signed by sigHeader
+func (a *Authenticator) runValidators(ctx context.Context, r *http.Request, sigHeader *SignatureHeader, skipDigest bool, scope validatorScope) error {
+	if isSignatureOnly(ctx) {
+		return nil
+	}
+	for _, v := range a.validators {
+		if _, ok := v.(validator.SignatureValidator); (ok && scope == validateRequest) || (!ok && scope == validateSignature) {
+			continue
+		}
+		if _, ok := v.(*validator.DigestValidator); ok && skipDigest {
+			continue
+		}
+		if isTimeValidator(v) && isHistorical(ctx) {
+			continue
+		}
+		// A covered date header missing from the request is reported as
+		// such rather than as a date that could not be parsed.
+		if dv, ok := v.(*validator.DateValidator); ok && r.Header.Get(dv.HeaderName) == "" && isCovered(sigHeader.headers, dv.HeaderName) {
+			return newVerifyError(ReasonMissingHeader, newMissingHeaderError(strings.ToLower(dv.HeaderName)))
+		}
+		if err := ctx.Err(); err != nil {
+			return newVerifyError(ReasonInternal, err)
+		}
+		if err := validateContext(ctx, v, r, sigHeader); err != nil {
+			return newVerifyError(validationFailureReason(err), err)
+		}
+	}
+	return nil
+}
+
+// verifySignatureHeader runs the checks of the parsed sigHeader, with the
+// validators of scope
+func (a *Authenticator) verifySignatureHeader(ctx context.Context, r *http.Request, sigHeader *SignatureHeader, scope validatorScope) (*SignatureHeader, error) {
+	if a.maxCoveredHeaders > 0 && len(sigHeader.headers) > a.maxCoveredHeaders {
+		return nil, newVerifyError(ReasonTooManyHeaders, ErrTooManyHeaders)
+	}
+	if !sigHeader.expires.IsZero() && time.Now().After(sigHeader.expires) && !isHistorical(ctx) && !isSignatureOnly(ctx) {
+		return nil, newVerifyError(ReasonExpiredSignature, ErrSignatureExpired)
+	}
+	skipDigest := a.skipDigest(r)
+	if a.requireDigestForBody && hasBody(r) && !a.digestCovered(sigHeader.headers) {
+		return nil, newVerifyError(ReasonMissingHeader, newHeaderNotCoveredError(a.digestHeader))
+	}
+	if err := a.runValidators(ctx, r, sigHeader, skipDigest, scope); err != nil {
+		return nil, err
+	}
+	for _, h := range a.mandatoryHeaders {
+		if !isCovered(sigHeader.headers, h) && !(skipDigest && strings.EqualFold(h, a.digestHeader)) {
+			return nil, newVerifyError(ReasonMissingHeader, newHeaderNotCoveredError(strings.ToLower(h)))
+		}
+	}
+	if missing := a.missingHeaders(r, sigHeader.headers, skipDigest); len(missing) > 0 {
+		return nil, newVerifyError(ReasonMissingHeader, newHeaderNotCoveredError(missing...))
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, newVerifyError(ReasonInternal, err)
+	}
+	candidates, err := a.getSecrets(ctx, sigHeader.keyID, sigHeader.algorithm)
+	if err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return nil, newVerifyError(ReasonInternal, ctxErr)
+		}
+		reason := ReasonInternal
+		switch {
+		case errors.Is(err, ErrInvalidKeyID):
+			reason = ReasonUnknownKeyID
+		case err == ErrIncorrectAlgorithm:
+			reason = ReasonAlgorithmMismatch
+		case err == ErrAlgorithmNotAllowed:
+			reason = ReasonAlgorithmNotAllowed
+		}
+		return nil, newVerifyError(reason, err)
+	}
+	if sigHeader.algorithm == "" {
+		sigHeader.algorithm = candidates[0].Algorithm.Name()
+	}
+	candidates = a.timedSecrets(ctx, candidates, r, sigHeader)
+
+	if coversTrailers(sigHeader.headers) {
+		if err := a.readBody(r); err != nil {
+			return nil, newVerifyError(validationFailureReason(err), err)
+		}
+	}
+	signString, err := a.constructSignMessage(r, sigHeader)
+	if err == ErrInvalidRequestTarget {
+		return nil, newVerifyError(ReasonBadSignature, err)
+	}
+	if err != nil {
+		return nil, newVerifyError(ReasonMissingHeader, err)
+	}
+
+	// RFC 9421 and JWS signatures are decoded by their parsers and always
+	// carried as base64
+	encoding := a.encoding
+	if a.format != Cavage {
+		encoding = Base64
+	}
+	signature, err := encoding.decode(sigHeader.signature)
+	if err != nil {
+		return nil, newVerifyError(ReasonBadSignature, ErrInvalidSign)
 	}
 
-	if secret.Algorithm.Name() != algorithm {
-		if algorithm != "" {
-			return nil, ErrIncorrectAlgorithm
+	// During key rotation the keyID has several secrets, the signature is
+	// valid when any of them verifies it.
+	verr := newVerifyError(ReasonBadSignature, ErrInvalidSign)
+	for _, secret := range candidates {
+		err := a.verifySignature(secret, signString, signature)
+		if err == nil {
+			if a.ipAllowList != nil && !a.ipAllowList.Allowed(sigHeader.keyID, a.sourceIP(r)) {
+				return nil, newVerifyError(ReasonIPNotAllowed, ErrIPNotAllowed)
+			}
+			return sigHeader, nil
 		}
+		if !errors.Is(err, crypto.ErrInvalidSignature) {
+			verr = newVerifyError(ReasonInternal, &SigningError{Op: "verify", KeyID: sigHeader.keyID, Err: err})
+		}
+	}
+	if a.debug && verr.Reason == ReasonBadSignature {
+		a.printSignDebug(r, sigHeader, signString)
 	}
-	return secret, nil
+	return nil, verr
 }
 
-func constructSignMessage(r *http.Request, headers []string) (string, error) {
-	var signBuffer bytes.Buffer
+func (a *Authenticator) verifySignature(secret *Secret, signString string, signature []byte) error {
+	if a.format != Cavage && strings.HasPrefix(secret.Algorithm.Name(), "ecdsa-") {
+		var err error
+		if signature, err = ecdsaSignatureToASN1(signature); err != nil {
+			return crypto.ErrInvalidSignature
+		}
+	}
+	return secret.Algorithm.Verify(signString, signature, secret.verifyingKey())
+}
 
-	for i, field := range headers {
-		var fieldValue string
-		switch field {
-		case host:
-			fieldValue = r.Host
-		case requestTarget:
-			fieldValue = fmt.Sprintf("%s %s", strings.ToLower(r.Method), r.URL.RequestURI())
-		default:
-			fieldValue = r.Header.Get(field)
-			if fieldValue == "" {
-				return "", ErrEmptyHeader
+// parseSignatureHeaders parses the signatures of r, a single one unless the
+// SignaturePolicy verifies several RFC 9421 signatures.
+//
+// The keyID resolved by WithKeyIDResolver, if any, is the keyID of the
+// signatures without one, and must be the keyID of the others.
+func (a *Authenticator) parseSignatureHeaders(r *http.Request) ([]*SignatureHeader, error) {
+	var (
+		keyID    KeyID
+		resolved bool
+	)
+	if a.keyIDResolver != nil {
+		keyID, resolved = a.keyIDResolver(r)
+		resolved = resolved && keyID != ""
+	}
+
+	var sigHeaders []*SignatureHeader
+	if a.format == RFC9421 && a.signaturePolicy != FirstSignature {
+		var err error
+		if sigHeaders, err = parseRFC9421Signatures(r, !resolved); err != nil {
+			return nil, err
+		}
+	} else {
+		sigHeader, err := a.parseSignatureHeader(r, !resolved)
+		if err != nil {
+			return nil, err
+		}
+		sigHeaders = []*SignatureHeader{sigHeader}
+	}
+
+	if resolved {
+		for _, sigHeader := range sigHeaders {
+			if sigHeader.keyID == "" {
+				sigHeader.keyID = keyID
+			} else if sigHeader.keyID != keyID {
+				return nil, ErrKeyIDMismatch
 			}
 		}
-		signString := fmt.Sprintf("%s: %s", field, fieldValue)
-		signBuffer.WriteString(signString)
-		if i < len(headers)-1 {
-			signBuffer.WriteString("\n")
+	}
+	return sigHeaders, nil
+}
+
+func (a *Authenticator) parseSignatureHeader(r *http.Request, requireKeyID bool) (*SignatureHeader, error) {
+	switch a.format {
+	case RFC9421:
+		return parseRFC9421Request(r, requireKeyID)
+	case JWS:
+		return parseJWSRequest(r, requireKeyID)
+	}
+	return parseHTTPRequest(r, a.preferAuthorization, requireKeyID)
+}
+
+func (a *Authenticator) constructSignMessage(r *http.Request, sigHeader *SignatureHeader) (string, error) {
+	r, err := a.resolveRequestTarget(r)
+	if err != nil {
+		return "", err
+	}
+	return a.canonicalizer.Canonicalize(r, sigHeader)
+}
+
+// resolveRequestTarget returns a shallow copy of r whose URL has the path and
+// the query of the request target resolved by WithRequestTargetResolver.
+func (a *Authenticator) resolveRequestTarget(r *http.Request) (*http.Request, error) {
+	if a.requestTarget == nil {
+		return r, nil
+	}
+	target := a.requestTarget(r)
+	if target == "" {
+		return r, nil
+	}
+	u, err := url.ParseRequestURI(target)
+	if err != nil {
+		return nil, ErrInvalidRequestTarget
+	}
+	resolved := *r.URL
+	resolved.Path, resolved.RawPath = u.Path, u.RawPath
+	resolved.RawQuery, resolved.ForceQuery = u.RawQuery, u.ForceQuery
+	r = r.WithContext(r.Context())
+	r.URL = &resolved
+	return r, nil
+}
+
+// isTLS reports whether r was received over TLS
+func (a *Authenticator) isTLS(r *http.Request) bool {
+	if r.TLS != nil {
+		return true
+	}
+	return a.trustForwardedProto && strings.EqualFold(r.Header.Get(forwardedProtoHeader), "https")
+}
+
+// skip reports whether the middlewares let r through without verification
+func (a *Authenticator) skip(r *http.Request) bool {
+	for _, skip := range a.skippers {
+		if skip(r) {
+			return true
+		}
+	}
+	return false
+}
+
+// printErrorMessage logs err with the Logger, or to stdout in debug mode.
+// Signing failures, which operators must fix, are logged even without debug.
+func (a *Authenticator) printErrorMessage(r *http.Request, err error) {
+	if a.logger == nil && !a.debug && !errors.Is(err, ErrSigningFailed) {
+		return
+	}
+	if a.debugFormat == JSON {
+		entry := debugEntry{Level: "error", Reason: ReasonInternal, Path: r.URL.Path, Error: err.Error()}
+		if verr, ok := err.(*VerifyError); ok {
+			entry.Reason, entry.KeyID = verr.Reason, verr.keyID
+		}
+		a.printJSON(entry)
+		return
+	}
+	a.printer().Printf("[ERROR] %s", err.Error())
+}
+
+// printSignDebug logs in debug mode the material of the signature sigHeader
+// which did not verify, to be compared with the one of the client. The JSON
+// format leaves the signature out.
+func (a *Authenticator) printSignDebug(r *http.Request, sigHeader *SignatureHeader, signString string) {
+	if a.debugFormat == JSON {
+		a.printJSON(debugEntry{
+			Level:         "debug",
+			Reason:        ReasonBadSignature,
+			KeyID:         sigHeader.keyID,
+			Path:          r.URL.Path,
+			Error:         ErrInvalidSign.Error(),
+			Algorithm:     sigHeader.algorithm,
+			Headers:       sigHeader.headers,
+			SigningString: signString,
+		})
+		return
+	}
+	a.printer().Printf("[DEBUG] keyId=%s algorithm=%s headers=%q signature=%s expected signing string=%q",
+		sigHeader.keyID, sigHeader.algorithm, sigHeader.headers, sigHeader.signature, signString)
+}
+
+// statusCode returns the HTTP status code of a failure with reason
+func (a *Authenticator) statusCode(reason FailureReason) int {
+	if a.statusMapper != nil {
+		return a.statusMapper(reason)
+	}
+	return DefaultErrorStatus(reason)
+}
+
+// hasBody reports whether r has a body: a non-zero or unknown Content-Length,
+// or a chunked Transfer-Encoding.
+func hasBody(r *http.Request) bool {
+	if r.Body == nil || r.Body == http.NoBody {
+		return false
+	}
+	for _, te := range r.TransferEncoding {
+		if te == "chunked" {
+			return true
+		}
+	}
+	return r.ContentLength != 0
+}
+
+// validateContext runs v, with ctx or with the parameters of sigHeader when v
+// supports it
+func validateContext(ctx context.Context, v validator.Validator, r *http.Request, sigHeader *SignatureHeader) error {
+	if sv, ok := v.(validator.SignatureValidator); ok {
+		return sv.ValidateSignature(r, &validator.Signature{
+			KeyID:     string(sigHeader.keyID),
+			Algorithm: sigHeader.algorithm,
+			Label:     sigHeader.label,
+			Headers:   sigHeader.headers,
+			Created:   sigHeader.created,
+			Expires:   sigHeader.expires,
+		})
+	}
+	if cv, ok := v.(validator.ContextValidator); ok {
+		return cv.ValidateContext(ctx, r)
+	}
+	return v.Validate(r)
+}
+
+// validationFailureReason returns the FailureReason of an error returned by a
+// validator.
+func validationFailureReason(err error) FailureReason {
+	var verr *validator.ValidationError
+	if !errors.As(err, &verr) {
+		return ReasonValidationFailed
+	}
+	switch verr.Code {
+	case validator.CodeInvalidDate, validator.CodeDateNotInRange:
+		return ReasonExpiredDate
+	case validator.CodeMissingCreated, validator.CodeCreatedNotInRange:
+		return ReasonExpiredSignature
+	case validator.CodeCreatedNotCovered:
+		return ReasonMissingHeader
+	case validator.CodeInvalidDigest, validator.CodeDigestAlgorithmNotAllowed, validator.CodeBodyLengthMismatch, validator.CodeInvalidBody:
+		return ReasonBadDigest
+	case validator.CodeBodyTooLarge:
+		return ReasonBodyTooLarge
+	case validator.CodeHostNotAllowed:
+		return ReasonHostNotAllowed
+	case validator.CodeMissingNonce, validator.CodeNonceReused:
+		return ReasonReplayedNonce
+	}
+	return ReasonValidationFailed
+}
+
+// missingHeaders returns the server required headers which are not in the
+// header list, lowercased. Header names are compared case-insensitively. The
+// digest header is not required when skipDigest is set, and the headers of
+// WithHeadersRequiredIfPresent only when r has them.
+func (a *Authenticator) missingHeaders(r *http.Request, headers []string, skipDigest bool) []string {
+	covered := make(map[string]struct{}, len(headers))
+	for _, h := range headers {
+		covered[strings.ToLower(h)] = struct{}{}
+	}
+	var missing []string
+	for _, h := range a.headers {
+		h = strings.ToLower(h)
+		if skipDigest && h == a.digestHeader {
+			continue
+		}
+		if isCovered(a.ifPresentHeaders, h) && !hasHeader(r, h) {
+			continue
+		}
+		if _, ok := covered[h]; !ok {
+			missing = append(missing, h)
+		}
+	}
+	for _, h := range a.ifPresentHeaders {
+		if _, ok := covered[h]; !ok && hasHeader(r, h) && !isCovered(a.headers, h) {
+			missing = append(missing, h)
+		}
+	}
+	return missing
+}
+
+// hasHeader reports whether r has the header h, even with an empty value. The
+// host header is removed from r.Header by net/http, r.Host is checked instead.
+func hasHeader(r *http.Request, h string) bool {
+	if h == host {
+		return r.Host != ""
+	}
+	return len(r.Header.Values(h)) > 0
+}
+
+// digestCovered reports whether one of the digest headers is covered
+func (a *Authenticator) digestCovered(headers []string) bool {
+	for _, h := range a.digestHeaders {
+		if isCovered(headers, h) {
+			return true
+		}
+	}
+	return false
+}
+
+// isCovered reports whether name is one of the covered headers, compared
+// case-insensitively
+func isCovered(headers []string, name string) bool {
+	for _, h := range headers {
+		if strings.EqualFold(h, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// getSecrets returns the secrets of keyID matching algorithm
+func (a *Authenticator) getSecrets(ctx context.Context, keyID KeyID, algorithm string) ([]*Secret, error) {
+	if algorithm != "" && !a.algorithmAllowed(algorithm) {
+		return nil, ErrAlgorithmNotAllowed
+	}
+	secret, err := a.secrets.Get(ctx, keyID)
+	if err != nil {
+		return nil, err
+	}
+	if secret == nil {
+		return nil, ErrInvalidKeyID
+	}
+
+	var (
+		secrets    []*Secret
+		disallowed bool
+	)
+	for _, s := range secret.all() {
+		if s.Algorithm.Name() != algorithm && (algorithm != "" || a.strictAlgo) {
+			continue
+		}
+		if !a.algorithmAllowed(s.Algorithm.Name()) {
+			disallowed = true
+			continue
+		}
+		secrets = append(secrets, s)
+	}
+	if len(secrets) == 0 && disallowed {
+		return nil, ErrAlgorithmNotAllowed
+	}
+	if len(secrets) == 0 {
+		return nil, ErrIncorrectAlgorithm
+	}
+	return secrets, nil
+}
+
+// timedSecrets returns the secrets accepted at the date of r, see
+// Secrets.AddTimed
+func (a *Authenticator) timedSecrets(ctx context.Context, secrets []*Secret, r *http.Request, sigHeader *SignatureHeader) []*Secret {
+	date, dated := a.validatedDate(ctx, r, sigHeader)
+	valid := secrets[:0:0]
+	for _, s := range secrets {
+		if s.validAt(date, dated) {
+			valid = append(valid, s)
+		}
+	}
+	return valid
+}
+
+// validatedDate returns the date of r checked by the validators, which the
+// client could not set freely: the created parameter covered by sigHeader
+// and checked by a CreatedValidator, else the date header covered by
+// sigHeader and checked by a DateValidator.
+func (a *Authenticator) validatedDate(ctx context.Context, r *http.Request, sigHeader *SignatureHeader) (time.Time, bool) {
+	if isHistorical(ctx) || isSignatureOnly(ctx) {
+		return time.Time{}, false
+	}
+	for _, v := range a.validators {
+		if _, ok := v.(*validator.CreatedValidator); ok && !sigHeader.created.IsZero() &&
+			(sigHeader.label != "" || isCovered(sigHeader.headers, created)) {
+			return sigHeader.created, true
+		}
+	}
+	for _, v := range a.validators {
+		dv, ok := v.(*validator.DateValidator)
+		if !ok || r.Header.Get(dv.HeaderName) == "" || !isCovered(sigHeader.headers, dv.HeaderName) {
+			continue
+		}
+		if date, err := dv.RequestTime(r); err == nil {
+			return date, true
 		}
 	}
+	return time.Time{}, false
+}
 
-	return signBuffer.String(), nil
+// algorithmAllowed reports whether the algorithm name is allowed, see
+// WithAllowedAlgorithms
+func (a *Authenticator) algorithmAllowed(name string) bool {
+	if a.allowedAlgorithms == nil {
+		return true
+	}
+	_, ok := a.allowedAlgorithms[strings.ToLower(name)]
+	return ok
+}
+
+// ConstructSignString returns the signing string of the headers of r, as
+// built by an Authenticator with the default Cavage format, to be compared
+// with the one of a client whose signatures are rejected. The (created) and
+// (expires) pseudo headers, whose values are parameters of the signature,
+// are not supported.
+func ConstructSignString(r *http.Request, headers []string) (string, error) {
+	return constructSignMessage(r, &SignatureHeader{headers: headers})
+}
+
+// constructSignMessage builds the signing string of the fields covered by
+// sigHeader. The values of the (created) and (expires) pseudo headers are the
+// parameters of sigHeader. Header values are normalized, see headerValue.
+func constructSignMessage(r *http.Request, sigHeader *SignatureHeader) (string, error) {
+	return CavageCanonicalizer{}.Canonicalize(r, sigHeader)
+}
+
+// requestTargetURI returns the request URI of r signed in (request-target)
+func requestTargetURI(r *http.Request, mode RequestTargetMode) string {
+	uri := r.URL.RequestURI()
+	if mode == PathOnly {
+		if i := strings.IndexByte(uri, '?'); i >= 0 {
+			uri = uri[:i]
+		}
+	}
+	return uri
+}
+
+// maxPooledBufferSize is the max capacity of the buffers returned to
+// signBufferPool, larger buffers are left to the garbage collector.
+const maxPooledBufferSize = 64 << 10
+
+// signBufferPool holds the buffers the signing strings are built in
+var signBufferPool = sync.Pool{
+	New: func() interface{} { return new(bytes.Buffer) },
+}
+
+func getSignBuffer() *bytes.Buffer {
+	buf := signBufferPool.Get().(*bytes.Buffer)
+	buf.Reset()
+	return buf
+}
+
+// putSignBuffer returns buf to the pool. The strings built with buf.String()
+// are copies, so buf may be reused.
+func putSignBuffer(buf *bytes.Buffer) {
+	if buf.Cap() > maxPooledBufferSize {
+		return
+	}
+	signBufferPool.Put(buf)
+}
+
+// headerValue returns the values of the header field concatenated with ", "
+// in their order in the request, as the signature string requires for headers
+// present several times. Unless strict is set, each value is normalized: the
+// obsolete line folding is replaced by a space and the surrounding optional
+// whitespace is removed.
+func headerValue(h http.Header, field string, strict bool) string {
+	values := h.Values(field)
+	if len(values) == 1 && strict {
+		return values[0]
+	}
+	normalized := make([]string, len(values))
+	for i, v := range values {
+		if !strict {
+			v = normalizeHeaderValue(v)
+		}
+		normalized[i] = v
+	}
+	return strings.Join(normalized, ", ")
+}
+
+// obsFold matches the obsolete line folding of RFC 7230 section 3.2.4
+var obsFold = regexp.MustCompile(`\r?\n[ \t]+`)
+
+func normalizeHeaderValue(v string) string {
+	if strings.ContainsAny(v, "\r\n") {
+		v = obsFold.ReplaceAllString(v, " ")
+	}
+	return strings.Trim(v, " \t")
+}
+
+// QueryParamHeader returns the pseudo header covering the query parameter
+// name, (query-param:name). Its value in the signing string is the URL
+// decoded value of the parameter. A repeated parameter has a line per value,
+// in the order of the query string. A missing parameter is signed as an empty
+// value, so that it can not be added to a signed request.
+func QueryParamHeader(name string) string {
+	return queryParamPrefix + name + ")"
+}
+
+// TrailerHeader returns the pseudo header covering the trailer field name,
+// (trailer:name). Its value in the signing string is the value of the
+// trailer, which must be present.
+//
+// Trailers are only available once the body has been read to the end: the
+// Authenticator reads and buffers the body of the requests covering trailers
+// before building the signing string, and the Signer signs the values set in
+// r.Trailer, so they must be known before signing.
+func TrailerHeader(name string) string {
+	return trailerPrefix + name + ")"
+}
+
+func trailerName(field string) (string, bool) {
+	if !strings.HasPrefix(field, trailerPrefix) || !strings.HasSuffix(field, ")") {
+		return "", false
+	}
+	return field[len(trailerPrefix) : len(field)-1], true
+}
+
+// coversTrailers reports whether one of the fields is a trailer
+func coversTrailers(fields []string) bool {
+	for _, field := range fields {
+		if _, ok := trailerName(field); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// readBody reads the body of r to the end, so that its trailers are set, and
+// restores it to be read again by the handlers. Bodies larger than the
+// MaxBodySize of the digest validator fail with validator.ErrBodyTooLarge.
+func (a *Authenticator) readBody(r *http.Request) error {
+	if r.Body == nil || r.Body == http.NoBody {
+		return nil
+	}
+	var reader io.Reader = r.Body
+	if a.maxBodySize > 0 {
+		reader = http.MaxBytesReader(nil, r.Body, a.maxBodySize)
+	}
+	body, err := ioutil.ReadAll(reader)
+	if isMaxBytesError(err) {
+		return validator.ErrBodyTooLarge
+	}
+	if err != nil {
+		return err
+	}
+	_ = r.Body.Close()
+	r.Body = ioutil.NopCloser(bytes.NewReader(body))
+	return nil
+}
+
+func queryParamName(field string) (string, bool) {
+	if !strings.HasPrefix(field, queryParamPrefix) || !strings.HasSuffix(field, ")") {
+		return "", false
+	}
+	return field[len(queryParamPrefix) : len(field)-1], true
 }
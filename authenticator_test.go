@@ -1,11 +1,17 @@
 package httpsign
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
+	"log"
 	"net/http"
 	"net/http/httptest"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -74,7 +80,7 @@ func TestAuthenticatedHeaderNoSignature(t *testing.T) {
 	require.NoError(t, err)
 	c := runTest(secrets, requiredHeaders, nil, req)
 	assert.Equal(t, http.StatusUnauthorized, c.Writer.Status())
-	assert.Equal(t, ErrNoSignature, c.Errors[0])
+	assert.Equal(t, ErrNoSignature.Err, c.Errors[0].Err)
 }
 
 func TestAuthenticatedHeaderInvalidSignature(t *testing.T) {
@@ -83,7 +89,7 @@ func TestAuthenticatedHeaderInvalidSignature(t *testing.T) {
 	req.Header.Set(authorizationHeader, "hello")
 	c := runTest(secrets, requiredHeaders, nil, req)
 	assert.Equal(t, http.StatusUnauthorized, c.Writer.Status())
-	assert.Equal(t, ErrInvalidAuthorizationHeader, c.Errors[0])
+	assert.Equal(t, ErrInvalidAuthorizationHeader.Err, c.Errors[0].Err)
 }
 
 func TestAuthenticatedHeaderWrongKey(t *testing.T) {
@@ -93,8 +99,8 @@ func TestAuthenticatedHeaderWrongKey(t *testing.T) {
 	req.Header.Set(authorizationHeader, sigHeader)
 	req.Header.Set("Date", time.Now().UTC().Format(http.TimeFormat))
 	c := runTest(secrets, requiredHeaders, nil, req)
-	assert.Equal(t, http.StatusBadRequest, c.Writer.Status())
-	assert.Equal(t, ErrInvalidKeyID, c.Errors[0])
+	assert.Equal(t, http.StatusUnauthorized, c.Writer.Status())
+	assert.Equal(t, ErrInvalidKeyID.Err, c.Errors[0].Err)
 }
 
 func TestAuthenticateDateNotAccept(t *testing.T) {
@@ -104,8 +110,8 @@ func TestAuthenticateDateNotAccept(t *testing.T) {
 	req.Header.Set(authorizationHeader, sigHeader)
 	req.Header.Set("Date", time.Date(1990, time.October, 20, 0, 0, 0, 0, time.UTC).Format(http.TimeFormat))
 	c := runTest(secrets, requiredHeaders, nil, req)
-	assert.Equal(t, http.StatusBadRequest, c.Writer.Status())
-	assert.Equal(t, validator.ErrDateNotInRange, c.Errors[0])
+	assert.Equal(t, http.StatusUnauthorized, c.Writer.Status())
+	assert.Equal(t, validator.ErrDateNotInRange.Err, c.Errors[0].Err)
 }
 
 func TestAuthenticateInvalidRequiredHeader(t *testing.T) {
@@ -118,8 +124,8 @@ func TestAuthenticateInvalidRequiredHeader(t *testing.T) {
 	req.Header.Set("Date", time.Now().UTC().Format(http.TimeFormat))
 
 	c := runTest(secrets, requiredHeaders, nil, req)
-	assert.Equal(t, http.StatusBadRequest, c.Writer.Status())
-	assert.Equal(t, ErrHeaderNotEnough, c.Errors[0])
+	assert.Equal(t, http.StatusUnauthorized, c.Writer.Status())
+	assert.Equal(t, ErrHeaderNotEnough.Err, c.Errors[0].Err)
 }
 
 func TestAuthenticateInvalidAlgo(t *testing.T) {
@@ -130,8 +136,8 @@ func TestAuthenticateInvalidAlgo(t *testing.T) {
 	req.Header.Set("Date", time.Now().UTC().Format(http.TimeFormat))
 
 	c := runTest(secrets, requiredHeaders, nil, req)
-	assert.Equal(t, http.StatusBadRequest, c.Writer.Status())
-	assert.Equal(t, ErrIncorrectAlgorithm, c.Errors[0])
+	assert.Equal(t, http.StatusUnauthorized, c.Writer.Status())
+	assert.Equal(t, ErrIncorrectAlgorithm.Err, c.Errors[0].Err)
 }
 
 func TestInvalidSign(t *testing.T) {
@@ -144,7 +150,7 @@ func TestInvalidSign(t *testing.T) {
 
 	c := runTest(secrets, requiredHeaders, nil, req)
 	assert.Equal(t, http.StatusUnauthorized, c.Writer.Status())
-	assert.Equal(t, ErrInvalidSign, c.Errors[0])
+	assert.Equal(t, ErrInvalidSign.Err, c.Errors[0].Err)
 }
 
 // mock interface always return true
@@ -209,7 +215,7 @@ func TestHttpInvalidDigest(t *testing.T) {
 	w := httptest.NewRecorder()
 	r.ServeHTTP(w, req)
 
-	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
 }
 
 func TestHttpValidRequest(t *testing.T) {
@@ -237,7 +243,7 @@ func TestHttpValidRequestWithCustomDate(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 
 	r := gin.Default()
-	auth := NewAuthenticator(secrets, WithValidator(validator.NewDigestValidator(), validator.NewCustomDateValidator("X-DATE", true)))
+	auth := NewAuthenticator(secrets, WithValidator(validator.NewDigestValidator(), validator.NewCustomDateValidator("X-DATE", true, validator.WithClock(func() time.Time { return requestTime }))))
 	r.Use(auth.Authenticated())
 	r.GET("/", httpTestGet)
 
@@ -245,6 +251,8 @@ func TestHttpValidRequestWithCustomDate(t *testing.T) {
 	require.NoError(t, err)
 	sigHeader := generateSignature(readID, algoHmacSha512, submitHeader, requestEmptyBodySig)
 	req.Header.Set(authorizationHeader, sigHeader)
+	// the signature covers the date header, the validator reads X-DATE
+	req.Header.Set("Date", requestTime.Format(http.TimeFormat))
 	req.Header.Set("X-DATE", requestTime.Format(http.TimeFormat))
 	req.Header.Set("Digest", requestBodyEmptyDigest)
 
@@ -252,6 +260,11 @@ func TestHttpValidRequestWithCustomDate(t *testing.T) {
 	r.ServeHTTP(w, req)
 
 	assert.Equal(t, http.StatusOK, w.Code)
+
+	req.Header.Set("X-DATE", requestTime.Add(-time.Hour).Format(http.TimeFormat))
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnauthorized, w.Code, "the date is read from X-DATE")
 }
 
 func TestHttpValidRequestBody(t *testing.T) {
@@ -302,3 +315,1068 @@ func TestHttpValidRequestHost(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, body, []byte(sampleBodyContent))
 }
+
+func TestVerify(t *testing.T) {
+	auth := NewAuthenticator(secrets, WithValidator(mockValidator...))
+
+	req, err := http.NewRequest("POST", "/", strings.NewReader(sampleBodyContent))
+	require.NoError(t, err)
+	req.Header.Set(authorizationHeader, generateSignature(readID, algoHmacSha512, submitHeader, requestBodySig))
+	req.Header.Set("Date", requestTime.Format(http.TimeFormat))
+	req.Header.Set("Digest", requestBodyDigest)
+	assert.NoError(t, auth.Verify(req))
+
+	req.Header.Set(authorizationHeader, generateSignature(readID, algoHmacSha512, submitHeader, requestNilBodySig))
+	err = auth.Verify(req)
+	var verr *VerifyError
+	require.True(t, errors.As(err, &verr))
+	assert.Equal(t, http.StatusUnauthorized, verr.StatusCode)
+	assert.True(t, errors.Is(err, ErrInvalidSign))
+
+	req.Header.Del(authorizationHeader)
+	err = auth.Verify(req)
+	require.True(t, errors.As(err, &verr))
+	assert.Equal(t, http.StatusUnauthorized, verr.StatusCode)
+	assert.True(t, errors.Is(err, ErrNoSignature))
+}
+
+func TestHttpBodyPreservedAfterDigest(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	type payload struct {
+		Name string `json:"name"`
+	}
+
+	r := gin.New()
+	auth := NewAuthenticator(secrets)
+	r.Use(auth.Authenticated())
+	r.POST("/", func(c *gin.Context) {
+		var p payload
+		if err := c.ShouldBindJSON(&p); err != nil {
+			c.AbortWithStatus(http.StatusUnprocessableEntity)
+			return
+		}
+		c.String(http.StatusOK, p.Name)
+	})
+
+	req, err := http.NewRequest("POST", "/", strings.NewReader(`{"name":"kyber"}`))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/json")
+	require.NoError(t, NewSigner(readID, secrets[readID], nil).Sign(req))
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "kyber", w.Body.String())
+}
+
+func TestStrictAlgorithm(t *testing.T) {
+	newRequest := func(algorithm string) *http.Request {
+		req, err := http.NewRequest("GET", "/", nil)
+		require.NoError(t, err)
+		req.Header.Set(authorizationHeader, generateSignature(readID, algorithm, submitHeader, requestEmptyBodySig))
+		req.Header.Set("Date", requestTime.Format(http.TimeFormat))
+		req.Header.Set("Digest", requestBodyEmptyDigest)
+		return req
+	}
+
+	auth := NewAuthenticator(secrets, WithValidator(&dateAlwaysValid{}))
+	assert.NoError(t, auth.Verify(newRequest("")))
+	assert.NoError(t, auth.Verify(newRequest(algoHmacSha512)))
+
+	auth = NewAuthenticator(secrets, WithValidator(&dateAlwaysValid{}), WithStrictAlgorithm(true))
+	assert.True(t, errors.Is(auth.Verify(newRequest("")), ErrIncorrectAlgorithm))
+	assert.True(t, errors.Is(auth.Verify(newRequest("hmac-sha256")), ErrIncorrectAlgorithm))
+	assert.NoError(t, auth.Verify(newRequest(algoHmacSha512)))
+}
+
+func TestAllowedAlgorithms(t *testing.T) {
+	legacy := Secrets{readID: &Secret{Key: "1234", Algorithm: &crypto.HmacSha1{}}}
+	headers := []string{requestTarget, date}
+	newRequest := func(secrets Secrets, algorithm string) *http.Request {
+		req, err := http.NewRequest("GET", "/", nil)
+		require.NoError(t, err)
+		require.NoError(t, NewSigner(readID, secrets[readID], headers).Sign(req))
+		if algorithm == "" {
+			sig := req.Header.Get(signatureHeader)
+			req.Header.Set(signatureHeader, strings.Replace(sig, `algorithm="`+secrets[readID].Algorithm.Name()+`",`, "", 1))
+		}
+		return req
+	}
+	options := []Option{WithRequiredHeaders(headers), WithNoValidators()}
+
+	assert.NoError(t, NewAuthenticator(legacy, options...).Verify(newRequest(legacy, "hmac-sha1")), "all the algorithms are allowed by default")
+
+	auth := NewAuthenticator(legacy, append(options, WithAllowedAlgorithms("HMAC-SHA256", algoHmacSha512))...)
+	for _, algorithm := range []string{"hmac-sha1", ""} {
+		err := auth.Verify(newRequest(legacy, algorithm))
+		assert.True(t, errors.Is(err, ErrAlgorithmNotAllowed), algorithm)
+		assert.Equal(t, ReasonAlgorithmNotAllowed, err.(*VerifyError).Reason, algorithm)
+	}
+
+	auth = NewAuthenticator(secrets, append(options, WithAllowedAlgorithms("hmac-sha256", algoHmacSha512))...)
+	assert.NoError(t, auth.Verify(newRequest(secrets, algoHmacSha512)))
+	assert.NoError(t, auth.Verify(newRequest(secrets, "")))
+	auth = NewAuthenticator(secrets, append(options, WithAllowedAlgorithms("hmac-sha256"))...)
+	assert.True(t, errors.Is(auth.Verify(newRequest(secrets, algoHmacSha512)), ErrAlgorithmNotAllowed))
+}
+
+func TestNoValidators(t *testing.T) {
+	assert.Len(t, NewAuthenticator(secrets).validators, 2)
+	assert.Len(t, NewAuthenticator(secrets, WithValidator()).validators, 2)
+	assert.Len(t, NewAuthenticator(secrets, WithValidator([]validator.Validator{}...)).validators, 2)
+	assert.Len(t, NewAuthenticator(secrets, WithValidator(&dateAlwaysValid{})).validators, 1)
+
+	headers := WithRequiredHeaders([]string{requestTarget, date})
+	auth := NewAuthenticator(secrets, WithNoValidators(), headers)
+	assert.NotNil(t, auth.validators)
+	assert.Empty(t, auth.validators)
+
+	req, err := http.NewRequest("GET", "/", nil)
+	require.NoError(t, err)
+	require.NoError(t, NewSigner(readID, secrets[readID], []string{requestTarget, date}).Sign(req))
+	assert.Error(t, NewAuthenticator(secrets, headers).Verify(req), "the digest is missing")
+	assert.NoError(t, auth.Verify(req))
+
+	auth = NewAuthenticator(secrets, WithNoValidators(), WithValidator(&dateAlwaysValid{}))
+	assert.Len(t, auth.validators, 1, "the last option wins")
+}
+
+func TestKeyIDResolver(t *testing.T) {
+	headers := []string{requestTarget, date}
+	fromHeader := func(r *http.Request) (KeyID, bool) {
+		keyID := r.Header.Get("X-Key-Id")
+		return KeyID(keyID), keyID != ""
+	}
+	newRequest := func(keyID KeyID, header string) *http.Request {
+		req, err := http.NewRequest("GET", "/", nil)
+		require.NoError(t, err)
+		require.NoError(t, NewSigner(keyID, secrets[keyID], headers).Sign(req))
+		if header != "" {
+			req.Header.Set("X-Key-Id", header)
+		}
+		return req
+	}
+	// withoutKeyID removes the keyId parameter of the signature of req
+	withoutKeyID := func(req *http.Request) *http.Request {
+		sig := req.Header.Get(signatureHeader)
+		req.Header.Set(signatureHeader, sig[strings.Index(sig, ",")+1:])
+		return req
+	}
+
+	auth := NewAuthenticator(secrets, WithRequiredHeaders(headers), WithValidator(&dateAlwaysValid{}), WithKeyIDResolver(fromHeader))
+	sigHeaders, err := auth.verify(context.Background(), withoutKeyID(newRequest(writeID, "write")))
+	require.NoError(t, err)
+	assert.Equal(t, writeID, sigHeaders[0].keyID, "the keyID is read from X-Key-Id")
+	assert.NoError(t, auth.Verify(newRequest(readID, "read")), "the keyIDs match")
+	assert.NoError(t, auth.Verify(newRequest(readID, "")), "the keyId of the signature is the fallback")
+
+	err = auth.Verify(newRequest(readID, "write"))
+	assert.True(t, errors.Is(err, ErrKeyIDMismatch))
+	assert.Equal(t, ReasonMalformedSignature, err.(*VerifyError).Reason)
+	assert.True(t, errors.Is(auth.Verify(withoutKeyID(newRequest(readID, ""))), ErrMissingKeyID))
+	assert.True(t, errors.Is(auth.Verify(withoutKeyID(newRequest(readID, "write"))), ErrInvalidSign), "the signature is not the one of write")
+
+	auth = NewAuthenticator(secrets, WithRequiredHeaders(headers), WithValidator(&dateAlwaysValid{}))
+	assert.True(t, errors.Is(auth.Verify(withoutKeyID(newRequest(readID, "read"))), ErrMissingKeyID), "X-Key-Id is ignored without resolver")
+}
+
+func TestQueryParams(t *testing.T) {
+	headers := []string{date, QueryParamHeader("amount"), QueryParamHeader("to")}
+	auth := NewAuthenticator(secrets,
+		WithValidator(&dateAlwaysValid{}),
+		WithRequiredHeaders([]string{date}),
+		WithRequiredQueryParams("amount", "to"),
+	)
+
+	req, err := http.NewRequest("GET", "/transfer?to=alice%20smith&amount=10&amount=20", nil)
+	require.NoError(t, err)
+	require.NoError(t, NewSigner(readID, secrets[readID], headers).Sign(req))
+
+	signString, err := constructSignMessage(req, &SignatureHeader{headers: headers})
+	require.NoError(t, err)
+	assert.Contains(t, signString, "(query-param:amount): 10\n(query-param:amount): 20\n(query-param:to): alice smith")
+	assert.NoError(t, auth.Verify(req))
+
+	// Re-encoding the query string does not change the signed values
+	req.URL.RawQuery = "amount=10&to=alice+smith&amount=20"
+	assert.NoError(t, auth.Verify(req))
+
+	req.URL.RawQuery = "to=mallory&amount=10&amount=20"
+	assert.True(t, errors.Is(auth.Verify(req), ErrInvalidSign))
+	req.URL.RawQuery = "to=alice+smith&amount=10%2C+20"
+	assert.True(t, errors.Is(auth.Verify(req), ErrInvalidSign), "the repeated values are not joined")
+	req.URL.RawQuery = "to=alice+smith&amount=20&amount=10"
+	assert.True(t, errors.Is(auth.Verify(req), ErrInvalidSign), "the order of the values is signed")
+
+	req, err = http.NewRequest("GET", "/transfer?to=alice&amount=10", nil)
+	require.NoError(t, err)
+	require.NoError(t, NewSigner(readID, secrets[readID], []string{date, QueryParamHeader("to")}).Sign(req))
+	assert.True(t, errors.Is(auth.Verify(req), ErrHeaderNotEnough))
+}
+
+func TestMissingHeaders(t *testing.T) {
+	emptyRequest := httptest.NewRequest("GET", "/", nil)
+	a := NewAuthenticator(secrets, WithRequiredHeaders([]string{requestTarget, "Date", digest}))
+	assert.Empty(t, a.missingHeaders(emptyRequest, []string{"date", "digest", requestTarget, host}, false))
+	assert.Empty(t, a.missingHeaders(emptyRequest, []string{"DATE", "Digest", requestTarget}, false))
+	assert.Equal(t, []string{digest}, a.missingHeaders(emptyRequest, []string{"date", requestTarget}, false))
+	assert.Equal(t, []string{requestTarget, date, digest}, a.missingHeaders(emptyRequest, nil, false))
+	assert.Empty(t, a.missingHeaders(emptyRequest, []string{"date", requestTarget}, true))
+
+	req, err := http.NewRequest("GET", "/", nil)
+	require.NoError(t, err)
+	require.NoError(t, NewSigner(readID, secrets[readID], []string{host}).Sign(req))
+	err = NewAuthenticator(secrets, WithRequiredHeaders([]string{requestTarget, "Date", host}), WithNoValidators()).Verify(req)
+	var missing *MissingHeadersError
+	require.True(t, errors.As(err, &missing))
+	assert.Equal(t, []string{requestTarget, date}, missing.Missing)
+	assert.True(t, errors.Is(err, ErrHeaderNotEnough))
+	assert.EqualError(t, err, "Header field is not match requirement: (request-target), date")
+}
+
+func TestHeadersRequiredIfPresent(t *testing.T) {
+	contentType := "content-type"
+	withContentType := append(append([]string{}, defaultRequiredHeaders...), contentType)
+	var tests = []struct {
+		name    string
+		options []Option
+	}{
+		{name: "if present", options: []Option{WithHeadersRequiredIfPresent("Content-Type")}},
+		{name: "required if present", options: []Option{WithRequiredHeaders(withContentType), WithHeadersRequiredIfPresent("Content-Type")}},
+	}
+	for _, tc := range tests {
+		auth := NewAuthenticator(secrets, append([]Option{WithValidator(&dateAlwaysValid{}, validator.NewDigestValidator())}, tc.options...)...)
+
+		req, err := http.NewRequest("POST", "/", strings.NewReader(sampleBodyContent))
+		require.NoError(t, err, tc.name)
+		req.Header.Set("Content-Type", "application/json")
+		require.NoError(t, NewSigner(readID, secrets[readID], nil).Sign(req), tc.name)
+		err = auth.Verify(req)
+		var missing *MissingHeadersError
+		require.True(t, errors.As(err, &missing), tc.name)
+		assert.Equal(t, []string{contentType}, missing.Missing, tc.name)
+
+		require.NoError(t, NewSigner(readID, secrets[readID], withContentType).Sign(req), tc.name)
+		assert.NoError(t, auth.Verify(req), tc.name)
+		req.Header.Set("Content-Type", "text/plain")
+		assert.True(t, errors.Is(auth.Verify(req), ErrInvalidSign), "%s: the content type cannot be swapped", tc.name)
+
+		req, err = http.NewRequest("POST", "/", strings.NewReader(sampleBodyContent))
+		require.NoError(t, err, tc.name)
+		require.NoError(t, NewSigner(readID, secrets[readID], nil).Sign(req), tc.name)
+		assert.NoError(t, auth.Verify(req), "%s: requests without content type are accepted", tc.name)
+	}
+}
+
+func TestHeadersRequiredIfPresentPresence(t *testing.T) {
+	auth := NewAuthenticator(secrets, WithRequiredHeaders([]string{date}),
+		WithHeadersRequiredIfPresent("X-Tenant", "Host", "(created)", "@method"))
+	assert.Equal(t, []string{"x-tenant", host}, auth.ifPresentHeaders, "the pseudo headers are ignored")
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header["X-Tenant"] = []string{""}
+	assert.Equal(t, []string{"x-tenant", host}, auth.missingHeaders(req, []string{date}, false), "empty headers and the host are present")
+	assert.Empty(t, auth.missingHeaders(req, []string{date, "x-tenant", host}, false))
+
+	req.Header.Del("X-Tenant")
+	req.Host = ""
+	assert.Empty(t, auth.missingHeaders(req, []string{date}, false))
+}
+
+func BenchmarkMissingHeaders(b *testing.B) {
+	headers := make([]string, 100)
+	for i := range headers {
+		headers[i] = fmt.Sprintf("x-header-%d", i)
+	}
+	a := NewAuthenticator(secrets, WithRequiredHeaders(headers))
+	covered := make([]string, len(headers))
+	for i := range headers {
+		covered[i] = headers[len(headers)-1-i]
+	}
+
+	emptyRequest := httptest.NewRequest("GET", "/", nil)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if len(a.missingHeaders(emptyRequest, covered, false)) > 0 {
+			b.Fatal("required headers should be covered")
+		}
+	}
+}
+
+func TestLogger(t *testing.T) {
+	var buf bytes.Buffer
+	a := NewAuthenticator(secrets, WithLogger(log.New(&buf, "", 0)))
+	req, err := http.NewRequest("GET", "/", nil)
+	require.NoError(t, err)
+
+	assert.Error(t, a.Verify(req))
+	assert.Equal(t, "[ERROR] "+ErrNoSignature.Error()+"\n", buf.String())
+}
+
+func TestConstructSignString(t *testing.T) {
+	req, err := http.NewRequest("GET", "/foo?a=b", nil)
+	require.NoError(t, err)
+	req.Header.Set("Date", requestTime.Format(http.TimeFormat))
+
+	signString, err := ConstructSignString(req, []string{requestTarget, date})
+	require.NoError(t, err)
+	assert.Equal(t, "(request-target): get /foo?a=b\ndate: "+requestTime.Format(http.TimeFormat), signString)
+	_, err = ConstructSignString(req, []string{digest})
+	assert.True(t, errors.Is(err, ErrMissingRequiredHeader))
+	assert.True(t, errors.Is(err, ErrEmptyHeader), "the deprecated alias matches with errors.Is")
+	assert.NotEqual(t, ErrEmptyHeader, err, "the error names the header")
+	assert.Equal(t, "Missing required header: digest", err.Error())
+
+	assert.Equal(t, "Empty required header", ErrEmptyHeader.Error(), "the deprecated error keeps its message")
+	assert.NotSame(t, ErrMissingRequiredHeader, ErrEmptyHeader)
+	assert.True(t, errors.Is(ErrEmptyHeader, ErrMissingRequiredHeader))
+	assert.False(t, errors.Is(ErrMissingRequiredHeader, ErrEmptyHeader))
+}
+
+func TestSignDebug(t *testing.T) {
+	headers := []string{requestTarget, date}
+	req, err := http.NewRequest("GET", "/foo", nil)
+	require.NoError(t, err)
+	require.NoError(t, NewSigner(readID, secrets[writeID], headers).Sign(req))
+	signString, err := ConstructSignString(req, headers)
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	options := []Option{WithRequiredHeaders(headers), WithValidator(&dateAlwaysValid{}), WithLogger(log.New(&buf, "", 0))}
+	assert.Error(t, NewAuthenticator(secrets, options...).Verify(req))
+	assert.NotContains(t, buf.String(), "[DEBUG]", "the signing string is only logged in debug mode")
+
+	buf.Reset()
+	assert.Error(t, NewAuthenticator(secrets, append(options, WithDebug(true))...).Verify(req))
+	assert.Contains(t, buf.String(), "[DEBUG] keyId=read algorithm=hmac-sha512")
+	assert.Contains(t, buf.String(), fmt.Sprintf("expected signing string=%q", signString))
+
+	buf.Reset()
+	require.NoError(t, NewSigner(readID, secrets[readID], headers).Sign(req))
+	assert.NoError(t, NewAuthenticator(secrets, append(options, WithDebug(true))...).Verify(req))
+	assert.Empty(t, buf.String())
+}
+
+func TestDebugFormatJSON(t *testing.T) {
+	headers := []string{requestTarget, date}
+	// keys which cannot appear in the timestamps of the log
+	debugSecrets := Secrets{readID: &Secret{Key: "debugReadSecretKey", Algorithm: hmacsha512}}
+	wrong := &Secret{Key: "debugWrongSecretKey", Algorithm: hmacsha512}
+	req, err := http.NewRequest("GET", "/foo", nil)
+	require.NoError(t, err)
+	require.NoError(t, NewSigner(readID, wrong, headers).Sign(req))
+	s, err := NewSignatureHeader(req)
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	auth := NewAuthenticator(debugSecrets, WithRequiredHeaders(headers), WithValidator(&dateAlwaysValid{}),
+		WithLogger(log.New(&buf, "", 0)), WithDebug(true), WithDebugFormat(JSON))
+	assert.Error(t, auth.Verify(req))
+	assert.NotContains(t, buf.String(), s.signature, "the signature is redacted")
+	assert.NotContains(t, buf.String(), debugSecrets[readID].Key)
+	assert.NotContains(t, buf.String(), wrong.Key)
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Len(t, lines, 2)
+	var debug, failure map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &debug))
+	require.NoError(t, json.Unmarshal([]byte(lines[1]), &failure))
+	assert.Equal(t, "debug", debug["level"])
+	assert.Equal(t, "error", failure["level"])
+	for _, entry := range []map[string]interface{}{debug, failure} {
+		assert.NotEmpty(t, entry["time"])
+		assert.Equal(t, string(ReasonBadSignature), entry["reason"])
+		assert.Equal(t, string(readID), entry["keyID"])
+		assert.Equal(t, "/foo", entry["path"])
+		assert.Equal(t, ErrInvalidSign.Error(), entry["error"])
+	}
+}
+
+func TestSigningFailed(t *testing.T) {
+	ed25519Seed := "AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA="
+	broken := &Secret{Key: "not a key", Algorithm: &crypto.Ed25519{}}
+
+	req, err := http.NewRequest("GET", "/", nil)
+	require.NoError(t, err)
+	err = NewSigner(readID, broken, []string{date}).Sign(req)
+	assert.True(t, errors.Is(err, ErrSigningFailed))
+	assert.True(t, errors.Is(err, crypto.ErrInvalidKey))
+	assert.Contains(t, err.Error(), "sign failed for keyID read")
+
+	var buf bytes.Buffer
+	auth := NewAuthenticator(Secrets{readID: broken}, WithRequiredHeaders([]string{date}),
+		WithValidator(&dateAlwaysValid{}), WithLogger(log.New(&buf, "", 0)))
+	req, err = http.NewRequest("GET", "/", nil)
+	require.NoError(t, err)
+	require.NoError(t, NewSigner(readID, &Secret{Key: ed25519Seed, Algorithm: &crypto.Ed25519{}}, []string{date}).Sign(req))
+
+	err = auth.Verify(req)
+	var verr *VerifyError
+	require.True(t, errors.As(err, &verr))
+	assert.Equal(t, ReasonInternal, verr.Reason)
+	assert.Equal(t, http.StatusInternalServerError, verr.StatusCode)
+	assert.True(t, errors.Is(err, ErrSigningFailed))
+	assert.True(t, errors.Is(err, crypto.ErrInvalidKey))
+	assert.Contains(t, buf.String(), "[ERROR] verify failed for keyID read")
+
+	gin.SetMode(gin.TestMode)
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = req
+	auth.Authenticated()(c)
+	assert.Equal(t, http.StatusInternalServerError, c.Writer.Status())
+	require.Len(t, c.Errors, 1)
+	assert.True(t, c.Errors[0].IsType(gin.ErrorTypePrivate), "the key error is not exposed")
+
+	w := httptest.NewRecorder()
+	auth.Middleware(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {})).ServeHTTP(w, req)
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.NotContains(t, w.Body.String(), "keyID")
+}
+
+func TestFailureReason(t *testing.T) {
+	auth := NewAuthenticator(secrets, WithValidator(mockValidator...))
+
+	var tests = []struct {
+		name          string
+		authorization string
+		reason        FailureReason
+	}{
+		{name: "no signature", reason: ReasonMissingSignature},
+		{name: "malformed", authorization: `Signature keyId="read`, reason: ReasonMalformedSignature},
+		{name: "missing header", authorization: generateSignature(readID, algoHmacSha512, []string{date}, requestBodySig), reason: ReasonMissingHeader},
+		{name: "unknown key", authorization: generateSignature("unknown", algoHmacSha512, submitHeader, requestBodySig), reason: ReasonUnknownKeyID},
+		{name: "wrong algorithm", authorization: generateSignature(readID, "hmac-sha256", submitHeader, requestBodySig), reason: ReasonAlgorithmMismatch},
+		{name: "bad signature", authorization: generateSignature(readID, algoHmacSha512, submitHeader, requestNilBodySig), reason: ReasonBadSignature},
+	}
+	for _, test := range tests {
+		req, err := http.NewRequest("POST", "/", strings.NewReader(sampleBodyContent))
+		require.NoError(t, err)
+		if test.authorization != "" {
+			req.Header.Set(authorizationHeader, test.authorization)
+		}
+		req.Header.Set("Date", requestTime.Format(http.TimeFormat))
+		req.Header.Set("Digest", requestBodyDigest)
+
+		var verr *VerifyError
+		require.True(t, errors.As(auth.Verify(req), &verr), test.name)
+		assert.Equal(t, test.reason, verr.Reason, test.name)
+	}
+
+	req, err := http.NewRequest("GET", "/", nil)
+	require.NoError(t, err)
+	req.Header.Set("Date", "not a date")
+	req.Header.Set(authorizationHeader, generateSignature(readID, algoHmacSha512, submitHeader, requestBodySig))
+	c := runTest(secrets, requiredHeaders, []validator.Validator{validator.NewDateValidator()}, req)
+	assert.Equal(t, ReasonExpiredDate, c.Errors[0].Meta)
+}
+
+func TestFailureReasonConcurrent(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	auth := NewAuthenticator(secrets, WithValidator(mockValidator...))
+
+	var tests = []struct {
+		authorization string
+		sentinel      *gin.Error
+		reason        FailureReason
+	}{
+		{sentinel: ErrNoSignature, reason: ReasonMissingSignature},
+		{authorization: generateSignature("unknown", algoHmacSha512, submitHeader, requestBodySig), sentinel: ErrInvalidKeyID, reason: ReasonUnknownKeyID},
+		{authorization: generateSignature(readID, algoHmacSha512, submitHeader, requestNilBodySig), sentinel: ErrInvalidSign, reason: ReasonBadSignature},
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 30; i++ {
+		test := tests[i%len(tests)]
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			req := httptest.NewRequest("POST", "/", strings.NewReader(sampleBodyContent))
+			if test.authorization != "" {
+				req.Header.Set(authorizationHeader, test.authorization)
+			}
+			req.Header.Set("Date", requestTime.Format(http.TimeFormat))
+			req.Header.Set("Digest", requestBodyDigest)
+
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request = req
+			auth.Authenticated()(c)
+			if assert.Len(t, c.Errors, 1) {
+				assert.Equal(t, test.sentinel.Err, c.Errors[0].Err)
+				assert.Equal(t, test.reason, c.Errors[0].Meta)
+			}
+		}()
+	}
+	wg.Wait()
+	for _, test := range tests {
+		assert.Nil(t, test.sentinel.Meta, "the sentinels are not changed")
+	}
+}
+
+func TestDigestRequiredForBody(t *testing.T) {
+	signer := NewSigner(readID, secrets[readID], []string{requestTarget, date})
+	validators := []validator.Validator{&dateAlwaysValid{}, validator.NewDigestValidator()}
+
+	get, err := http.NewRequest("GET", "/", nil)
+	require.NoError(t, err)
+	require.NoError(t, signer.Sign(get))
+	post, err := http.NewRequest("POST", "/", strings.NewReader(sampleBodyContent))
+	require.NoError(t, err)
+	require.NoError(t, signer.Sign(post))
+
+	auth := NewAuthenticator(secrets, WithValidator(validators...))
+	assert.Error(t, auth.Verify(get))
+	assert.Error(t, auth.Verify(post))
+
+	auth = NewAuthenticator(secrets, WithValidator(validators...), WithDigestRequiredForBody(true))
+	assert.NoError(t, auth.Verify(get))
+	assert.Error(t, auth.Verify(post))
+
+	get.Header.Set(digest, requestBodyDigest)
+	assert.Error(t, auth.Verify(get), "a digest header is still validated")
+
+	chunked := httptest.NewRequest("POST", "/", strings.NewReader(sampleBodyContent))
+	chunked.ContentLength = -1
+	chunked.TransferEncoding = []string{"chunked"}
+	assert.True(t, hasBody(chunked))
+	assert.False(t, hasBody(httptest.NewRequest("GET", "/", nil)))
+}
+
+func TestCoveredDigestHeader(t *testing.T) {
+	auth := NewAuthenticator(secrets, WithRequiredHeaders([]string{requestTarget, date}), WithRequireDigestForBody(true),
+		WithValidator(&dateAlwaysValid{}, validator.NewDigestValidator(validator.WithCoveredDigestHeader())))
+	newRequest := func(headers []string, options ...SignerOption) *http.Request {
+		req, err := http.NewRequest("POST", "/", strings.NewReader(sampleBodyContent))
+		require.NoError(t, err)
+		require.NoError(t, NewSigner(readID, secrets[readID], headers, options...).Sign(req))
+		return req
+	}
+
+	assert.NoError(t, auth.Verify(newRequest([]string{requestTarget, date, digest})))
+	assert.NoError(t, auth.Verify(newRequest([]string{requestTarget, date, contentDigest}, WithSignerDigestHeader("Content-Digest"))))
+
+	req := newRequest([]string{requestTarget, date, contentDigest}, WithSignerDigestHeader("Content-Digest"))
+	req.Header.Set("Digest", requestBodyFalseDigest)
+	assert.NoError(t, auth.Verify(req), "the Digest header is not covered")
+	req.Header.Set("Content-Digest", requestBodyFalseDigest)
+	assert.True(t, errors.Is(auth.Verify(req), validator.ErrInvalidDigest))
+
+	assert.True(t, errors.Is(auth.Verify(newRequest([]string{requestTarget, date})), ErrHeaderNotEnough))
+}
+
+func TestAuthenticatedWithHeaders(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	r := gin.New()
+	auth := NewAuthenticator(secrets, WithValidator(&dateAlwaysValid{}))
+	r.GET("/default", auth.Authenticated(), httpTestGet)
+	r.GET("/light", auth.AuthenticatedWithHeaders([]string{requestTarget}), httpTestGet)
+
+	for _, path := range []string{"/default", "/light"} {
+		req, err := http.NewRequest("GET", path, nil)
+		require.NoError(t, err)
+		require.NoError(t, NewSigner(readID, secrets[readID], []string{requestTarget}).Sign(req))
+
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if path == "/light" {
+			assert.Equal(t, http.StatusOK, w.Code, path)
+		} else {
+			assert.Equal(t, http.StatusUnauthorized, w.Code, path)
+		}
+	}
+	assert.Equal(t, defaultRequiredHeaders, auth.headers, "the Authenticator is not modified")
+}
+
+func TestErrorStatusMapper(t *testing.T) {
+	req, err := http.NewRequest("GET", "/", nil)
+	require.NoError(t, err)
+	req.Header.Set(authorizationHeader, generateSignature("unknown", algoHmacSha512, submitHeader, requestEmptyBodySig))
+	req.Header.Set("Date", requestTime.Format(http.TimeFormat))
+	req.Header.Set("Digest", requestBodyEmptyDigest)
+
+	auth := NewAuthenticator(secrets, WithValidator(&dateAlwaysValid{}))
+	assert.Equal(t, http.StatusUnauthorized, StatusCode(auth.Verify(req)))
+
+	auth = NewAuthenticator(secrets, WithValidator(&dateAlwaysValid{}), WithErrorStatusMapper(func(reason FailureReason) int {
+		if reason == ReasonUnknownKeyID {
+			return http.StatusForbidden
+		}
+		return DefaultErrorStatus(reason)
+	}))
+	assert.Equal(t, http.StatusForbidden, StatusCode(auth.Verify(req)))
+
+	assert.Equal(t, http.StatusInternalServerError, DefaultErrorStatus(ReasonInternal))
+	assert.Equal(t, http.StatusUnauthorized, DefaultErrorStatus(ReasonMalformedSignature))
+	assert.Equal(t, http.StatusRequestEntityTooLarge, DefaultErrorStatus(ReasonBodyTooLarge))
+}
+
+func TestVerifySignatureOnly(t *testing.T) {
+	auth := NewAuthenticator(secrets)
+
+	req, err := http.NewRequest("POST", "/", strings.NewReader(sampleBodyContent))
+	require.NoError(t, err)
+	req.Header.Set("Date", time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat))
+	require.NoError(t, NewSigner(readID, secrets[readID], nil).Sign(req))
+	req.Body = ioutil.NopCloser(strings.NewReader("tampered"))
+
+	assert.True(t, errors.Is(auth.Verify(req), validator.ErrDateNotInRange))
+	assert.NoError(t, auth.VerifySignatureOnly(req), "the date and the digest are not checked")
+
+	headers := []string{requestTarget, date, expires}
+	expired := &SignatureHeader{keyID: readID, algorithm: algoHmacSha512, headers: headers, expires: time.Now().Add(-time.Minute)}
+	signString, err := constructSignMessage(req, expired)
+	require.NoError(t, err)
+	signature, err := secrets[readID].Algorithm.Sign(signString, secrets[readID].Key)
+	require.NoError(t, err)
+	expired.signature = Base64.encode(signature)
+	req.Header.Set(signatureHeader, expired.String())
+	assert.NoError(t, NewAuthenticator(secrets, WithRequiredHeaders(headers)).VerifySignatureOnly(req), "the expires parameter is ignored")
+	assert.True(t, errors.Is(auth.VerifySignatureOnly(req), ErrHeaderNotEnough), "the required headers are checked")
+
+	require.NoError(t, NewSigner(readID, secrets[writeID], nil).Sign(req))
+	assert.True(t, errors.Is(auth.VerifySignatureOnly(req), ErrInvalidSign))
+}
+
+// recordingValidator appends its name to calls
+type recordingValidator struct {
+	calls *[]string
+}
+
+func (v recordingValidator) Validate(_ *http.Request) error {
+	*v.calls = append(*v.calls, "validator")
+	return nil
+}
+
+func TestBeforeVerify(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	var calls []string
+	hook := func(name string, err error) func(c *gin.Context) error {
+		return func(c *gin.Context) error {
+			calls = append(calls, name)
+			c.Set("tenant", "acme")
+			return err
+		}
+	}
+	var meta interface{}
+	headers := []string{requestTarget, date}
+	newRouter := func(options ...Option) *gin.Engine {
+		auth := NewAuthenticator(secrets, append([]Option{WithRequiredHeaders(headers),
+			WithValidator(recordingValidator{calls: &calls})}, options...)...)
+		r := gin.New()
+		r.Use(func(c *gin.Context) {
+			c.Next()
+			if gerr := c.Errors.Last(); gerr != nil {
+				meta = gerr.Meta
+			}
+		})
+		r.GET("/", auth.Authenticated(), func(c *gin.Context) {
+			assert.Equal(t, "acme", c.GetString("tenant"))
+			c.Status(http.StatusOK)
+		})
+		return r
+	}
+	req, err := http.NewRequest("GET", "/", nil)
+	require.NoError(t, err)
+	require.NoError(t, NewSigner(readID, secrets[readID], headers).Sign(req))
+
+	w := httptest.NewRecorder()
+	newRouter(WithBeforeVerify(hook("first", nil)), WithBeforeVerify(hook("second", nil))).ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, []string{"first", "second", "validator"}, calls, "the hooks run in order before the validators")
+
+	var tests = []struct {
+		name    string
+		err     error
+		options []Option
+		status  int
+		reason  FailureReason
+	}{
+		{name: "error", err: errors.New("unknown tenant"), status: http.StatusUnauthorized, reason: ReasonRejected},
+		{name: "mapped error", err: errors.New("unknown tenant"), status: http.StatusForbidden, reason: ReasonRejected,
+			options: []Option{WithErrorStatusMapper(func(reason FailureReason) int {
+				if reason == ReasonRejected {
+					return http.StatusForbidden
+				}
+				return DefaultErrorStatus(reason)
+			})}},
+		{name: "verify error", err: newVerifyError(ReasonTLSRequired, ErrTLSRequired), status: http.StatusUnauthorized, reason: ReasonTLSRequired},
+	}
+	for _, tc := range tests {
+		calls = nil
+		r := newRouter(append(tc.options, WithBeforeVerify(hook("failing", tc.err)), WithBeforeVerify(hook("skipped", nil)))...)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		assert.Equal(t, tc.status, w.Code, tc.name)
+		assert.Equal(t, tc.reason, meta, tc.name)
+		assert.Equal(t, []string{"failing"}, calls, tc.name)
+	}
+
+	shared := newVerifyError(ReasonTLSRequired, ErrTLSRequired)
+	w = httptest.NewRecorder()
+	newRouter(WithBeforeVerify(hook("shared", shared))).ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Zero(t, shared.StatusCode, "the error of the hook is not changed")
+}
+
+type contextValidator struct {
+	ctx context.Context
+}
+
+func (v *contextValidator) Validate(r *http.Request) error {
+	return errors.New("Validate must not be called")
+}
+
+func (v *contextValidator) ValidateContext(ctx context.Context, r *http.Request) error {
+	v.ctx = ctx
+	return nil
+}
+
+func TestVerifyContext(t *testing.T) {
+	v := &contextValidator{}
+	auth := NewAuthenticator(secrets, WithValidator(v))
+
+	req, err := http.NewRequest("GET", "/", nil)
+	require.NoError(t, err)
+	require.NoError(t, NewSigner(readID, secrets[readID], nil).Sign(req))
+
+	type key struct{}
+	ctx := context.WithValue(context.Background(), key{}, "value")
+	assert.NoError(t, auth.VerifyContext(ctx, req))
+	assert.Equal(t, ctx, v.ctx)
+
+	ctx, cancel := context.WithCancel(ctx)
+	cancel()
+	err = auth.VerifyContext(ctx, req)
+	assert.True(t, errors.Is(err, context.Canceled))
+	assert.Equal(t, http.StatusInternalServerError, StatusCode(err))
+}
+
+func TestMaxCoveredHeaders(t *testing.T) {
+	newRequest := func(n int) *http.Request {
+		headers := append([]string{}, submitHeader...)
+		for len(headers) < n {
+			headers = append(headers, fmt.Sprintf("x-header-%d", len(headers)))
+		}
+		req, err := http.NewRequest("GET", "/", nil)
+		require.NoError(t, err)
+		req.Header.Set(authorizationHeader, generateSignature(readID, algoHmacSha512, headers, requestEmptyBodySig))
+		return req
+	}
+
+	auth := NewAuthenticator(secrets, WithValidator(&dateAlwaysValid{}))
+	err := auth.Verify(newRequest(65))
+	assert.True(t, errors.Is(err, ErrTooManyHeaders))
+	assert.False(t, errors.Is(auth.Verify(newRequest(64)), ErrTooManyHeaders))
+
+	auth = NewAuthenticator(secrets, WithValidator(&dateAlwaysValid{}), WithMaxCoveredHeaders(3))
+	assert.True(t, errors.Is(auth.Verify(newRequest(4)), ErrTooManyHeaders))
+
+	auth = NewAuthenticator(secrets, WithValidator(&dateAlwaysValid{}), WithMaxCoveredHeaders(0))
+	assert.False(t, errors.Is(auth.Verify(newRequest(1000)), ErrTooManyHeaders))
+}
+
+type recordingMetrics struct {
+	successes []KeyID
+	failures  []FailureReason
+}
+
+func (m *recordingMetrics) OnSuccess(keyID KeyID, dur time.Duration) {
+	m.successes = append(m.successes, keyID)
+}
+
+func (m *recordingMetrics) OnFailure(reason FailureReason, dur time.Duration) {
+	m.failures = append(m.failures, reason)
+}
+
+func TestMetrics(t *testing.T) {
+	metrics := &recordingMetrics{}
+	auth := NewAuthenticator(secrets, WithValidator(&dateAlwaysValid{}), WithMetrics(metrics))
+
+	req, err := http.NewRequest("GET", "/", nil)
+	require.NoError(t, err)
+	assert.Error(t, auth.Verify(req))
+	require.NoError(t, NewSigner(readID, secrets[readID], nil).Sign(req))
+	assert.NoError(t, auth.Verify(req))
+
+	assert.Equal(t, []KeyID{readID}, metrics.successes)
+	assert.Equal(t, []FailureReason{ReasonMissingSignature}, metrics.failures)
+}
+
+func TestMissingRequiredHeader(t *testing.T) {
+	req, err := http.NewRequest("GET", "/", nil)
+	require.NoError(t, err)
+	req.Header.Set(authorizationHeader, generateSignature(readID, algoHmacSha512, []string{requestTarget, date}, requestEmptyBodySig))
+
+	auth := NewAuthenticator(secrets, WithValidator(validator.NewDateValidator()), WithRequiredHeaders([]string{date}))
+	err = auth.Verify(req)
+	assert.True(t, errors.Is(err, ErrMissingRequiredHeader))
+	assert.EqualError(t, err, "Missing required header: date")
+	var verr *VerifyError
+	require.True(t, errors.As(err, &verr))
+	assert.Equal(t, ReasonMissingHeader, verr.Reason)
+
+	req.Header.Set(authorizationHeader, generateSignature(readID, algoHmacSha512, []string{date, "x-request-id"}, requestEmptyBodySig))
+	req.Header.Set("Date", time.Now().UTC().Format(http.TimeFormat))
+	err = auth.Verify(req)
+	assert.True(t, errors.Is(err, ErrMissingRequiredHeader))
+	assert.EqualError(t, err, "Missing required header: x-request-id")
+}
+
+func TestRequestTargetResolver(t *testing.T) {
+	req, err := http.NewRequest("GET", "http://example.com/api/v1/items?page=2", nil)
+	require.NoError(t, err)
+	require.NoError(t, NewSigner(readID, secrets[readID], []string{requestTarget, date}).Sign(req))
+
+	// The proxy strips the /api/v1 prefix
+	req.URL.Path = "/items"
+	req.Header.Set("X-Forwarded-Prefix", "/api/v1")
+	resolve := func(r *http.Request) string {
+		return r.Header.Get("X-Forwarded-Prefix") + r.URL.RequestURI()
+	}
+
+	required := WithRequiredHeaders([]string{requestTarget, date})
+	assert.True(t, errors.Is(NewAuthenticator(secrets, WithValidator(&dateAlwaysValid{}), required).Verify(req), ErrInvalidSign))
+	auth := NewAuthenticator(secrets, WithValidator(&dateAlwaysValid{}), required, WithRequestTargetResolver(resolve))
+	assert.NoError(t, auth.Verify(req))
+	assert.Equal(t, "/items", req.URL.Path, "the request must not be modified")
+
+	req.Header.Set("X-Forwarded-Prefix", "api")
+	err = auth.Verify(req)
+	assert.True(t, errors.Is(err, ErrInvalidRequestTarget))
+	var verr *VerifyError
+	require.True(t, errors.As(err, &verr))
+	assert.Equal(t, ReasonBadSignature, verr.Reason)
+}
+
+func TestCustomDigestHeader(t *testing.T) {
+	auth := NewAuthenticator(secrets, WithValidator(&dateAlwaysValid{}, validator.NewDigestValidatorWithHeader("Content-Digest")))
+	assert.Equal(t, []string{requestTarget, date, "content-digest"}, auth.headers)
+
+	req, err := http.NewRequest("POST", "/", strings.NewReader(sampleBodyContent))
+	require.NoError(t, err)
+	signer := NewSigner(readID, secrets[readID], auth.headers, WithSignerDigestHeader("Content-Digest"))
+	require.NoError(t, signer.Sign(req))
+	assert.NotEmpty(t, req.Header.Get("Content-Digest"))
+	assert.Empty(t, req.Header.Get("Digest"))
+	assert.NoError(t, auth.Verify(req))
+
+	req.Header.Set("Content-Digest", "SHA-256=fakeDigest=")
+	var verr *VerifyError
+	require.True(t, errors.As(auth.Verify(req), &verr))
+	assert.Equal(t, ReasonBadDigest, verr.Reason)
+
+	auth = NewAuthenticator(secrets, WithDigestRequiredForBody(true), WithRequiredHeaders([]string{date, "content-digest"}),
+		WithValidator(&dateAlwaysValid{}, validator.NewDigestValidatorWithHeader("Content-Digest")))
+	req, err = http.NewRequest("GET", "/", nil)
+	require.NoError(t, err)
+	require.NoError(t, NewSigner(readID, secrets[readID], []string{date}).Sign(req))
+	assert.NoError(t, auth.Verify(req), "bodiless requests do not need the content-digest")
+}
+
+func TestCanonicalizedDigest(t *testing.T) {
+	auth := NewAuthenticator(secrets, WithValidator(&dateAlwaysValid{},
+		validator.NewDigestValidator(validator.WithBodyCanonicalizer(validator.CanonicalizeJSON))))
+
+	req, err := http.NewRequest("POST", "/", strings.NewReader(`{"b": [1, 2], "a": "x"}`))
+	require.NoError(t, err)
+	signer := NewSigner(readID, secrets[readID], auth.headers, WithSignerBodyCanonicalizer(validator.CanonicalizeJSON))
+	require.NoError(t, signer.Sign(req))
+	assert.NoError(t, auth.Verify(req))
+
+	req.Body = ioutil.NopCloser(strings.NewReader(`{"a":"x","b":[1,2]}`))
+	assert.NoError(t, auth.Verify(req), "the same JSON serialized differently")
+
+	req.Body = ioutil.NopCloser(strings.NewReader(`{"a":"y","b":[1,2]}`))
+	var verr *VerifyError
+	require.True(t, errors.As(auth.Verify(req), &verr))
+	assert.Equal(t, ReasonBadDigest, verr.Reason)
+}
+
+func BenchmarkConstructSignMessage(b *testing.B) {
+	req, err := http.NewRequest("POST", "/foo?param=value", strings.NewReader(sampleBodyContent))
+	if err != nil {
+		b.Fatal(err)
+	}
+	req.Header.Set("Date", "Mon, 22 Oct 2018 07:00:07 GMT")
+	req.Header.Set("Digest", "SHA-256=uU0nuZNNPgilLlLX2n2r+sSE7+N6U4DukIj3rOLvzek=")
+	sigHeader := &SignatureHeader{headers: []string{requestTarget, host, date, digest}}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := constructSignMessage(req, sigHeader); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkVerify(b *testing.B) {
+	req, err := http.NewRequest("GET", "/foo", nil)
+	if err != nil {
+		b.Fatal(err)
+	}
+	if err := NewSigner(readID, secrets[readID], []string{requestTarget, host, date}).Sign(req); err != nil {
+		b.Fatal(err)
+	}
+	auth := NewAuthenticator(secrets, WithValidator(&dateAlwaysValid{}), WithRequiredHeaders([]string{requestTarget, date}))
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := auth.Verify(req); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestRequireTLS(t *testing.T) {
+	newRequest := func(tls bool, proto string) *http.Request {
+		target := "http://example.com/"
+		if tls {
+			target = "https://example.com/"
+		}
+		req := httptest.NewRequest("GET", target, nil)
+		if proto != "" {
+			req.Header.Set("X-Forwarded-Proto", proto)
+		}
+		require.NoError(t, NewSigner(readID, secrets[readID], nil).Sign(req))
+		return req
+	}
+
+	var tests = []struct {
+		name   string
+		trust  bool
+		tls    bool
+		proto  string
+		reject bool
+	}{
+		{name: "tls", tls: true},
+		{name: "plaintext", reject: true},
+		{name: "untrusted forwarded proto", proto: "https", reject: true},
+		{name: "trusted forwarded proto", trust: true, proto: "HTTPS"},
+		{name: "trusted forwarded http", trust: true, proto: "http", reject: true},
+	}
+	for _, tc := range tests {
+		auth := NewAuthenticator(secrets, WithValidator(&dateAlwaysValid{}),
+			WithRequireTLS(true), WithTrustForwardedProto(tc.trust))
+		err := auth.Verify(newRequest(tc.tls, tc.proto))
+		if !tc.reject {
+			assert.NoError(t, err, tc.name)
+			continue
+		}
+		var verr *VerifyError
+		require.True(t, errors.As(err, &verr), tc.name)
+		assert.Equal(t, ReasonTLSRequired, verr.Reason, tc.name)
+		assert.Equal(t, ErrTLSRequired, verr.Err, tc.name)
+	}
+
+	req := httptest.NewRequest("GET", "http://example.com/", nil)
+	err := NewAuthenticator(secrets, WithRequireTLS(true)).Verify(req)
+	assert.True(t, errors.Is(err, ErrTLSRequired), "checked before the signature")
+}
+
+func TestSkipper(t *testing.T) {
+	auth := NewAuthenticator(secrets, WithSkipPaths("/metrics"), WithSkipper(func(r *http.Request) bool {
+		return r.Method == http.MethodOptions
+	}))
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(auth.Authenticated())
+	r.GET("/metrics", httpTestGet)
+	r.GET("/", httpTestGet)
+	r.OPTIONS("/", httpTestGet)
+
+	for _, req := range []*http.Request{httptest.NewRequest("GET", "/metrics", nil), httptest.NewRequest("OPTIONS", "/", nil)} {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code, req.Method+" "+req.URL.Path)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	assert.Error(t, auth.Verify(httptest.NewRequest("GET", "/metrics", nil)), "Verify does not skip")
+}
+
+func TestMandatoryHeaders(t *testing.T) {
+	auth := NewAuthenticator(secrets, WithValidator(&dateAlwaysValid{}), WithRequiredHeaders([]string{host}), WithMandatoryHeaders(requestTarget, "Date"))
+
+	req, err := http.NewRequest("GET", "/", nil)
+	require.NoError(t, err)
+	require.NoError(t, NewSigner(readID, secrets[readID], []string{host, date}).Sign(req))
+	err = auth.Verify(req)
+	assert.True(t, errors.Is(err, ErrHeaderNotEnough))
+	assert.EqualError(t, err, "Header field is not match requirement: (request-target)")
+
+	require.NoError(t, NewSigner(readID, secrets[readID], []string{host, requestTarget, date}).Sign(req))
+	assert.NoError(t, auth.Verify(req))
+
+	// The routes overriding the required headers keep the mandatory ones
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(auth.AuthenticatedWithHeaders([]string{host}))
+	r.GET("/", httpTestGet)
+	req = httptest.NewRequest("GET", "/", nil)
+	require.NoError(t, NewSigner(readID, secrets[readID], []string{host, requestTarget}).Sign(req))
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
+
+func TestHmacAlgorithmsDistinguished(t *testing.T) {
+	sha384Secrets := Secrets{readID: &Secret{Key: "1234", Algorithm: &crypto.HmacSha384{}}}
+	auth := NewAuthenticator(sha384Secrets, WithValidator(&dateAlwaysValid{}), WithRequiredHeaders([]string{date}))
+
+	req, err := http.NewRequest("GET", "/", nil)
+	require.NoError(t, err)
+	require.NoError(t, NewSigner(readID, sha384Secrets[readID], []string{date}).Sign(req))
+	assert.NoError(t, auth.Verify(req))
+
+	for _, algorithm := range []crypto.Crypto{&crypto.HmacSha256{}, &crypto.HmacSha512{}} {
+		require.NoError(t, NewSigner(readID, &Secret{Key: "1234", Algorithm: algorithm}, []string{date}).Sign(req))
+		assert.True(t, errors.Is(auth.Verify(req), ErrIncorrectAlgorithm), algorithm.Name())
+	}
+}
+
+func TestRequireDigestForBody(t *testing.T) {
+	auth := NewAuthenticator(secrets, WithRequiredHeaders([]string{date}), WithRequireDigestForBody(true),
+		WithValidator(&dateAlwaysValid{}, validator.NewDigestValidator()))
+
+	req, err := http.NewRequest("POST", "/", strings.NewReader(sampleBodyContent))
+	require.NoError(t, err)
+	require.NoError(t, NewSigner(readID, secrets[readID], []string{date}).Sign(req))
+	err = auth.Verify(req)
+	assert.True(t, errors.Is(err, ErrHeaderNotEnough))
+	assert.EqualError(t, err, "Header field is not match requirement: digest")
+
+	require.NoError(t, NewSigner(readID, secrets[readID], []string{date, digest}).Sign(req))
+	assert.NoError(t, auth.Verify(req))
+
+	req, err = http.NewRequest("GET", "/", nil)
+	require.NoError(t, err)
+	require.NoError(t, NewSigner(readID, secrets[readID], []string{date}).Sign(req))
+	assert.NoError(t, NewAuthenticator(secrets, WithRequiredHeaders([]string{date}), WithRequireDigestForBody(true),
+		WithValidator(&dateAlwaysValid{})).Verify(req), "bodiless requests do not need the digest")
+}
+
+func TestCreatedValidator(t *testing.T) {
+	auth := NewAuthenticator(secrets, WithRequiredHeaders([]string{created}),
+		WithValidator(validator.NewCreatedValidator(time.Minute, 5*time.Second)))
+
+	req, err := http.NewRequest("GET", "/", nil)
+	require.NoError(t, err)
+	require.NoError(t, NewSigner(readID, secrets[readID], []string{created}).Sign(req))
+	assert.NoError(t, auth.Verify(req))
+
+	s, err := NewSignatureHeader(req)
+	require.NoError(t, err)
+	s.created = time.Now().Add(-2 * time.Minute)
+	req.Header.Set(signatureHeader, s.String())
+	var verr *VerifyError
+	require.True(t, errors.As(auth.Verify(req), &verr))
+	assert.Equal(t, ReasonExpiredSignature, verr.Reason)
+	assert.Equal(t, validator.ErrCreatedNotInRange, verr.Err)
+}
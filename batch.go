@@ -0,0 +1,76 @@
+package httpsign
+
+import (
+	"context"
+	"net/http"
+	"runtime"
+	"sync"
+
+	"github.com/stremovskyy/httpsign/validator"
+)
+
+// BatchMode selects the checks run by VerifyBatch.
+type BatchMode int
+
+const (
+	// LiveMode verifies the requests as Verify does.
+	LiveMode BatchMode = iota
+	// HistoricalMode verifies previously captured requests: the checks of the
+	// time of the verification are skipped, i.e. the date, created and
+	// nonce validators, the expires parameter and the FailureLimiter. The
+	// nonces are neither checked nor stored.
+	HistoricalMode
+)
+
+type historicalKey struct{}
+
+// isHistorical reports whether ctx verifies requests in HistoricalMode
+func isHistorical(ctx context.Context) bool {
+	historical, _ := ctx.Value(historicalKey{}).(bool)
+	return historical
+}
+
+// isTimeValidator reports whether v checks the request against the time of
+// the verification, skipped in HistoricalMode
+func isTimeValidator(v validator.Validator) bool {
+	switch v.(type) {
+	case *validator.DateValidator, *validator.CreatedValidator, *validator.NonceValidator:
+		return true
+	}
+	return false
+}
+
+// VerifyBatch verifies reqs, e.g. captured requests re-verified by audit
+// tooling, and returns the error of each request, nil for the valid ones.
+// The requests are verified concurrently by GOMAXPROCS workers sharing the
+// signing buffers and the secrets of the Authenticator. It is not meant for
+// the request path, use Authenticated or Verify there.
+func (a *Authenticator) VerifyBatch(reqs []*http.Request, mode BatchMode) []error {
+	errs := make([]error, len(reqs))
+	workers := runtime.GOMAXPROCS(0)
+	if workers > len(reqs) {
+		workers = len(reqs)
+	}
+
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for w := 0; w < workers; w++ {
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				ctx := reqs[i].Context()
+				if mode == HistoricalMode {
+					ctx = context.WithValue(ctx, historicalKey{}, true)
+				}
+				_, errs[i] = a.verifyRequest(ctx, reqs[i])
+			}
+		}()
+	}
+	for i := range reqs {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+	return errs
+}
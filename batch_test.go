@@ -0,0 +1,61 @@
+package httpsign
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stremovskyy/httpsign/validator"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVerifyBatch(t *testing.T) {
+	headers := []string{requestTarget, date, created, expires}
+	signer := NewSigner(readID, secrets[readID], headers, WithSignatureExpiry(time.Millisecond))
+	newRequest := func(captured time.Time) *http.Request {
+		req, err := http.NewRequest("GET", "/", nil)
+		require.NoError(t, err)
+		req.Header.Set("Date", captured.Format(http.TimeFormat))
+		require.NoError(t, signer.Sign(req))
+		return req
+	}
+
+	captured := newRequest(requestTime)
+	time.Sleep(10 * time.Millisecond)
+	tampered := newRequest(requestTime)
+	tampered.URL.Path = "/admin"
+	reqs := []*http.Request{captured, tampered, newRequest(time.Now())}
+
+	auth := NewAuthenticator(secrets, WithRequiredHeaders(headers), WithValidator(validator.NewDateValidator()))
+	errs := auth.VerifyBatch(reqs, LiveMode)
+	require.Len(t, errs, len(reqs))
+	assert.True(t, errors.Is(errs[0], ErrSignatureExpired), "the signature expired")
+	assert.Error(t, errs[1])
+	assert.True(t, errors.Is(errs[2], ErrSignatureExpired))
+
+	errs = auth.VerifyBatch(reqs, HistoricalMode)
+	require.Len(t, errs, len(reqs))
+	assert.NoError(t, errs[0], "the date and expires are not checked")
+	assert.True(t, errors.Is(errs[1], ErrInvalidSign))
+	assert.NoError(t, errs[2])
+
+	assert.Empty(t, auth.VerifyBatch(nil, HistoricalMode))
+}
+
+func TestVerifyBatchNonces(t *testing.T) {
+	headers := []string{requestTarget, date, "nonce"}
+	store := validator.NewMemoryNonceStore()
+	auth := NewAuthenticator(secrets, WithRequiredHeaders(headers), WithValidator(validator.NewNonceValidator(store)))
+
+	req, err := http.NewRequest("GET", "/", nil)
+	require.NoError(t, err)
+	req.Header.Set("nonce", "n1")
+	require.NoError(t, NewSigner(readID, secrets[readID], headers).Sign(req))
+
+	reqs := []*http.Request{req, req}
+	assert.Equal(t, []error{nil, nil}, auth.VerifyBatch(reqs, HistoricalMode), "the nonces are not checked")
+	assert.NoError(t, auth.Verify(req), "the nonces are not stored")
+	assert.Error(t, auth.Verify(req))
+}
@@ -0,0 +1,111 @@
+package httpsign
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Canonicalizer builds the string signed for the fields covered by a
+// signature, the wire format of which depends on the signature format.
+type Canonicalizer interface {
+	// Canonicalize returns the signing string of the fields of r covered by
+	// sigHeader.
+	Canonicalize(r *http.Request, sigHeader *SignatureHeader) (string, error)
+}
+
+// CavageCanonicalizer builds the signing string of draft-cavage-http-signatures:
+// a field: value line for each covered field, separated by \n. The values of
+// the (created) and (expires) pseudo headers are the parameters of the
+// signature. Header values are normalized, see headerValue.
+type CavageCanonicalizer struct {
+	// Strict uses the header values byte for byte, see WithStrictHeaderValues
+	Strict bool
+	// RequestTargetMode selects the parts of the request URI in
+	// (request-target), see WithRequestTargetMode
+	RequestTargetMode RequestTargetMode
+	// MethodCase is the case of the method in (request-target), see
+	// WithRequestTargetMethodCase
+	MethodCase MethodCase
+}
+
+// Canonicalize returns the signing string of the fields covered by sigHeader.
+func (c CavageCanonicalizer) Canonicalize(r *http.Request, sigHeader *SignatureHeader) (string, error) {
+	signBuffer := getSignBuffer()
+	defer putSignBuffer(signBuffer)
+
+	headers := sigHeader.headers
+	for i, field := range headers {
+		var fieldValue string
+		// the covered names are case-insensitive, e.g. Date or
+		// (Request-Target), but written as listed
+		switch name := strings.ToLower(field); name {
+		case host:
+			fieldValue = r.Host
+		case requestTarget:
+			method := r.Method
+			if c.MethodCase == Lower {
+				method = strings.ToLower(method)
+			}
+			fieldValue = fmt.Sprintf("%s %s", method, requestTargetURI(r, c.RequestTargetMode))
+		case created, expires:
+			ts := sigHeader.created
+			if name == expires {
+				ts = sigHeader.expires
+			}
+			if ts.IsZero() {
+				return "", newMissingHeaderError(field)
+			}
+			fieldValue = strconv.FormatInt(ts.Unix(), 10)
+		default:
+			if name, ok := queryParamName(field); ok {
+				// Each value is on its own line, like the @query-param of
+				// RFC 9421, so that the value "a, b" is not signed like the
+				// values a and b
+				values := r.URL.Query()[name]
+				for ; len(values) > 1; values = values[1:] {
+					signBuffer.WriteString(field)
+					signBuffer.WriteString(": ")
+					signBuffer.WriteString(values[0])
+					signBuffer.WriteString("\n")
+				}
+				if len(values) == 1 {
+					fieldValue = values[0]
+				}
+				break
+			}
+			if name, ok := trailerName(field); ok {
+				fieldValue = headerValue(r.Trailer, name, c.Strict)
+				if fieldValue == "" {
+					return "", newMissingHeaderError(field)
+				}
+				break
+			}
+			fieldValue = headerValue(r.Header, field, c.Strict)
+			if fieldValue == "" {
+				return "", newMissingHeaderError(field)
+			}
+		}
+		signBuffer.WriteString(field)
+		signBuffer.WriteString(": ")
+		signBuffer.WriteString(fieldValue)
+		if i < len(headers)-1 {
+			signBuffer.WriteString("\n")
+		}
+	}
+
+	return signBuffer.String(), nil
+}
+
+// newCanonicalizer returns the Canonicalizer of the signatures of format,
+// cavage for the Cavage and JWS formats
+func newCanonicalizer(format SignatureFormat, cavage CavageCanonicalizer) Canonicalizer {
+	switch format {
+	case RFC9421:
+		return RFC9421Canonicalizer{}
+	case JWS:
+		return jwsCanonicalizer{payload: cavage}
+	}
+	return cavage
+}
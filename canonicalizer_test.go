@@ -0,0 +1,111 @@
+package httpsign
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stremovskyy/httpsign/validator"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCanonicalizers(t *testing.T) {
+	req, err := http.NewRequest("POST", "https://example.com/foo?a=b", nil)
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", " application/json ")
+	req.Header.Set("Date", requestTime.Format(http.TimeFormat))
+
+	tests := []struct {
+		name          string
+		canonicalizer Canonicalizer
+		sigHeader     *SignatureHeader
+		want          string
+	}{
+		{
+			name:          "cavage",
+			canonicalizer: CavageCanonicalizer{},
+			sigHeader:     &SignatureHeader{headers: []string{requestTarget, created, "content-type"}, created: time.Unix(1618884473, 0)},
+			want:          "(request-target): post /foo?a=b\n(created): 1618884473\ncontent-type: application/json",
+		},
+		{
+			name:          "cavage strict path only",
+			canonicalizer: CavageCanonicalizer{Strict: true, RequestTargetMode: PathOnly},
+			sigHeader:     &SignatureHeader{headers: []string{requestTarget, "content-type"}},
+			want:          "(request-target): post /foo\ncontent-type:  application/json ",
+		},
+		{
+			name:          "rfc9421",
+			canonicalizer: RFC9421Canonicalizer{},
+			sigHeader:     &SignatureHeader{headers: []string{componentMethod, componentPath, "content-type"}, params: `("@method" "@path" "content-type");keyid="k"`},
+			want:          "\"@method\": POST\n\"@path\": /foo\n\"content-type\": application/json\n\"@signature-params\": (\"@method\" \"@path\" \"content-type\");keyid=\"k\"",
+		},
+		{
+			name:          "jws",
+			canonicalizer: jwsCanonicalizer{},
+			sigHeader:     &SignatureHeader{headers: []string{date}, params: "e30", unencoded: true},
+			want:          "e30.date: " + requestTime.Format(http.TimeFormat),
+		},
+	}
+	for _, tc := range tests {
+		got, err := tc.canonicalizer.Canonicalize(req, tc.sigHeader)
+		require.NoError(t, err, tc.name)
+		assert.Equal(t, tc.want, got, tc.name)
+	}
+
+	_, err = RFC9421Canonicalizer{}.Canonicalize(req, &SignatureHeader{headers: []string{"Content-Type"}})
+	assert.Equal(t, ErrUnsupportedComponent, err)
+	_, err = CavageCanonicalizer{}.Canonicalize(req, &SignatureHeader{headers: []string{digest}})
+	assert.True(t, errors.Is(err, ErrMissingRequiredHeader))
+}
+
+// upperCanonicalizer is a Canonicalizer of a hypothetical format upper
+// casing the cavage signing string
+type upperCanonicalizer struct{}
+
+func (upperCanonicalizer) Canonicalize(r *http.Request, sigHeader *SignatureHeader) (string, error) {
+	s, err := CavageCanonicalizer{}.Canonicalize(r, sigHeader)
+	return strings.ToUpper(s), err
+}
+
+func TestWithCanonicalizer(t *testing.T) {
+	assert.Equal(t, CavageCanonicalizer{Strict: true}, NewAuthenticator(secrets, WithStrictHeaderValues(true)).canonicalizer)
+	assert.Equal(t, RFC9421Canonicalizer{}, NewAuthenticator(secrets, WithSignatureFormat(RFC9421)).canonicalizer)
+
+	req, err := http.NewRequest("GET", "/foo", nil)
+	require.NoError(t, err)
+	req.Header.Set("Date", requestTime.Format(http.TimeFormat))
+	sigHeader := &SignatureHeader{keyID: readID, headers: []string{requestTarget, date}}
+	signString, err := upperCanonicalizer{}.Canonicalize(req, sigHeader)
+	require.NoError(t, err)
+	signature, err := hmacsha512.Sign(signString, secrets[readID].Key)
+	require.NoError(t, err)
+	sigHeader.signature = Base64.encode(signature)
+	req.Header.Set(signatureHeader, sigHeader.String())
+
+	headers := WithRequiredHeaders([]string{requestTarget, date})
+	assert.True(t, errors.Is(NewAuthenticator(secrets, headers, WithNoValidators()).Verify(req), ErrInvalidSign))
+	assert.NoError(t, NewAuthenticator(secrets, headers, WithNoValidators(), WithCanonicalizer(upperCanonicalizer{})).Verify(req))
+}
+
+func TestMixedCaseCoveredHeaders(t *testing.T) {
+	req, err := http.NewRequest("POST", "https://example.com/foo?a=b", strings.NewReader(sampleBodyContent))
+	require.NoError(t, err)
+	headers := []string{"(Request-Target)", "Host", "Date", "Digest", "(Created)"}
+	require.NoError(t, NewSigner(readID, secrets[readID], headers).Sign(req))
+	assert.NotEmpty(t, req.Header.Get("Date"))
+	assert.Equal(t, requestBodyDigest, req.Header.Get("Digest"))
+
+	s, err := NewSignatureHeader(req)
+	require.NoError(t, err)
+	signString, err := constructSignMessage(req, s)
+	require.NoError(t, err)
+	assert.Equal(t, fmt.Sprintf("(Request-Target): post /foo?a=b\nHost: example.com\nDate: %s\nDigest: %s\n(Created): %d",
+		req.Header.Get("Date"), requestBodyDigest, s.created.Unix()), signString)
+
+	auth := NewAuthenticator(secrets, WithValidator(&dateAlwaysValid{}, validator.NewDigestValidator()))
+	assert.NoError(t, auth.Verify(req))
+}
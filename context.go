@@ -0,0 +1,128 @@
+package httpsign
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Keys of the gin context values set by Authenticated for verified requests
+const (
+	// ContextKeyAuthenticated is whether the signature of the request is
+	// valid, it is also set to false by OptionalAuthenticated
+	ContextKeyAuthenticated = "httpsign.authenticated"
+	// ContextKeyKeyID is the KeyID which signed the request
+	ContextKeyKeyID = "httpsign.keyID"
+	// ContextKeyAlgorithm is the name of the algorithm of the signature
+	ContextKeyAlgorithm = "httpsign.algorithm"
+	// ContextKeyHeaders is the list of headers covered by the signature
+	ContextKeyHeaders = "httpsign.headers"
+	// ContextKeyLabels is the list of the labels of the verified RFC 9421
+	// signatures
+	ContextKeyLabels = "httpsign.labels"
+	// ContextKeyVerifyDuration is the time.Duration the verification of the
+	// request took, it is also set for the requests failing it
+	ContextKeyVerifyDuration = "httpsign.verify_duration"
+)
+
+// verifyDurationHeader is the response header of the verification duration in
+// milliseconds, set in debug mode
+const verifyDurationHeader = "X-Signature-Verify-Ms"
+
+// setContextSignature sets the context values of the verified signatures,
+// the keyID, algorithm and headers are the ones of the first.
+func setContextSignature(c *gin.Context, sigHeaders []*SignatureHeader) {
+	var labels []string
+	for _, h := range sigHeaders {
+		if h.label != "" {
+			labels = append(labels, h.label)
+		}
+	}
+	if len(labels) > 0 {
+		c.Set(ContextKeyLabels, labels)
+	}
+
+	sigHeader := sigHeaders[0]
+	c.Set(ContextKeyAuthenticated, true)
+	c.Set(ContextKeyKeyID, sigHeader.keyID)
+	c.Set(ContextKeyAlgorithm, sigHeader.algorithm)
+	c.Set(ContextKeyHeaders, sigHeader.headers)
+}
+
+// IsAuthenticated reports whether the request has a valid signature, verified
+// by Authenticated or OptionalAuthenticated.
+func IsAuthenticated(c *gin.Context) bool {
+	return c.GetBool(ContextKeyAuthenticated)
+}
+
+// KeyIDFromContext returns the KeyID which signed the request verified by
+// Authenticated.
+func KeyIDFromContext(c *gin.Context) (KeyID, bool) {
+	v, ok := c.Get(ContextKeyKeyID)
+	if !ok {
+		return "", false
+	}
+	keyID, ok := v.(KeyID)
+	return keyID, ok
+}
+
+// AlgorithmFromContext returns the algorithm of the signature verified by
+// Authenticated.
+func AlgorithmFromContext(c *gin.Context) (string, bool) {
+	v, ok := c.Get(ContextKeyAlgorithm)
+	if !ok {
+		return "", false
+	}
+	algorithm, ok := v.(string)
+	return algorithm, ok
+}
+
+// HeadersFromContext returns the headers covered by the signature verified by
+// Authenticated.
+func HeadersFromContext(c *gin.Context) ([]string, bool) {
+	v, ok := c.Get(ContextKeyHeaders)
+	if !ok {
+		return nil, false
+	}
+	headers, ok := v.([]string)
+	return headers, ok
+}
+
+// LabelsFromContext returns the labels of the RFC 9421 signatures verified by
+// Authenticated, see WithSignaturePolicy.
+func LabelsFromContext(c *gin.Context) ([]string, bool) {
+	v, ok := c.Get(ContextKeyLabels)
+	if !ok {
+		return nil, false
+	}
+	labels, ok := v.([]string)
+	return labels, ok
+}
+
+// VerifyDurationFromContext returns the time the verification of the request
+// took in Authenticated or OptionalAuthenticated.
+func VerifyDurationFromContext(c *gin.Context) (time.Duration, bool) {
+	v, ok := c.Get(ContextKeyVerifyDuration)
+	if !ok {
+		return 0, false
+	}
+	d, ok := v.(time.Duration)
+	return d, ok
+}
+
+// setVerifyDuration sets the context value of the verification duration d,
+// and in debug mode the X-Signature-Verify-Ms response header.
+func (a *Authenticator) setVerifyDuration(c *gin.Context, d time.Duration) {
+	c.Set(ContextKeyVerifyDuration, d)
+	a.setVerifyDurationHeader(c.Writer.Header(), d)
+}
+
+// setVerifyDurationHeader sets the X-Signature-Verify-Ms header to d in debug
+// mode
+func (a *Authenticator) setVerifyDurationHeader(h http.Header, d time.Duration) {
+	if a.debug {
+		h.Set(verifyDurationHeader, strconv.FormatFloat(float64(d)/float64(time.Millisecond), 'f', 3, 64))
+	}
+}
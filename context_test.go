@@ -0,0 +1,136 @@
+package httpsign
+
+import (
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestContextSignature(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	var (
+		keyID          KeyID
+		algorithm      string
+		headers        []string
+		okK, okA, okH  bool
+		publicHasKeyID bool
+	)
+	r := gin.New()
+	r.GET("/public", func(c *gin.Context) {
+		_, publicHasKeyID = KeyIDFromContext(c)
+	})
+	r.GET("/", NewAuthenticator(secrets).Authenticated(), func(c *gin.Context) {
+		keyID, okK = KeyIDFromContext(c)
+		algorithm, okA = AlgorithmFromContext(c)
+		headers, okH = HeadersFromContext(c)
+	})
+
+	req, err := http.NewRequest("GET", "/", nil)
+	require.NoError(t, err)
+	require.NoError(t, NewSigner(writeID, secrets[writeID], nil).Sign(req))
+	r.ServeHTTP(httptest.NewRecorder(), req)
+
+	assert.True(t, okK && okA && okH)
+	assert.Equal(t, writeID, keyID)
+	assert.Equal(t, algoHmacSha512, algorithm)
+	assert.Equal(t, defaultRequiredHeaders, headers)
+
+	req, err = http.NewRequest("GET", "/public", nil)
+	require.NoError(t, err)
+	r.ServeHTTP(httptest.NewRecorder(), req)
+	assert.False(t, publicHasKeyID)
+}
+
+func TestOptionalAuthenticated(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	var (
+		authenticated, hasFlag bool
+		keyID                  KeyID
+		hasKeyID               bool
+	)
+	r := gin.New()
+	r.GET("/", NewAuthenticator(secrets).OptionalAuthenticated(), func(c *gin.Context) {
+		_, hasFlag = c.Get(ContextKeyAuthenticated)
+		authenticated = IsAuthenticated(c)
+		keyID, hasKeyID = KeyIDFromContext(c)
+		c.Status(http.StatusOK)
+	})
+
+	signed, err := http.NewRequest("GET", "/", nil)
+	require.NoError(t, err)
+	require.NoError(t, NewSigner(writeID, secrets[writeID], nil).Sign(signed))
+	invalid, err := http.NewRequest("GET", "/", nil)
+	require.NoError(t, err)
+	require.NoError(t, NewSigner(writeID, &Secret{Key: "wrong", Algorithm: secrets[writeID].Algorithm}, nil).Sign(invalid))
+	unsigned, err := http.NewRequest("GET", "/", nil)
+	require.NoError(t, err)
+
+	var tests = []struct {
+		name          string
+		req           *http.Request
+		authenticated bool
+	}{
+		{name: "signed", req: signed, authenticated: true},
+		{name: "invalid signature", req: invalid},
+		{name: "unsigned", req: unsigned},
+	}
+	for _, tc := range tests {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, tc.req)
+		assert.Equal(t, http.StatusOK, w.Code, tc.name)
+		assert.True(t, hasFlag, tc.name)
+		assert.Equal(t, tc.authenticated, authenticated, tc.name)
+		assert.Equal(t, tc.authenticated, hasKeyID, tc.name)
+		if tc.authenticated {
+			assert.Equal(t, writeID, keyID, tc.name)
+		}
+	}
+}
+
+func TestVerifyDuration(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	for _, debug := range []bool{false, true} {
+		var (
+			d  time.Duration
+			ok bool
+		)
+		r := gin.New()
+		r.GET("/", NewAuthenticator(secrets, WithDebug(debug), WithLogger(log.New(ioutil.Discard, "", 0))).OptionalAuthenticated(), func(c *gin.Context) {
+			d, ok = VerifyDurationFromContext(c)
+		})
+
+		req, err := http.NewRequest("GET", "/", nil)
+		require.NoError(t, err)
+		require.NoError(t, NewSigner(writeID, secrets[writeID], nil).Sign(req))
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		assert.True(t, ok, "debug %t", debug)
+		assert.True(t, d > 0, "debug %t", debug)
+
+		header := w.Header().Get(verifyDurationHeader)
+		if !debug {
+			assert.Empty(t, header, "the header is only set in debug mode")
+			continue
+		}
+		ms, err := strconv.ParseFloat(header, 64)
+		require.NoError(t, err)
+		assert.InDelta(t, float64(d)/float64(time.Millisecond), ms, 0.001)
+	}
+
+	handler := NewAuthenticator(secrets, WithDebug(true), WithLogger(log.New(ioutil.Discard, "", 0))).Middleware(http.NotFoundHandler())
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.NotEmpty(t, w.Header().Get(verifyDurationHeader), "the header is set for the failures too")
+}
@@ -1,7 +1,21 @@
 package crypto
 
+import "errors"
+
+var (
+	// ErrInvalidKey error when the key could not be decoded for the algorithm
+	ErrInvalidKey = errors.New("invalid key")
+	// ErrInvalidSignature error when the signature does not match the message
+	ErrInvalidSignature = errors.New("invalid signature")
+)
+
 // Crypto interface for signing algorithim
 type Crypto interface {
 	Name() string
 	Sign(msg string, secret string) ([]byte, error)
+	// Verify return nil when signature is a valid signing of msg, or
+	// ErrInvalidSignature otherwise. Randomized algorithms could not be
+	// verified by signing msg again, so this is the only way the
+	// authenticator checks signatures.
+	Verify(msg string, signature []byte, secret string) error
 }
@@ -0,0 +1,99 @@
+package crypto
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	"fmt"
+	"hash"
+)
+
+const (
+	algoEcdsaSha256 = "ecdsa-sha256"
+	algoEcdsaSha384 = "ecdsa-sha384"
+)
+
+// EcdsaSha256 signing algorithm using ECDSA on the P-256 curve and sha256.
+// Signatures are ASN.1 encoded. The private key is a PEM encoded SEC 1 or
+// PKCS#8 EC key.
+type EcdsaSha256 struct {
+}
+
+// Sign return signing of input msg with the private key
+func (e *EcdsaSha256) Sign(msg string, secret string) ([]byte, error) {
+	return ecdsaSign(msg, secret, elliptic.P256(), sha256.New)
+}
+
+// Verify checks that signature is a valid signature of msg. The key could be
+// a PEM encoded public key, certificate or private key.
+func (e *EcdsaSha256) Verify(msg string, signature []byte, key string) error {
+	return ecdsaVerify(msg, signature, key, elliptic.P256(), sha256.New)
+}
+
+// Name return name of algorithim
+func (e *EcdsaSha256) Name() string {
+	return algoEcdsaSha256
+}
+
+// EcdsaSha384 signing algorithm using ECDSA on the P-384 curve and sha384.
+// Signatures are ASN.1 encoded. The private key is a PEM encoded SEC 1 or
+// PKCS#8 EC key.
+type EcdsaSha384 struct {
+}
+
+// Sign return signing of input msg with the private key
+func (e *EcdsaSha384) Sign(msg string, secret string) ([]byte, error) {
+	return ecdsaSign(msg, secret, elliptic.P384(), sha512.New384)
+}
+
+// Verify checks that signature is a valid signature of msg. The key could be
+// a PEM encoded public key, certificate or private key.
+func (e *EcdsaSha384) Verify(msg string, signature []byte, key string) error {
+	return ecdsaVerify(msg, signature, key, elliptic.P384(), sha512.New384)
+}
+
+// Name return name of algorithim
+func (e *EcdsaSha384) Name() string {
+	return algoEcdsaSha384
+}
+
+func ecdsaSign(msg string, secret string, curve elliptic.Curve, newHash func() hash.Hash) ([]byte, error) {
+	parsed, err := parsePrivateKey(secret)
+	if err != nil {
+		return nil, err
+	}
+	key, ok := parsed.(*ecdsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("%w: %T is not an ecdsa private key", ErrInvalidKey, parsed)
+	}
+	if key.Curve != curve {
+		return nil, fmt.Errorf("%w: ecdsa key must use curve %s", ErrInvalidKey, curve.Params().Name)
+	}
+
+	h := newHash()
+	h.Write([]byte(msg))
+	return ecdsa.SignASN1(rand.Reader, key, h.Sum(nil))
+}
+
+func ecdsaVerify(msg string, signature []byte, key string, curve elliptic.Curve, newHash func() hash.Hash) error {
+	parsed, err := parsePublicKey(key)
+	if err != nil {
+		return err
+	}
+	pub, ok := parsed.(*ecdsa.PublicKey)
+	if !ok {
+		return fmt.Errorf("%w: %T is not an ecdsa public key", ErrInvalidKey, parsed)
+	}
+	if pub.Curve != curve {
+		return fmt.Errorf("%w: ecdsa key must use curve %s", ErrInvalidKey, curve.Params().Name)
+	}
+
+	h := newHash()
+	h.Write([]byte(msg))
+	if !ecdsa.VerifyASN1(pub, h.Sum(nil), signature) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
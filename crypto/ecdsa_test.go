@@ -0,0 +1,57 @@
+package crypto
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func generateECKeyPEM(t *testing.T, curve elliptic.Curve) (string, string) {
+	key, err := ecdsa.GenerateKey(curve, rand.Reader)
+	require.NoError(t, err)
+	privDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+	pubDER, err := x509.MarshalPKIXPublicKey(key.Public())
+	require.NoError(t, err)
+	return string(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: privDER})),
+		string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pubDER}))
+}
+
+func TestEcdsaRoundTrip(t *testing.T) {
+	var tests = []struct {
+		name      string
+		algorithm interface {
+			Crypto
+			Verify(msg string, signature []byte, key string) error
+		}
+		curve      elliptic.Curve
+		otherCurve elliptic.Curve
+	}{
+		{name: "ecdsa-sha256", algorithm: &EcdsaSha256{}, curve: elliptic.P256(), otherCurve: elliptic.P384()},
+		{name: "ecdsa-sha384", algorithm: &EcdsaSha384{}, curve: elliptic.P384(), otherCurve: elliptic.P256()},
+	}
+
+	for _, tc := range tests {
+		assert.Equal(t, tc.name, tc.algorithm.Name())
+		privPEM, pubPEM := generateECKeyPEM(t, tc.curve)
+		msg := "date: Mon, 22 Oct 2018 07:00:07 GMT"
+
+		signature, err := tc.algorithm.Sign(msg, privPEM)
+		require.NoError(t, err, tc.name)
+		assert.NoError(t, tc.algorithm.Verify(msg, signature, pubPEM), tc.name)
+		assert.NoError(t, tc.algorithm.Verify(msg, signature, privPEM), tc.name)
+		assert.True(t, errors.Is(tc.algorithm.Verify(msg+"x", signature, pubPEM), ErrInvalidSignature), tc.name)
+
+		otherPriv, otherPub := generateECKeyPEM(t, tc.otherCurve)
+		_, err = tc.algorithm.Sign(msg, otherPriv)
+		assert.True(t, errors.Is(err, ErrInvalidKey), tc.name)
+		assert.True(t, errors.Is(tc.algorithm.Verify(msg, signature, otherPub), ErrInvalidKey), tc.name)
+	}
+}
@@ -0,0 +1,99 @@
+package crypto
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"strings"
+)
+
+const algoEd25519 = "ed25519"
+
+// Ed25519 signing algorithm using the Ed25519 signature scheme.
+// The private key could be a PKCS#8 PEM block or a base64 encoded
+// seed (32 bytes) or private key (64 bytes).
+type Ed25519 struct {
+}
+
+// Sign return signing of input msg with the private key
+func (e *Ed25519) Sign(msg string, secret string) ([]byte, error) {
+	key, err := parseEd25519PrivateKey(secret)
+	if err != nil {
+		return nil, err
+	}
+	return ed25519.Sign(key, []byte(msg)), nil
+}
+
+// Verify checks that signature is a valid signature of msg. The key could be a
+// public key (PKIX PEM block or base64 encoded 32 bytes) or a private key
+// accepted by Sign.
+func (e *Ed25519) Verify(msg string, signature []byte, key string) error {
+	pub, err := parseEd25519PublicKey(key)
+	if err != nil {
+		return err
+	}
+	if !ed25519.Verify(pub, []byte(msg), signature) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// Name return name of algorithim
+func (e *Ed25519) Name() string {
+	return algoEd25519
+}
+
+func parseEd25519PrivateKey(key string) (ed25519.PrivateKey, error) {
+	if block, _ := pem.Decode([]byte(key)); block != nil {
+		parsed, err := parsePrivateKey(key)
+		if err != nil {
+			return nil, err
+		}
+		priv, ok := parsed.(ed25519.PrivateKey)
+		if !ok {
+			return nil, fmt.Errorf("%w: %T is not an ed25519 private key", ErrInvalidKey, parsed)
+		}
+		return priv, nil
+	}
+
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(key))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidKey, err.Error())
+	}
+	switch len(raw) {
+	case ed25519.SeedSize:
+		return ed25519.NewKeyFromSeed(raw), nil
+	case ed25519.PrivateKeySize:
+		return ed25519.PrivateKey(raw), nil
+	}
+	return nil, fmt.Errorf("%w: ed25519 private key must be %d or %d bytes, got %d",
+		ErrInvalidKey, ed25519.SeedSize, ed25519.PrivateKeySize, len(raw))
+}
+
+func parseEd25519PublicKey(key string) (ed25519.PublicKey, error) {
+	if block, _ := pem.Decode([]byte(key)); block != nil {
+		parsed, err := parsePublicKey(key)
+		if err != nil {
+			return nil, err
+		}
+		pub, ok := parsed.(ed25519.PublicKey)
+		if !ok {
+			return nil, fmt.Errorf("%w: %T is not an ed25519 public key", ErrInvalidKey, parsed)
+		}
+		return pub, nil
+	}
+
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(key))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidKey, err.Error())
+	}
+	if len(raw) == ed25519.PublicKeySize {
+		return ed25519.PublicKey(raw), nil
+	}
+	priv, err := parseEd25519PrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+	return priv.Public().(ed25519.PublicKey), nil
+}
@@ -0,0 +1,60 @@
+//go:build go1.20
+
+package crypto
+
+import (
+	stdcrypto "crypto"
+	"crypto/ed25519"
+	"crypto/sha512"
+)
+
+const algoEd25519ph = "ed25519ph"
+
+func init() {
+	Register(algoEd25519ph, func() Crypto { return &Ed25519ph{} })
+}
+
+// Ed25519ph signing algorithm using the pre-hashed Ed25519 variant of RFC
+// 8032: the message is hashed with SHA-512, then the hash is signed. Its
+// signatures are not valid Ed25519 signatures, both sides must use it. The
+// keys are the ones of Ed25519.
+//
+// The signed message is the signing string, which covers the body through the
+// digest header and not the body itself, so the body is hashed once, by the
+// digest. Ed25519ph requires Go 1.20 or later.
+type Ed25519ph struct {
+}
+
+// Sign return signing of input msg with the private key
+func (e *Ed25519ph) Sign(msg string, secret string) ([]byte, error) {
+	key, err := parseEd25519PrivateKey(secret)
+	if err != nil {
+		return nil, err
+	}
+	h := sha512.Sum512([]byte(msg))
+	return key.Sign(nil, h[:], &ed25519.Options{Hash: stdcrypto.SHA512})
+}
+
+// Verify checks that signature is a valid signature of msg, see
+// Ed25519.Verify for the keys.
+func (e *Ed25519ph) Verify(msg string, signature []byte, key string) error {
+	pub, err := parseEd25519PublicKey(key)
+	if err != nil {
+		return err
+	}
+	h := sha512.Sum512([]byte(msg))
+	if ed25519.VerifyWithOptions(pub, h[:], signature, &ed25519.Options{Hash: stdcrypto.SHA512}) != nil {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// Name return name of algorithim
+func (e *Ed25519ph) Name() string {
+	return algoEd25519ph
+}
+
+func (e *Ed25519ph) checkKeyType(pub interface{}) bool {
+	_, ok := pub.(ed25519.PublicKey)
+	return ok
+}
@@ -0,0 +1,62 @@
+//go:build go1.20
+
+package crypto
+
+import (
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Test abc of RFC 8032 section 7.3
+const (
+	ed25519phSeed      = "833fe62409237b9d62ec77587520911e9a759cec1d19755b7da901b96dca3d42"
+	ed25519phPublicKey = "ec172b93ad5e563bf4932c70e1245034c35467ef2efd4d64ebf819683467e2bf"
+	ed25519phSignature = "98a70222f0b8121aa9d30f813d683f809e462b469c7ff87639499bb94e6dae4131f85042463c2a355a2003d062adf5aaa10b8c61e636062aaad11c2a26083406"
+)
+
+func TestEd25519phKnownVector(t *testing.T) {
+	e := &Ed25519ph{}
+	seed := base64.StdEncoding.EncodeToString(mustDecodeHex(t, ed25519phSeed))
+	pub := base64.StdEncoding.EncodeToString(mustDecodeHex(t, ed25519phPublicKey))
+
+	signature, err := e.Sign("abc", seed)
+	require.NoError(t, err)
+	assert.Equal(t, mustDecodeHex(t, ed25519phSignature), signature)
+	assert.NoError(t, e.Verify("abc", signature, pub))
+	assert.True(t, errors.Is(e.Verify("abd", signature, pub), ErrInvalidSignature))
+	assert.Equal(t, "ed25519ph", e.Name())
+}
+
+func TestEd25519phDistinctFromEd25519(t *testing.T) {
+	seed := base64.StdEncoding.EncodeToString(mustDecodeHex(t, ed25519phSeed))
+	msg := "(request-target): post /foo\ndigest: SHA-256=uU0nuZNNPgilLlLX2n2r+sSE7+N6U4DukIj3rOLvzek="
+
+	prehashed, err := (&Ed25519ph{}).Sign(msg, seed)
+	require.NoError(t, err)
+	plain, err := (&Ed25519{}).Sign(msg, seed)
+	require.NoError(t, err)
+	assert.True(t, errors.Is((&Ed25519{}).Verify(msg, prehashed, seed), ErrInvalidSignature))
+	assert.True(t, errors.Is((&Ed25519ph{}).Verify(msg, plain, seed), ErrInvalidSignature))
+
+	algorithm, err := Get("ed25519ph")
+	require.NoError(t, err)
+	assert.IsType(t, &Ed25519ph{}, algorithm)
+}
+
+func TestEd25519phCheckKeyType(t *testing.T) {
+	der, err := x509.MarshalPKCS8PrivateKey(ed25519.NewKeyFromSeed(mustDecodeHex(t, ed25519phSeed)))
+	require.NoError(t, err)
+	edPEM := string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}))
+	_, ecPub := generateECKeyPEM(t, elliptic.P256())
+
+	assert.NoError(t, CheckKeyType(&Ed25519ph{}, edPEM))
+	assert.True(t, errors.Is(CheckKeyType(&Ed25519ph{}, ecPub), ErrIncompatibleKey))
+}
@@ -0,0 +1,189 @@
+package crypto
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"hash"
+
+	"golang.org/x/crypto/pbkdf2"
+)
+
+// ErrIncorrectPassphrase error when an encrypted private key could not be
+// decrypted with the passphrase
+var ErrIncorrectPassphrase = errors.New("incorrect passphrase")
+
+var (
+	oidPBES2          = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 5, 13}
+	oidPBKDF2         = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 5, 12}
+	oidHMACWithSHA1   = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 7}
+	oidHMACWithSHA256 = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 9}
+	oidHMACWithSHA512 = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 11}
+	oidAES128CBC      = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 2}
+	oidAES192CBC      = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 22}
+	oidAES256CBC      = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 42}
+)
+
+// encryptedPrivateKeyInfo is the PKCS#8 EncryptedPrivateKeyInfo of RFC 5208
+type encryptedPrivateKeyInfo struct {
+	Algorithm     pkix.AlgorithmIdentifier
+	EncryptedData []byte
+}
+
+// pbes2Params are the PBES2 parameters of RFC 8018
+type pbes2Params struct {
+	KeyDerivationFunc pkix.AlgorithmIdentifier
+	EncryptionScheme  pkix.AlgorithmIdentifier
+}
+
+// pbkdf2Params are the PBKDF2 parameters of RFC 8018
+type pbkdf2Params struct {
+	Salt           []byte
+	IterationCount int
+	KeyLength      int                      `asn1:"optional"`
+	PRF            pkix.AlgorithmIdentifier `asn1:"optional"`
+}
+
+// DecryptPEM decrypts the PEM encoded private key pemBytes with passphrase
+// and returns it PEM encoded. PKCS#8 keys encrypted with PBES2 (PBKDF2 and
+// AES-CBC, the default of openssl) and the legacy encrypted PEM blocks with a
+// Proc-Type header are supported. Keys that are not encrypted are returned as
+// is. ErrIncorrectPassphrase is returned when passphrase does not decrypt the
+// key, ErrInvalidKey when pemBytes is not a supported key.
+func DecryptPEM(pemBytes []byte, passphrase string) ([]byte, error) {
+	block, _ := pem.Decode(pemBytes)
+	if block == nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidKey, errNoPEMBlock.Error())
+	}
+
+	switch {
+	case block.Type == "ENCRYPTED PRIVATE KEY":
+		der, err := decryptPKCS8(block.Bytes, []byte(passphrase))
+		if err != nil {
+			return nil, err
+		}
+		return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), nil
+	case x509.IsEncryptedPEMBlock(block):
+		// The legacy encryption is deprecated as insecure by design, see
+		// x509.DecryptPEMBlock, but it is still what many tools produce.
+		der, err := x509.DecryptPEMBlock(block, []byte(passphrase))
+		if err == x509.IncorrectPasswordError {
+			return nil, ErrIncorrectPassphrase
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidKey, err.Error())
+		}
+		// The padding check of DecryptPEMBlock does not always detect a wrong
+		// passphrase, the key would then not parse.
+		decrypted := pem.EncodeToMemory(&pem.Block{Type: block.Type, Bytes: der})
+		if _, err := parsePrivateKey(string(decrypted)); err != nil {
+			return nil, ErrIncorrectPassphrase
+		}
+		return decrypted, nil
+	}
+	return pemBytes, nil
+}
+
+// decryptPKCS8 returns the PKCS#8 private key of an EncryptedPrivateKeyInfo
+func decryptPKCS8(der []byte, passphrase []byte) ([]byte, error) {
+	var info encryptedPrivateKeyInfo
+	if _, err := asn1.Unmarshal(der, &info); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidKey, err.Error())
+	}
+	if !info.Algorithm.Algorithm.Equal(oidPBES2) {
+		return nil, fmt.Errorf("%w: unsupported PKCS#8 encryption %s", ErrInvalidKey, info.Algorithm.Algorithm)
+	}
+	var params pbes2Params
+	if _, err := asn1.Unmarshal(info.Algorithm.Parameters.FullBytes, &params); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidKey, err.Error())
+	}
+
+	var keyLen int
+	switch alg := params.EncryptionScheme.Algorithm; {
+	case alg.Equal(oidAES128CBC):
+		keyLen = 16
+	case alg.Equal(oidAES192CBC):
+		keyLen = 24
+	case alg.Equal(oidAES256CBC):
+		keyLen = 32
+	default:
+		return nil, fmt.Errorf("%w: unsupported PKCS#8 cipher %s", ErrInvalidKey, alg)
+	}
+	var iv []byte
+	if _, err := asn1.Unmarshal(params.EncryptionScheme.Parameters.FullBytes, &iv); err != nil || len(iv) != aes.BlockSize {
+		return nil, fmt.Errorf("%w: invalid PKCS#8 cipher parameters", ErrInvalidKey)
+	}
+
+	key, err := pbkdf2Key(params.KeyDerivationFunc, passphrase, keyLen)
+	if err != nil {
+		return nil, err
+	}
+	if len(info.EncryptedData) == 0 || len(info.EncryptedData)%aes.BlockSize != 0 {
+		return nil, fmt.Errorf("%w: invalid PKCS#8 encrypted data length", ErrInvalidKey)
+	}
+	c, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	plain := make([]byte, len(info.EncryptedData))
+	cipher.NewCBCDecrypter(c, iv).CryptBlocks(plain, info.EncryptedData)
+
+	// A wrong passphrase gives a random plaintext, whose padding and content
+	// are invalid.
+	plain, ok := unpad(plain)
+	if !ok {
+		return nil, ErrIncorrectPassphrase
+	}
+	if _, err := x509.ParsePKCS8PrivateKey(plain); err != nil {
+		return nil, ErrIncorrectPassphrase
+	}
+	return plain, nil
+}
+
+// pbkdf2Key derives the keyLen bytes key of passphrase with the PBKDF2 kdf
+func pbkdf2Key(kdf pkix.AlgorithmIdentifier, passphrase []byte, keyLen int) ([]byte, error) {
+	if !kdf.Algorithm.Equal(oidPBKDF2) {
+		return nil, fmt.Errorf("%w: unsupported PKCS#8 key derivation %s", ErrInvalidKey, kdf.Algorithm)
+	}
+	var params pbkdf2Params
+	if _, err := asn1.Unmarshal(kdf.Parameters.FullBytes, &params); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidKey, err.Error())
+	}
+	if params.IterationCount <= 0 || (params.KeyLength != 0 && params.KeyLength != keyLen) {
+		return nil, fmt.Errorf("%w: invalid PKCS#8 key derivation parameters", ErrInvalidKey)
+	}
+
+	var newHash func() hash.Hash
+	switch prf := params.PRF.Algorithm; {
+	case len(prf) == 0, prf.Equal(oidHMACWithSHA1):
+		newHash = sha1.New
+	case prf.Equal(oidHMACWithSHA256):
+		newHash = sha256.New
+	case prf.Equal(oidHMACWithSHA512):
+		newHash = sha512.New
+	default:
+		return nil, fmt.Errorf("%w: unsupported PKCS#8 key derivation PRF %s", ErrInvalidKey, prf)
+	}
+	return pbkdf2.Key(passphrase, params.Salt, params.IterationCount, keyLen, newHash), nil
+}
+
+// unpad removes the PKCS#7 padding of b
+func unpad(b []byte) ([]byte, bool) {
+	n := int(b[len(b)-1])
+	if n == 0 || n > aes.BlockSize || n > len(b) {
+		return nil, false
+	}
+	if !bytes.Equal(b[len(b)-n:], bytes.Repeat([]byte{byte(n)}, n)) {
+		return nil, false
+	}
+	return b[:len(b)-n], true
+}
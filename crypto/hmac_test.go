@@ -0,0 +1,92 @@
+package crypto
+
+import (
+	"encoding/hex"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHmacVerify(t *testing.T) {
+	for _, algorithm := range []Crypto{&HmacSha1{}, &HmacSha256{}, &HmacSha384{}, &HmacSha512{}} {
+		name := algorithm.Name()
+		signature, err := algorithm.Sign("msg", "secret")
+		require.NoError(t, err, name)
+
+		assert.NoError(t, algorithm.Verify("msg", signature, "secret"), name)
+		assert.True(t, errors.Is(algorithm.Verify("msg", signature, "other"), ErrInvalidSignature), name)
+
+		tampered := append([]byte{}, signature...)
+		tampered[len(tampered)-1] ^= 1
+		assert.True(t, errors.Is(algorithm.Verify("msg", tampered, "secret"), ErrInvalidSignature), name)
+		assert.True(t, errors.Is(algorithm.Verify("msg", signature[:len(signature)-1], "secret"), ErrInvalidSignature), name)
+		assert.True(t, errors.Is(algorithm.Verify("msg", append(signature, 0), "secret"), ErrInvalidSignature), name)
+	}
+}
+
+// TestHmacSha1 uses the test cases 1 and 2 of RFC 2202
+func TestHmacSha1(t *testing.T) {
+	var tests = []struct {
+		key    string
+		data   string
+		digest string
+	}{
+		{key: strings.Repeat("\x0b", 20), data: "Hi There", digest: "b617318655057264e28bc0b6fb378c8ef146be00"},
+		{key: "Jefe", data: "what do ya want for nothing?", digest: "effcdf6ae5eb2fa2d27416d5f184df9c259a7c79"},
+	}
+	for _, test := range tests {
+		signature, err := (&HmacSha1{}).Sign(test.data, test.key)
+		require.NoError(t, err)
+		assert.Equal(t, test.digest, hex.EncodeToString(signature))
+	}
+
+	_, err := Get("hmac-sha1")
+	assert.True(t, errors.Is(err, ErrUnknownAlgorithm), "hmac-sha1 must be explicitly constructed")
+}
+
+// TestHmacSha2 uses the test cases 1, 2 and 6 of RFC 4231
+func TestHmacSha2(t *testing.T) {
+	var tests = []struct {
+		key     string
+		data    string
+		digests map[Crypto]string
+	}{
+		{
+			key:  strings.Repeat("\x0b", 20),
+			data: "Hi There",
+			digests: map[Crypto]string{
+				&HmacSha256{}: "b0344c61d8db38535ca8afceaf0bf12b881dc200c9833da726e9376c2e32cff7",
+				&HmacSha384{}: "afd03944d84895626b0825f4ab46907f15f9dadbe4101ec682aa034c7cebc59cfaea9ea9076ede7f4af152e8b2fa9cb6",
+				&HmacSha512{}: "87aa7cdea5ef619d4ff0b4241a1d6cb02379f4e2ce4ec2787ad0b30545e17cdedaa833b7d6b8a702038b274eaea3f4e4be9d914eeb61f1702e696c203a126854",
+			},
+		},
+		{
+			key:  "Jefe",
+			data: "what do ya want for nothing?",
+			digests: map[Crypto]string{
+				&HmacSha256{}: "5bdcc146bf60754e6a042426089575c75a003f089d2739839dec58b964ec3843",
+				&HmacSha384{}: "af45d2e376484031617f78d2b58a6b1b9c7ef464f5a01b47e42ec3736322445e8e2240ca5e69e2c78b3239ecfab21649",
+				&HmacSha512{}: "164b7a7bfcf819e2e395fbe73b56e0a387bd64222e831fd610270cd7ea2505549758bf75c05a994a6d034f65f8f0e6fdcaeab1a34d4a6b4b636e070a38bce737",
+			},
+		},
+		{
+			key:  strings.Repeat("\xaa", 131),
+			data: "Test Using Larger Than Block-Size Key - Hash Key First",
+			digests: map[Crypto]string{
+				&HmacSha256{}: "60e431591ee0b67f0d8a26aacbf5b77f8e0bc6213728c5140546040f0ee37f54",
+				&HmacSha384{}: "4ece084485813e9088d2c63a041bc5b44f9ef1012a2b588f3cd11f05033ac4c60c2ef6ab4030fe8296248df163f44952",
+				&HmacSha512{}: "80b24263c7c1a3ebb71493c1dd7be8b49b46d1f41b4aeec1121b013783f8f3526b56d037e05f2598bd0fd2215d6a1e5295e64f73f63f0aec8b915a985d786598",
+			},
+		},
+	}
+	for _, test := range tests {
+		for algorithm, digest := range test.digests {
+			signature, err := algorithm.Sign(test.data, test.key)
+			require.NoError(t, err, algorithm.Name())
+			assert.Equal(t, digest, hex.EncodeToString(signature), algorithm.Name())
+		}
+	}
+}
@@ -0,0 +1,42 @@
+package crypto
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+)
+
+const algoHmacSha1 = "hmac-sha1"
+
+// HmacSha1 signing algorithm using hmac and sha1.
+//
+// SHA-1 is deprecated and HmacSha1 only exists to interoperate with legacy
+// systems which can not sign with anything else. It is not registered, so it
+// must be explicitly used in a Secret. Prefer HmacSha256 or HmacSha512.
+type HmacSha1 struct {
+}
+
+// Sign return signing of input msg with secret string
+func (h *HmacSha1) Sign(msg string, secret string) ([]byte, error) {
+	mac := hmac.New(sha1.New, []byte(secret))
+	if _, err := mac.Write([]byte(msg)); err != nil {
+		return nil, err
+	}
+	return mac.Sum(nil), nil
+}
+
+// Verify checks in constant time that signature is the signing of msg with secret
+func (h *HmacSha1) Verify(msg string, signature []byte, secret string) error {
+	expected, err := h.Sign(msg, secret)
+	if err != nil {
+		return err
+	}
+	if !hmac.Equal(expected, signature) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// Name return name of algorithim
+func (h *HmacSha1) Name() string {
+	return algoHmacSha1
+}
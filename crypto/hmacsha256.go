@@ -20,6 +20,18 @@ func (h *HmacSha256) Sign(msg string, secret string) ([]byte, error) {
 	return mac.Sum(nil), nil
 }
 
+// Verify checks in constant time that signature is the signing of msg with secret
+func (h *HmacSha256) Verify(msg string, signature []byte, secret string) error {
+	expected, err := h.Sign(msg, secret)
+	if err != nil {
+		return err
+	}
+	if !hmac.Equal(expected, signature) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
 // Name return name of algorithim
 func (h *HmacSha256) Name() string {
 	return algoHmacSha256
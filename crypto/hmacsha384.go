@@ -0,0 +1,38 @@
+package crypto
+
+import (
+	"crypto/hmac"
+	"crypto/sha512"
+)
+
+const algoHmacSha384 = "hmac-sha384"
+
+// HmacSha384 signing algorithm using hmac and sha384
+type HmacSha384 struct {
+}
+
+// Sign return signing of input msg with secret string
+func (h *HmacSha384) Sign(msg string, secret string) ([]byte, error) {
+	mac := hmac.New(sha512.New384, []byte(secret))
+	if _, err := mac.Write([]byte(msg)); err != nil {
+		return nil, err
+	}
+	return mac.Sum(nil), nil
+}
+
+// Verify checks in constant time that signature is the signing of msg with secret
+func (h *HmacSha384) Verify(msg string, signature []byte, secret string) error {
+	expected, err := h.Sign(msg, secret)
+	if err != nil {
+		return err
+	}
+	if !hmac.Equal(expected, signature) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// Name return name of algorithim
+func (h *HmacSha384) Name() string {
+	return algoHmacSha384
+}
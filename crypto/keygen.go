@@ -0,0 +1,68 @@
+package crypto
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+)
+
+// minRSABits is the smallest RSA key size GenerateRSAKeypair accepts
+const minRSABits = 2048
+
+// GenerateEd25519Keypair generates an Ed25519 keypair for the ed25519
+// algorithm. The private key is a PKCS#8 PEM block and the public key a PKIX
+// PEM block, usable as the Key and PublicKey of a secret.
+func GenerateEd25519Keypair() (privPEM, pubPEM []byte, err error) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	return encodeKeypair(priv, pub)
+}
+
+// GenerateRSAKeypair generates a RSA keypair of bits bits, at least 2048, for
+// the rsa-sha256 and rsa-pss-* algorithms. The keys are encoded like the ones
+// of GenerateEd25519Keypair.
+func GenerateRSAKeypair(bits int) (privPEM, pubPEM []byte, err error) {
+	if bits < minRSABits {
+		return nil, nil, fmt.Errorf("%w: rsa keys must have at least %d bits, got %d", ErrInvalidKey, minRSABits, bits)
+	}
+	priv, err := rsa.GenerateKey(rand.Reader, bits)
+	if err != nil {
+		return nil, nil, err
+	}
+	return encodeKeypair(priv, priv.Public())
+}
+
+// GenerateECDSAKeypair generates an ECDSA keypair on curve, e.g.
+// elliptic.P256() for ecdsa-sha256 or elliptic.P384() for ecdsa-sha384. The
+// keys are encoded like the ones of GenerateEd25519Keypair.
+func GenerateECDSAKeypair(curve elliptic.Curve) (privPEM, pubPEM []byte, err error) {
+	if curve == nil {
+		return nil, nil, fmt.Errorf("%w: missing ecdsa curve", ErrInvalidKey)
+	}
+	priv, err := ecdsa.GenerateKey(curve, rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	return encodeKeypair(priv, priv.Public())
+}
+
+// encodeKeypair encodes priv as a PKCS#8 PEM block and pub as a PKIX PEM block
+func encodeKeypair(priv, pub interface{}) ([]byte, []byte, error) {
+	privDER, err := x509.MarshalPKCS8PrivateKey(priv)
+	if err != nil {
+		return nil, nil, err
+	}
+	pubDER, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		return nil, nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privDER}),
+		pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pubDER}), nil
+}
@@ -0,0 +1,45 @@
+package crypto
+
+import (
+	"crypto/elliptic"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerateKeypair(t *testing.T) {
+	var tests = []struct {
+		name      string
+		generate  func() ([]byte, []byte, error)
+		algorithm Crypto
+	}{
+		{name: "ed25519", generate: GenerateEd25519Keypair, algorithm: &Ed25519{}},
+		{name: "rsa", generate: func() ([]byte, []byte, error) { return GenerateRSAKeypair(2048) }, algorithm: &RsaSha256{}},
+		{name: "rsa-pss", generate: func() ([]byte, []byte, error) { return GenerateRSAKeypair(2048) }, algorithm: &RsaPss{}},
+		{name: "ecdsa p-256", generate: func() ([]byte, []byte, error) { return GenerateECDSAKeypair(elliptic.P256()) }, algorithm: &EcdsaSha256{}},
+		{name: "ecdsa p-384", generate: func() ([]byte, []byte, error) { return GenerateECDSAKeypair(elliptic.P384()) }, algorithm: &EcdsaSha384{}},
+	}
+	for _, tc := range tests {
+		privPEM, pubPEM, err := tc.generate()
+		require.NoError(t, err, tc.name)
+
+		name, err := DefaultAlgorithm(string(pubPEM))
+		require.NoError(t, err, tc.name)
+		algorithm, err := Get(name)
+		require.NoError(t, err, tc.name)
+		assert.NoError(t, CheckKeyType(tc.algorithm, string(privPEM)), tc.name)
+		assert.NoError(t, CheckKeyType(algorithm, string(pubPEM)), tc.name)
+
+		signature, err := tc.algorithm.Sign("message", string(privPEM))
+		require.NoError(t, err, tc.name)
+		assert.NoError(t, tc.algorithm.Verify("message", signature, string(pubPEM)), tc.name)
+		assert.True(t, errors.Is(tc.algorithm.Verify("tampered", signature, string(pubPEM)), ErrInvalidSignature), tc.name)
+	}
+
+	_, _, err := GenerateRSAKeypair(1024)
+	assert.True(t, errors.Is(err, ErrInvalidKey))
+	_, _, err = GenerateECDSAKeypair(nil)
+	assert.True(t, errors.Is(err, ErrInvalidKey))
+}
@@ -0,0 +1,145 @@
+package crypto
+
+import (
+	stdcrypto "crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+)
+
+var errNoPEMBlock = errors.New("no PEM block found")
+
+// ErrIncompatibleKey error when a key is not of the type used by an algorithm
+var ErrIncompatibleKey = errors.New("key type is incompatible with the algorithm")
+
+// DefaultAlgorithm returns the name of the default algorithm of a PEM encoded
+// private or public key: rsa-sha256 for RSA keys, ecdsa-sha256 and
+// ecdsa-sha384 for P-256 and P-384 keys and ed25519 for Ed25519 keys.
+func DefaultAlgorithm(key string) (string, error) {
+	pub, err := parsePublicKey(key)
+	if err != nil {
+		return "", err
+	}
+	switch pub := pub.(type) {
+	case *rsa.PublicKey:
+		return algoRsaSha256, nil
+	case *ecdsa.PublicKey:
+		switch pub.Curve {
+		case elliptic.P256():
+			return algoEcdsaSha256, nil
+		case elliptic.P384():
+			return algoEcdsaSha384, nil
+		}
+		return "", fmt.Errorf("%w: no algorithm for the %s curve", ErrInvalidKey, pub.Curve.Params().Name)
+	case ed25519.PublicKey:
+		return algoEd25519, nil
+	}
+	return "", fmt.Errorf("%w: no algorithm for %T keys", ErrInvalidKey, pub)
+}
+
+// keyTypeChecker is implemented by the builtin algorithms defined in files
+// with build constraints, which CheckKeyType could not name.
+type keyTypeChecker interface {
+	checkKeyType(pub interface{}) bool
+}
+
+// CheckKeyType returns ErrIncompatibleKey when key, a PEM encoded private or
+// public key, is of a type the builtin algorithm does not use, e.g. a RSA key
+// for hmac-sha256. Keys that are not PEM encoded, like hmac secrets, and the
+// algorithms added with Register are not checked.
+func CheckKeyType(algorithm Crypto, key string) error {
+	pub, err := parsePublicKey(key)
+	if err != nil {
+		return nil
+	}
+	var ok bool
+	switch alg := algorithm.(type) {
+	case *HmacSha1, *HmacSha256, *HmacSha384, *HmacSha512:
+	case *RsaSha256, *RsaPss:
+		_, ok = pub.(*rsa.PublicKey)
+	case *EcdsaSha256, *EcdsaSha384:
+		_, ok = pub.(*ecdsa.PublicKey)
+	case *Ed25519:
+		_, ok = pub.(ed25519.PublicKey)
+	case keyTypeChecker:
+		ok = alg.checkKeyType(pub)
+	default:
+		return nil
+	}
+	if !ok {
+		return fmt.Errorf("%w: %s with a %T key", ErrIncompatibleKey, algorithm.Name(), pub)
+	}
+	return nil
+}
+
+// parsePrivateKey decodes a PEM encoded PKCS#1, PKCS#8 or SEC 1 private key
+func parsePrivateKey(key string) (interface{}, error) {
+	block, _ := pem.Decode([]byte(key))
+	if block == nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidKey, errNoPEMBlock.Error())
+	}
+
+	var (
+		parsed interface{}
+		err    error
+	)
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		parsed, err = x509.ParseECPrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("%w: unsupported PEM block type %q", ErrInvalidKey, block.Type)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidKey, err.Error())
+	}
+	return parsed, nil
+}
+
+// parsePublicKey decodes a PEM encoded PKIX or PKCS#1 public key, or a
+// certificate. Private keys are accepted too and their public part returned.
+func parsePublicKey(key string) (interface{}, error) {
+	block, _ := pem.Decode([]byte(key))
+	if block == nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidKey, errNoPEMBlock.Error())
+	}
+
+	var (
+		parsed interface{}
+		err    error
+	)
+	switch block.Type {
+	case "PUBLIC KEY":
+		parsed, err = x509.ParsePKIXPublicKey(block.Bytes)
+	case "RSA PUBLIC KEY":
+		parsed, err = x509.ParsePKCS1PublicKey(block.Bytes)
+	case "CERTIFICATE":
+		var cert *x509.Certificate
+		cert, err = x509.ParseCertificate(block.Bytes)
+		if err == nil {
+			parsed = cert.PublicKey
+		}
+	default:
+		priv, err := parsePrivateKey(key)
+		if err != nil {
+			return nil, err
+		}
+		signer, ok := priv.(stdcrypto.Signer)
+		if !ok {
+			return nil, fmt.Errorf("%w: %T has no public key", ErrInvalidKey, priv)
+		}
+		return signer.Public(), nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidKey, err.Error())
+	}
+	return parsed, nil
+}
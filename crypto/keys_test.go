@@ -0,0 +1,66 @@
+package crypto
+
+import (
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDefaultAlgorithm(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	rsaPEM := string(pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(rsaKey)}))
+	p256Priv, p256Pub := generateECKeyPEM(t, elliptic.P256())
+	p384Priv, _ := generateECKeyPEM(t, elliptic.P384())
+	p521Priv, _ := generateECKeyPEM(t, elliptic.P521())
+	edDER, err := x509.MarshalPKCS8PrivateKey(ed25519.NewKeyFromSeed(mustDecodeHex(t, ed25519Seed)))
+	require.NoError(t, err)
+	edPEM := string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: edDER}))
+
+	var tests = []struct {
+		name      string
+		key       string
+		algorithm string
+	}{
+		{name: "rsa", key: rsaPEM, algorithm: "rsa-sha256"},
+		{name: "p-256", key: p256Priv, algorithm: "ecdsa-sha256"},
+		{name: "p-256 public key", key: p256Pub, algorithm: "ecdsa-sha256"},
+		{name: "p-384", key: p384Priv, algorithm: "ecdsa-sha384"},
+		{name: "ed25519", key: edPEM, algorithm: "ed25519"},
+	}
+	for _, tc := range tests {
+		algorithm, err := DefaultAlgorithm(tc.key)
+		require.NoError(t, err, tc.name)
+		assert.Equal(t, tc.algorithm, algorithm, tc.name)
+	}
+
+	_, err = DefaultAlgorithm(p521Priv)
+	assert.True(t, errors.Is(err, ErrInvalidKey))
+	_, err = DefaultAlgorithm("secret")
+	assert.True(t, errors.Is(err, ErrInvalidKey))
+}
+
+func TestCheckKeyType(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	rsaPEM := string(pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(rsaKey)}))
+	ecPriv, ecPub := generateECKeyPEM(t, elliptic.P256())
+
+	assert.NoError(t, CheckKeyType(&RsaSha256{}, rsaPEM))
+	assert.NoError(t, CheckKeyType(&RsaPss{}, rsaPEM))
+	assert.NoError(t, CheckKeyType(&EcdsaSha256{}, ecPub))
+	assert.NoError(t, CheckKeyType(&HmacSha256{}, "secret"))
+	assert.NoError(t, CheckKeyType(&reverseHmac{}, rsaPEM), "registered algorithms are not checked")
+
+	assert.True(t, errors.Is(CheckKeyType(&HmacSha256{}, rsaPEM), ErrIncompatibleKey))
+	assert.True(t, errors.Is(CheckKeyType(&RsaSha256{}, ecPriv), ErrIncompatibleKey))
+	assert.True(t, errors.Is(CheckKeyType(&Ed25519{}, ecPub), ErrIncompatibleKey))
+}
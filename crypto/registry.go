@@ -0,0 +1,56 @@
+package crypto
+
+import (
+	stdcrypto "crypto"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// ErrUnknownAlgorithm error when no algorithm is registered under a name
+var ErrUnknownAlgorithm = errors.New("unknown algorithm")
+
+var (
+	registryMu sync.RWMutex
+	registry   = map[string]func() Crypto{
+		algoHmacSha256:   func() Crypto { return &HmacSha256{} },
+		algoHmacSha384:   func() Crypto { return &HmacSha384{} },
+		algoHmacSha512:   func() Crypto { return &HmacSha512{} },
+		algoRsaSha256:    func() Crypto { return &RsaSha256{} },
+		algoEcdsaSha256:  func() Crypto { return &EcdsaSha256{} },
+		algoEcdsaSha384:  func() Crypto { return &EcdsaSha384{} },
+		algoEd25519:      func() Crypto { return &Ed25519{} },
+		algoRsaPssSha256: func() Crypto { return &RsaPss{Hash: stdcrypto.SHA256} },
+		algoRsaPssSha512: func() Crypto { return &RsaPss{Hash: stdcrypto.SHA512} },
+	}
+)
+
+// Register makes an algorithm available by name, so that secrets could be
+// created for it. Registering an existing name replaces the previous factory,
+// including the builtin algorithms.
+func Register(name string, factory func() Crypto) {
+	if name == "" || factory == nil {
+		panic("crypto: Register with empty name or nil factory")
+	}
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	registry[name] = factory
+}
+
+// AlgorithmFromName returns a new instance of the algorithm registered under
+// name, and whether there is one. HmacSha1 is not registered.
+func AlgorithmFromName(name string) (Crypto, bool) {
+	algorithm, err := Get(name)
+	return algorithm, err == nil
+}
+
+// Get returns a new instance of the algorithm registered under name
+func Get(name string) (Crypto, error) {
+	registryMu.RLock()
+	factory, ok := registry[name]
+	registryMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownAlgorithm, name)
+	}
+	return factory(), nil
+}
@@ -0,0 +1,44 @@
+package crypto
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type reverseHmac struct {
+	HmacSha256
+}
+
+func (r *reverseHmac) Name() string {
+	return "reverse-hmac"
+}
+
+func TestRegistry(t *testing.T) {
+	for _, name := range []string{"hmac-sha256", "hmac-sha384", "hmac-sha512", "rsa-sha256", "ecdsa-sha256", "ecdsa-sha384", "ed25519", "rsa-pss-sha256", "rsa-pss-sha512"} {
+		algorithm, err := Get(name)
+		require.NoError(t, err, name)
+		assert.Equal(t, name, algorithm.Name())
+	}
+
+	_, err := Get("reverse-hmac")
+	assert.True(t, errors.Is(err, ErrUnknownAlgorithm))
+	_, ok := AlgorithmFromName("reverse-hmac")
+	assert.False(t, ok)
+	_, ok = AlgorithmFromName("hmac-sha1")
+	assert.False(t, ok, "hmac-sha1 is not registered")
+
+	Register("reverse-hmac", func() Crypto { return &reverseHmac{} })
+	algorithm, err := Get("reverse-hmac")
+	require.NoError(t, err)
+	assert.Equal(t, "reverse-hmac", algorithm.Name())
+	algorithm, ok = AlgorithmFromName("reverse-hmac")
+	require.True(t, ok)
+	assert.Equal(t, "reverse-hmac", algorithm.Name())
+
+	signature, err := algorithm.Sign("msg", "secret")
+	require.NoError(t, err)
+	assert.NoError(t, algorithm.Verify("msg", signature, "secret"))
+}
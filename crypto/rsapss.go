@@ -0,0 +1,81 @@
+package crypto
+
+import (
+	stdcrypto "crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	_ "crypto/sha256" // register SHA-256 and SHA-512 for stdcrypto.Hash
+	_ "crypto/sha512"
+	"fmt"
+	"strings"
+)
+
+const (
+	algoRsaPssSha256 = "rsa-pss-sha256"
+	algoRsaPssSha512 = "rsa-pss-sha512"
+)
+
+// RsaPss signing algorithm using RSASSA-PSS. Hash is the hash function,
+// SHA-512 when not set, and SaltLength the length of the salt in bytes, the
+// size of the hash when not set. PSS signatures are randomized, so they are
+// only checked with Verify.
+// The private key is a PEM encoded PKCS#1 or PKCS#8 RSA key.
+type RsaPss struct {
+	Hash       stdcrypto.Hash
+	SaltLength int
+}
+
+// Sign return signing of input msg with the private key
+func (r *RsaPss) Sign(msg string, secret string) ([]byte, error) {
+	key, err := parseRSAPrivateKey(secret)
+	if err != nil {
+		return nil, err
+	}
+	hash := r.hash()
+	if !hash.Available() {
+		return nil, fmt.Errorf("rsa-pss: hash %v is not available", hash)
+	}
+	h := hash.New()
+	h.Write([]byte(msg))
+	return rsa.SignPSS(rand.Reader, key, hash, h.Sum(nil), r.options())
+}
+
+// Verify checks that signature is a valid signature of msg. The key could be
+// a PEM encoded public key, certificate or private key.
+func (r *RsaPss) Verify(msg string, signature []byte, key string) error {
+	pub, err := parseRSAPublicKey(key)
+	if err != nil {
+		return err
+	}
+	hash := r.hash()
+	if !hash.Available() {
+		return fmt.Errorf("rsa-pss: hash %v is not available", hash)
+	}
+	h := hash.New()
+	h.Write([]byte(msg))
+	if err := rsa.VerifyPSS(pub, hash, h.Sum(nil), signature, r.options()); err != nil {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// Name return name of algorithim, e.g. rsa-pss-sha512
+func (r *RsaPss) Name() string {
+	name := strings.ToLower(strings.Replace(r.hash().String(), "-", "", 1))
+	return "rsa-pss-" + name
+}
+
+func (r *RsaPss) hash() stdcrypto.Hash {
+	if r.Hash == 0 {
+		return stdcrypto.SHA512
+	}
+	return r.Hash
+}
+
+func (r *RsaPss) options() *rsa.PSSOptions {
+	saltLength := r.SaltLength
+	if saltLength == 0 {
+		saltLength = rsa.PSSSaltLengthEqualsHash
+	}
+	return &rsa.PSSOptions{SaltLength: saltLength, Hash: r.hash()}
+}
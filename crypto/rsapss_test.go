@@ -0,0 +1,49 @@
+package crypto
+
+import (
+	stdcrypto "crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRsaPssRoundTrip(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	priv := string(pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}))
+	pubDER, err := x509.MarshalPKIXPublicKey(key.Public())
+	require.NoError(t, err)
+	pub := string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pubDER}))
+
+	var tests = []struct {
+		name      string
+		algorithm *RsaPss
+	}{
+		{name: "rsa-pss-sha512", algorithm: &RsaPss{}},
+		{name: "rsa-pss-sha256", algorithm: &RsaPss{Hash: stdcrypto.SHA256}},
+		{name: "rsa-pss-sha512", algorithm: &RsaPss{Hash: stdcrypto.SHA512, SaltLength: 32}},
+	}
+	for _, test := range tests {
+		assert.Equal(t, test.name, test.algorithm.Name())
+
+		signature, err := test.algorithm.Sign("msg", priv)
+		require.NoError(t, err, test.name)
+		other, err := test.algorithm.Sign("msg", priv)
+		require.NoError(t, err, test.name)
+		assert.NotEqual(t, signature, other, "pss signatures are randomized")
+
+		assert.NoError(t, test.algorithm.Verify("msg", signature, pub), test.name)
+		assert.NoError(t, test.algorithm.Verify("msg", other, priv), test.name)
+		assert.True(t, errors.Is(test.algorithm.Verify("other", signature, pub), ErrInvalidSignature), test.name)
+		assert.True(t, errors.Is((&RsaSha256{}).Verify("msg", signature, pub), ErrInvalidSignature), test.name)
+	}
+
+	_, err = (&RsaPss{}).Sign("msg", "not a key")
+	assert.True(t, errors.Is(err, ErrInvalidKey))
+}
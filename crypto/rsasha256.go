@@ -0,0 +1,69 @@
+package crypto
+
+import (
+	stdcrypto "crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"fmt"
+)
+
+const algoRsaSha256 = "rsa-sha256"
+
+// RsaSha256 signing algorithm using RSASSA-PKCS1-v1_5 and sha256.
+// The private key is a PEM encoded PKCS#1 or PKCS#8 RSA key.
+type RsaSha256 struct {
+}
+
+// Sign return signing of input msg with the private key
+func (r *RsaSha256) Sign(msg string, secret string) ([]byte, error) {
+	key, err := parseRSAPrivateKey(secret)
+	if err != nil {
+		return nil, err
+	}
+	hashed := sha256.Sum256([]byte(msg))
+	return rsa.SignPKCS1v15(rand.Reader, key, stdcrypto.SHA256, hashed[:])
+}
+
+// Verify checks that signature is a valid signature of msg. The key could be
+// a PEM encoded public key, certificate or private key.
+func (r *RsaSha256) Verify(msg string, signature []byte, key string) error {
+	pub, err := parseRSAPublicKey(key)
+	if err != nil {
+		return err
+	}
+	hashed := sha256.Sum256([]byte(msg))
+	if err := rsa.VerifyPKCS1v15(pub, stdcrypto.SHA256, hashed[:], signature); err != nil {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// Name return name of algorithim
+func (r *RsaSha256) Name() string {
+	return algoRsaSha256
+}
+
+func parseRSAPrivateKey(key string) (*rsa.PrivateKey, error) {
+	parsed, err := parsePrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+	priv, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("%w: %T is not a rsa private key", ErrInvalidKey, parsed)
+	}
+	return priv, nil
+}
+
+func parseRSAPublicKey(key string) (*rsa.PublicKey, error) {
+	parsed, err := parsePublicKey(key)
+	if err != nil {
+		return nil, err
+	}
+	pub, ok := parsed.(*rsa.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("%w: %T is not a rsa public key", ErrInvalidKey, parsed)
+	}
+	return pub, nil
+}
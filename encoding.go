@@ -0,0 +1,31 @@
+package httpsign
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+)
+
+// SignatureEncoding is the encoding of the signature parameter of the
+// Signature header.
+type SignatureEncoding int
+
+const (
+	// Base64 is the standard base64 encoding of the signatures. This is the default.
+	Base64 SignatureEncoding = iota
+	// Hex is the lowercase hexadecimal encoding of the signatures.
+	Hex
+)
+
+func (e SignatureEncoding) encode(signature []byte) string {
+	if e == Hex {
+		return hex.EncodeToString(signature)
+	}
+	return base64.StdEncoding.EncodeToString(signature)
+}
+
+func (e SignatureEncoding) decode(signature string) ([]byte, error) {
+	if e == Hex {
+		return hex.DecodeString(signature)
+	}
+	return base64.StdEncoding.DecodeString(signature)
+}
@@ -2,6 +2,9 @@ package httpsign
 
 import (
 	"errors"
+	"fmt"
+	"net/http"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 )
@@ -13,13 +16,103 @@ func newPublicError(msg string) *gin.Error {
 	}
 }
 
+// newMalformedError returns a public error wrapping ErrMalformedSignatureHeader
+func newMalformedError(msg string) *gin.Error {
+	return &gin.Error{
+		Err:  fmt.Errorf("%w: %s", ErrMalformedSignatureHeader, msg),
+		Type: gin.ErrorTypePublic,
+	}
+}
+
+// newMissingHeaderError returns a public error wrapping
+// ErrMissingRequiredHeader with the name of the missing header
+func newMissingHeaderError(name string) *gin.Error {
+	return &gin.Error{
+		Err:  &missingHeaderError{name: name},
+		Type: gin.ErrorTypePublic,
+	}
+}
+
+// missingHeaderError is the error of a covered header missing from the
+// request. It wraps ErrMissingRequiredHeader and matches the deprecated
+// ErrEmptyHeader too, which was returned before.
+type missingHeaderError struct {
+	name string
+}
+
+func (e *missingHeaderError) Error() string {
+	return ErrMissingRequiredHeader.Error() + ": " + e.name
+}
+
+func (e *missingHeaderError) Unwrap() error {
+	return ErrMissingRequiredHeader
+}
+
+func (e *missingHeaderError) Is(target error) bool {
+	return target == error(ErrEmptyHeader)
+}
+
+// emptyHeaderError is the error of ErrEmptyHeader, it matches
+// ErrMissingRequiredHeader
+type emptyHeaderError struct{}
+
+func (emptyHeaderError) Error() string {
+	return "Empty required header"
+}
+
+func (emptyHeaderError) Unwrap() error {
+	return ErrMissingRequiredHeader
+}
+
+// newHeaderNotCoveredError returns a public MissingHeadersError with the
+// names of the headers the signature does not cover
+func newHeaderNotCoveredError(names ...string) *gin.Error {
+	return &gin.Error{
+		Err:  &MissingHeadersError{Missing: names},
+		Type: gin.ErrorTypePublic,
+	}
+}
+
+// toGinError returns a new *gin.Error for err with meta as its meta. The
+// sentinels are shared between requests, so they are copied instead of being
+// changed. Errors that are not *gin.Error yet, like the
+// validator.ValidationError, are public. Signing failures, which are server
+// errors, are private.
+func toGinError(err error, meta interface{}) *gin.Error {
+	if gerr, ok := err.(*gin.Error); ok {
+		return &gin.Error{
+			Err:  gerr.Err,
+			Type: gerr.Type,
+			Meta: meta,
+		}
+	}
+	if errors.Is(err, ErrSigningFailed) {
+		return &gin.Error{
+			Err:  err,
+			Type: gin.ErrorTypePrivate,
+			Meta: meta,
+		}
+	}
+	return &gin.Error{
+		Err:  err,
+		Type: gin.ErrorTypePublic,
+		Meta: meta,
+	}
+}
+
 var (
 	// ErrInvalidAuthorizationHeader error when get invalid format of Authorization header
 	ErrInvalidAuthorizationHeader = newPublicError("Authorization header format is incorrect")
 	// ErrInvalidKeyID error when KeyID in header does not provided
 	ErrInvalidKeyID = newPublicError("Invalid keyId")
+	// ErrKeyIDMismatch error when the keyId of the signature is not the one
+	// resolved from the request, see WithKeyIDResolver
+	ErrKeyIDMismatch = newPublicError("keyId does not match")
 	// ErrIncorrectAlgorithm error when Algorithm in header does not match with secret key
 	ErrIncorrectAlgorithm = newPublicError("Algorithm does not match")
+	// ErrAlgorithmNotAllowed error when the algorithm of the signature or of the
+	// secret is not allowed, see WithAllowedAlgorithms
+	ErrAlgorithmNotAllowed = newPublicError("Algorithm is not allowed")
 	// ErrHeaderNotEnough error when requiremts header do not appear on heder field
 	ErrHeaderNotEnough = newPublicError("Header field is not match requirement")
 	// ErrNoSignature error when no Signature not found in header
@@ -31,12 +124,212 @@ var (
 	// ErrMissingSignature error when signature not in header
 	ErrMissingSignature = newPublicError("signature must be on header")
 
+	// ErrMalformedSignatureHeader err when the signature header could not be
+	// parsed. The parsing errors below wrap it, so it could be matched with
+	// errors.Is.
+	ErrMalformedSignatureHeader = newPublicError("Signature header format is incorrect")
 	// ErrUnterminatedParameter err when could not parse value
-	ErrUnterminatedParameter = newPublicError("Unterminated parameter")
+	ErrUnterminatedParameter = newMalformedError("Unterminated parameter")
 	// ErrMissingDoubleQuote err when after character = not have double quote
-	ErrMissingDoubleQuote = newPublicError(`Missing " after = character`)
+	ErrMissingDoubleQuote = newMalformedError(`Missing " after = character`)
 	// ErrMissingEqualCharacter err when there is no character = before " or , character
-	ErrMissingEqualCharacter = newPublicError(`Missing = character =`)
+	ErrMissingEqualCharacter = newMalformedError(`Missing = character =`)
+	// ErrMissingParameterName err when a parameter has no name
+	ErrMissingParameterName = newMalformedError("Missing parameter name")
+	// ErrDuplicateParameter err when a parameter appears more than once
+	ErrDuplicateParameter = newMalformedError("Duplicate parameter")
+	// ErrMissingRequiredHeader err when a header covered by the signature is
+	// not in the request. The returned errors wrap it and name the header, so
+	// it should be matched with errors.Is.
+	ErrMissingRequiredHeader = newPublicError("Missing required header")
 	// ErrEmptyHeader err when one of the required headers are empty
-	ErrEmptyHeader = newPublicError(`Empty required header`)
+	//
+	// Deprecated: use ErrMissingRequiredHeader. The errors of the missing
+	// headers name the header, so comparing them with == no longer matches:
+	// they match ErrEmptyHeader with errors.Is, and ErrEmptyHeader matches
+	// ErrMissingRequiredHeader.
+	ErrEmptyHeader = &gin.Error{
+		Err:  emptyHeaderError{},
+		Type: gin.ErrorTypePublic,
+	}
+
+	// ErrInvalidSignatureInput err when the Signature-Input header could not be parsed
+	ErrInvalidSignatureInput = newPublicError("Signature-Input header format is incorrect")
+	// ErrInvalidAcceptSignature err when the Accept-Signature header could not be parsed
+	ErrInvalidAcceptSignature = newPublicError("Accept-Signature header format is incorrect")
+	// ErrNoAcceptableSignature err when the response could not be signed with
+	// any key and algorithm the client accepts
+	ErrNoAcceptableSignature = newPublicError("No acceptable signature")
+	// ErrInvalidJWS err when the X-JWS-Signature header could not be parsed
+	ErrInvalidJWS = newMalformedError("JWS signature format is incorrect")
+	// ErrUnsupportedComponent err when a covered component is not supported
+	ErrUnsupportedComponent = newPublicError("Covered component is not supported")
+	// ErrInvalidRequestTarget err when the resolved request target is not a valid request URI
+	ErrInvalidRequestTarget = newPublicError("Request target is incorrect")
+	// ErrTooManyHeaders err when the signature covers more headers than accepted
+	ErrTooManyHeaders = newPublicError("Signature covers too many headers")
+	// ErrTooManyFailures err when the FailureLimiter rejects the request
+	ErrTooManyFailures = newPublicError("Too many failed verifications")
+	// ErrTLSRequired err when the request is not received over TLS
+	ErrTLSRequired = newPublicError("TLS is required")
+	// ErrIPNotAllowed err when the keyID is not allowed from the source IP of
+	// the request, see WithIPAllowList
+	ErrIPNotAllowed = newPublicError("Source IP is not allowed")
+	// ErrSignatureExpired err when the expiration time of the signature has passed
+	ErrSignatureExpired = newPublicError("Signature has expired")
+	// ErrInvalidTimestamp err when the created or expires parameter is not a Unix timestamp
+	ErrInvalidTimestamp = newMalformedError("created and expires must be Unix timestamps")
+	// ErrMissingSignatureExpiry err when signing (expires) without WithSignatureExpiry
+	ErrMissingSignatureExpiry = errors.New("httpsign: (expires) is covered but the Signer has no signature expiry")
+	// ErrSigningFailed err when the algorithm fails to sign or verify for
+	// another reason than an invalid signature, e.g. a misconfigured key. The
+	// returned errors are *SigningError matching it with errors.Is.
+	ErrSigningFailed = errors.New("httpsign: signing failed")
 )
+
+// SigningError is the error of the algorithm of a secret failing to sign or
+// verify, which is a server misconfiguration rather than a client error. It
+// matches ErrSigningFailed with errors.Is and unwraps to the error of the
+// algorithm.
+type SigningError struct {
+	// Op is sign or verify
+	Op    string
+	KeyID KeyID
+	Err   error
+}
+
+func (e *SigningError) Error() string {
+	return fmt.Sprintf("%s failed for keyID %s: %v", e.Op, e.KeyID, e.Err)
+}
+
+// Unwrap returns the error of the algorithm
+func (e *SigningError) Unwrap() error {
+	return e.Err
+}
+
+// Is reports whether target is ErrSigningFailed
+func (e *SigningError) Is(target error) bool {
+	return target == ErrSigningFailed
+}
+
+// MissingHeadersError is the error of the signatures not covering headers
+// the Authenticator requires. It wraps ErrHeaderNotEnough and its message
+// lists the missing headers.
+type MissingHeadersError struct {
+	// Missing are the lowercased names of the headers not covered
+	Missing []string
+}
+
+func (e *MissingHeadersError) Error() string {
+	return fmt.Sprintf("%s: %s", ErrHeaderNotEnough.Error(), strings.Join(e.Missing, ", "))
+}
+
+// Unwrap returns ErrHeaderNotEnough
+func (e *MissingHeadersError) Unwrap() error {
+	return ErrHeaderNotEnough
+}
+
+// FailureReason is a machine-readable reason of a verification failure
+type FailureReason string
+
+// Reasons of the verification failures
+const (
+	// ReasonMissingSignature the request carries no signature
+	ReasonMissingSignature FailureReason = "missing_signature"
+	// ReasonMalformedSignature the signature header could not be parsed
+	ReasonMalformedSignature FailureReason = "malformed_signature"
+	// ReasonTooManyHeaders the signature covers more headers than accepted
+	ReasonTooManyHeaders FailureReason = "too_many_headers"
+	// ReasonExpiredSignature the expires parameter of the signature is past, or
+	// its created parameter is missing or out of range
+	ReasonExpiredSignature FailureReason = "expired_signature"
+	// ReasonExpiredDate the date header is invalid or out of the accepted range
+	ReasonExpiredDate FailureReason = "expired_date"
+	// ReasonBadDigest the digest header is invalid or does not match the body
+	ReasonBadDigest FailureReason = "bad_digest"
+	// ReasonBodyTooLarge the body is larger than accepted
+	ReasonBodyTooLarge FailureReason = "body_too_large"
+	// ReasonReplayedNonce the nonce is missing or was already used
+	ReasonReplayedNonce FailureReason = "replayed_nonce"
+	// ReasonHostNotAllowed the host of the request is not allowed
+	ReasonHostNotAllowed FailureReason = "host_not_allowed"
+	// ReasonValidationFailed a custom validator rejected the request
+	ReasonValidationFailed FailureReason = "validation_failed"
+	// ReasonMissingHeader a required header is not covered or not present
+	ReasonMissingHeader FailureReason = "missing_header"
+	// ReasonUnknownKeyID no secret is configured for the keyId
+	ReasonUnknownKeyID FailureReason = "unknown_keyid"
+	// ReasonAlgorithmMismatch the algorithm does not match the secret
+	ReasonAlgorithmMismatch FailureReason = "algorithm_mismatch"
+	// ReasonAlgorithmNotAllowed the algorithm is not allowed, see
+	// WithAllowedAlgorithms
+	ReasonAlgorithmNotAllowed FailureReason = "algorithm_not_allowed"
+	// ReasonBadSignature the signature does not match the request
+	ReasonBadSignature FailureReason = "bad_signature"
+	// ReasonTooManyFailures the FailureLimiter rejected the request
+	ReasonTooManyFailures FailureReason = "too_many_failures"
+	// ReasonTLSRequired the request was not received over TLS, see
+	// WithRequireTLS
+	ReasonTLSRequired FailureReason = "tls_required"
+	// ReasonIPNotAllowed the keyID is not allowed from the source IP, see
+	// WithIPAllowList
+	ReasonIPNotAllowed FailureReason = "ip_not_allowed"
+	// ReasonRejected the request was rejected by a hook, see
+	// WithBeforeVerify
+	ReasonRejected FailureReason = "rejected"
+	// ReasonInternal the signature could not be checked, e.g. invalid key
+	ReasonInternal FailureReason = "internal_error"
+)
+
+// VerifyError is returned by Authenticator.Verify. It wraps the reason of the
+// failure together with the HTTP status code the middleware responds with.
+type VerifyError struct {
+	StatusCode int
+	Reason     FailureReason
+	Err        error
+
+	// keyID is the keyID of the signature, if it could be parsed
+	keyID KeyID
+}
+
+// newVerifyError returns a VerifyError, its StatusCode is set by the
+// Authenticator from the reason.
+func newVerifyError(reason FailureReason, err error) *VerifyError {
+	return &VerifyError{Reason: reason, Err: err}
+}
+
+func (e *VerifyError) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the underlying error
+func (e *VerifyError) Unwrap() error {
+	return e.Err
+}
+
+// DefaultErrorStatus is the default mapping of the failure reasons to HTTP
+// status codes: 500 Internal Server Error for ReasonInternal, 429 Too Many
+// Requests for ReasonTooManyFailures, 413 Request Entity Too Large for
+// ReasonBodyTooLarge, 401 Unauthorized otherwise. See WithErrorStatusMapper.
+func DefaultErrorStatus(reason FailureReason) int {
+	switch reason {
+	case ReasonInternal:
+		return http.StatusInternalServerError
+	case ReasonTooManyFailures:
+		return http.StatusTooManyRequests
+	case ReasonBodyTooLarge:
+		return http.StatusRequestEntityTooLarge
+	}
+	return http.StatusUnauthorized
+}
+
+// StatusCode returns the HTTP status code matching err. Errors returned by
+// Authenticator.Verify carry their own status code, any other error is
+// considered an authentication failure.
+func StatusCode(err error) int {
+	var verr *VerifyError
+	if errors.As(err, &verr) {
+		return verr.StatusCode
+	}
+	return http.StatusUnauthorized
+}
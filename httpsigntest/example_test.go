@@ -0,0 +1,36 @@
+package httpsigntest_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stremovskyy/httpsign"
+	"github.com/stremovskyy/httpsign/crypto"
+	"github.com/stremovskyy/httpsign/httpsigntest"
+)
+
+func ExampleSignRequest() {
+	secrets := httpsign.Secrets{
+		"read": &httpsign.Secret{Key: "1234", Algorithm: &crypto.HmacSha512{}},
+	}
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(httpsign.NewAuthenticator(secrets).Authenticated())
+	router.POST("/items", func(c *gin.Context) {
+		c.String(http.StatusOK, "created")
+	})
+
+	req := httptest.NewRequest("POST", "/items", strings.NewReader(`{"name":"item"}`))
+	if err := httpsigntest.SignRequest(req, "read", secrets["read"], nil); err != nil {
+		fmt.Println(err)
+		return
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	fmt.Println(w.Code, w.Body.String())
+	// Output: 200 created
+}
@@ -0,0 +1,19 @@
+// Package httpsigntest provides utilities to test the handlers protected by
+// the httpsign middleware.
+package httpsigntest
+
+import (
+	"net/http"
+
+	"github.com/stremovskyy/httpsign"
+)
+
+// SignRequest signs r with secret on behalf of keyID, as a client would, so
+// that it is accepted by an Authenticator configured with the same secret.
+// headers is the ordered list of covered fields, the default required headers
+// of the Authenticator when empty. The Date and Digest headers are set when
+// covered and missing. It is meant for requests built with
+// httptest.NewRequest.
+func SignRequest(r *http.Request, keyID httpsign.KeyID, secret *httpsign.Secret, headers []string) error {
+	return httpsign.NewSigner(keyID, secret, headers).Sign(r)
+}
@@ -0,0 +1,93 @@
+package httpsign
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+const forwardedForHeader = "X-Forwarded-For"
+
+// IPAllowList restricts keyIDs to the source IPs of their networks. The
+// keyIDs it does not list are usable from any IP.
+type IPAllowList map[KeyID][]*net.IPNet
+
+// NewIPAllowList parses the allowed CIDRs of each keyID, e.g. 10.0.0.0/8.
+// Single IPs without prefix length are accepted too.
+func NewIPAllowList(cidrs map[KeyID][]string) (IPAllowList, error) {
+	list := make(IPAllowList, len(cidrs))
+	for keyID, values := range cidrs {
+		nets := make([]*net.IPNet, 0, len(values))
+		for _, value := range values {
+			n, err := parseCIDR(value)
+			if err != nil {
+				return nil, fmt.Errorf("httpsign: keyID %s: %w", keyID, err)
+			}
+			nets = append(nets, n)
+		}
+		list[keyID] = nets
+	}
+	return list, nil
+}
+
+// parseCIDR parses a CIDR, or an IP as the network of this single IP
+func parseCIDR(value string) (*net.IPNet, error) {
+	if !strings.Contains(value, "/") {
+		ip := net.ParseIP(value)
+		if ip == nil {
+			return nil, fmt.Errorf("invalid IP %q", value)
+		}
+		bits := 8 * net.IPv6len
+		if ip4 := ip.To4(); ip4 != nil {
+			ip, bits = ip4, 8*net.IPv4len
+		}
+		return &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}, nil
+	}
+	_, n, err := net.ParseCIDR(value)
+	return n, err
+}
+
+// Allowed reports whether keyID may be used from ip
+func (l IPAllowList) Allowed(keyID KeyID, ip net.IP) bool {
+	nets, ok := l[keyID]
+	if !ok {
+		return true
+	}
+	for _, n := range nets {
+		if ip != nil && n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// WithIPAllowList configures the Authenticator to reject the valid signatures
+// of the keyIDs of list sent from other IPs, with ReasonIPNotAllowed. The
+// source IP is the host of r.RemoteAddr, see WithTrustForwardedFor.
+func WithIPAllowList(list IPAllowList) Option {
+	return func(a *Authenticator) {
+		a.ipAllowList = list
+	}
+}
+
+// WithTrustForwardedFor configures WithIPAllowList to use the last address of
+// the X-Forwarded-For header as source IP, the one added by the proxy in
+// front of the server. Only enable it behind a proxy setting this header,
+// clients could send it otherwise.
+func WithTrustForwardedFor(trust bool) Option {
+	return func(a *Authenticator) {
+		a.trustForwardedFor = trust
+	}
+}
+
+// sourceIP returns the IP r was sent from, nil when it could not be parsed
+func (a *Authenticator) sourceIP(r *http.Request) net.IP {
+	if a.trustForwardedFor {
+		if values := r.Header.Values(forwardedForHeader); len(values) > 0 {
+			addrs := strings.Split(values[len(values)-1], ",")
+			return net.ParseIP(strings.TrimSpace(addrs[len(addrs)-1]))
+		}
+	}
+	return net.ParseIP(remoteIP(r))
+}
@@ -0,0 +1,71 @@
+package httpsign
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewIPAllowList(t *testing.T) {
+	list, err := NewIPAllowList(map[KeyID][]string{readID: {"10.0.0.0/8", "192.168.1.10", "2001:db8::/32"}})
+	require.NoError(t, err)
+	assert.True(t, list.Allowed(readID, net.ParseIP("10.1.2.3")))
+	assert.True(t, list.Allowed(readID, net.ParseIP("192.168.1.10")))
+	assert.True(t, list.Allowed(readID, net.ParseIP("2001:db8::1")))
+	assert.False(t, list.Allowed(readID, net.ParseIP("192.168.1.11")))
+	assert.False(t, list.Allowed(readID, nil))
+	assert.True(t, list.Allowed(writeID, net.ParseIP("192.168.1.11")), "keyIDs not listed are not restricted")
+
+	_, err = NewIPAllowList(map[KeyID][]string{readID: {"10.0.0.0/33"}})
+	assert.Error(t, err)
+	_, err = NewIPAllowList(map[KeyID][]string{readID: {"localhost"}})
+	assert.Error(t, err)
+}
+
+func TestIPAllowList(t *testing.T) {
+	list, err := NewIPAllowList(map[KeyID][]string{readID: {"10.0.0.0/8"}})
+	require.NoError(t, err)
+	headers := []string{requestTarget, date}
+	options := []Option{WithRequiredHeaders(headers), WithValidator(&dateAlwaysValid{}), WithIPAllowList(list)}
+
+	tests := []struct {
+		name         string
+		keyID        KeyID
+		remoteAddr   string
+		forwardedFor []string
+		trust        bool
+		allowed      bool
+	}{
+		{name: "in range", keyID: readID, remoteAddr: "10.1.2.3:1234", allowed: true},
+		{name: "out of range", keyID: readID, remoteAddr: "192.168.1.1:1234"},
+		{name: "unrestricted keyID", keyID: writeID, remoteAddr: "192.168.1.1:1234", allowed: true},
+		{name: "untrusted forwarded for", keyID: readID, remoteAddr: "192.168.1.1:1234", forwardedFor: []string{"10.1.2.3"}},
+		{name: "trusted forwarded for", keyID: readID, remoteAddr: "192.168.1.1:1234", forwardedFor: []string{"10.1.2.3"}, trust: true, allowed: true},
+		{name: "last forwarded address", keyID: readID, remoteAddr: "10.1.2.3:1234", forwardedFor: []string{"10.0.0.1", "192.168.1.1, 10.0.0.2"}, trust: true, allowed: true},
+		{name: "spoofed forwarded address", keyID: readID, remoteAddr: "10.1.2.3:1234", forwardedFor: []string{"10.0.0.1, 192.168.1.1"}, trust: true},
+	}
+	for _, tc := range tests {
+		req, err := http.NewRequest("GET", "/", nil)
+		require.NoError(t, err, tc.name)
+		req.RemoteAddr = tc.remoteAddr
+		for _, v := range tc.forwardedFor {
+			req.Header.Add(forwardedForHeader, v)
+		}
+		require.NoError(t, NewSigner(tc.keyID, secrets[tc.keyID], headers).Sign(req), tc.name)
+
+		err = NewAuthenticator(secrets, append(options, WithTrustForwardedFor(tc.trust))...).Verify(req)
+		if tc.allowed {
+			assert.NoError(t, err, tc.name)
+			continue
+		}
+		var verr *VerifyError
+		require.True(t, errors.As(err, &verr), tc.name)
+		assert.Equal(t, ReasonIPNotAllowed, verr.Reason, tc.name)
+		assert.Equal(t, http.StatusUnauthorized, verr.StatusCode, tc.name)
+		assert.True(t, errors.Is(err, ErrIPNotAllowed), tc.name)
+	}
+}
@@ -0,0 +1,114 @@
+package httpsign
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+const (
+	// jwsSignatureHeader is the header carrying the detached JWS
+	jwsSignatureHeader = "X-JWS-Signature"
+)
+
+// jwsAlgorithms maps the JWS alg values (RFC 7518) to the names of the
+// algorithms of the crypto package.
+var jwsAlgorithms = map[string]string{
+	"HS256": "hmac-sha256",
+	"HS384": "hmac-sha384",
+	"HS512": "hmac-sha512",
+	"RS256": "rsa-sha256",
+	"PS256": "rsa-pss-sha256",
+	"PS512": "rsa-pss-sha512",
+	"ES256": "ecdsa-sha256",
+	"ES384": "ecdsa-sha384",
+	"EdDSA": "ed25519",
+}
+
+// jwsHeader is the JOSE protected header of the JWS signatures. Headers is the
+// list of fields covered by the signature, as the headers parameter of the
+// Cavage signatures.
+type jwsHeader struct {
+	Alg     string   `json:"alg"`
+	Kid     string   `json:"kid"`
+	Headers []string `json:"headers,omitempty"`
+	B64     *bool    `json:"b64,omitempty"`
+	Crit    []string `json:"crit,omitempty"`
+}
+
+// parseJWSRequest parses the detached JWS of the X-JWS-Signature header:
+// BASE64URL(protected header) + ".." + BASE64URL(signature). The payload is
+// the signing string of the covered headers. The kid may be missing unless
+// requireKeyID is set, see WithKeyIDResolver.
+func parseJWSRequest(r *http.Request, requireKeyID bool) (*SignatureHeader, error) {
+	jws := strings.TrimSpace(r.Header.Get(jwsSignatureHeader))
+	if jws == "" {
+		return nil, ErrNoSignature
+	}
+	parts := strings.Split(jws, ".")
+	if len(parts) != 3 || parts[1] != "" {
+		return nil, ErrInvalidJWS
+	}
+
+	protected, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, ErrInvalidJWS
+	}
+	var header jwsHeader
+	if err := json.Unmarshal(protected, &header); err != nil {
+		return nil, ErrInvalidJWS
+	}
+	for _, c := range header.Crit {
+		if c != "b64" || header.B64 == nil {
+			return nil, ErrInvalidJWS
+		}
+	}
+	// RFC 7797 unencoded payload must be declared critical
+	unencoded := header.B64 != nil && !*header.B64
+	if unencoded && len(header.Crit) == 0 {
+		return nil, ErrInvalidJWS
+	}
+	if header.Kid == "" && requireKeyID {
+		return nil, ErrMissingKeyID
+	}
+	algorithm, ok := jwsAlgorithms[header.Alg]
+	if !ok {
+		return nil, ErrIncorrectAlgorithm
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil || len(signature) == 0 {
+		return nil, ErrMissingSignature
+	}
+
+	sigHeader := &SignatureHeader{
+		keyID:     KeyID(header.Kid),
+		algorithm: algorithm,
+		headers:   header.Headers,
+		signature: base64.StdEncoding.EncodeToString(signature),
+		params:    parts[0],
+		unencoded: unencoded,
+	}
+	if len(sigHeader.headers) == 0 {
+		sigHeader.headers = []string{date}
+	}
+	return sigHeader, nil
+}
+
+// jwsCanonicalizer builds the JWS signing input:
+// BASE64URL(protected header) + "." + BASE64URL(signing string), the
+// signing string of payload being used as is for unencoded payloads.
+type jwsCanonicalizer struct {
+	payload CavageCanonicalizer
+}
+
+func (c jwsCanonicalizer) Canonicalize(r *http.Request, sigHeader *SignatureHeader) (string, error) {
+	payload, err := c.payload.Canonicalize(r, sigHeader)
+	if err != nil {
+		return "", err
+	}
+	if !sigHeader.unencoded {
+		payload = base64.RawURLEncoding.EncodeToString([]byte(payload))
+	}
+	return sigHeader.params + "." + payload, nil
+}
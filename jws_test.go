@@ -0,0 +1,130 @@
+package httpsign
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/stremovskyy/httpsign/crypto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// signJWS sets the detached JWS of req, sign returns the signature of the
+// signing input.
+func signJWS(t *testing.T, req *http.Request, header jwsHeader, sign func(input string) []byte) {
+	protected, err := json.Marshal(header)
+	require.NoError(t, err)
+	sigHeader := &SignatureHeader{
+		headers:   header.Headers,
+		params:    base64.RawURLEncoding.EncodeToString(protected),
+		unencoded: header.B64 != nil && !*header.B64,
+	}
+	input, err := jwsCanonicalizer{}.Canonicalize(req, sigHeader)
+	require.NoError(t, err)
+	req.Header.Set(jwsSignatureHeader, sigHeader.params+".."+base64.RawURLEncoding.EncodeToString(sign(input)))
+}
+
+func newJWSRequest(t *testing.T) *http.Request {
+	req, err := http.NewRequest("GET", "/foo?a=b", nil)
+	require.NoError(t, err)
+	req.Header.Set("Date", requestTime.Format(http.TimeFormat))
+	return req
+}
+
+func TestJWSVerifyHMAC(t *testing.T) {
+	auth := NewAuthenticator(secrets, WithSignatureFormat(JWS), WithRequiredHeaders([]string{requestTarget, date}), WithValidator(&dateAlwaysValid{}))
+	hs512 := func(input string) []byte {
+		signature, err := secrets[readID].Algorithm.Sign(input, secrets[readID].Key)
+		require.NoError(t, err)
+		return signature
+	}
+	header := jwsHeader{Alg: "HS512", Kid: string(readID), Headers: []string{requestTarget, date}}
+
+	req := newJWSRequest(t)
+	signJWS(t, req, header, hs512)
+	assert.NoError(t, auth.Verify(req))
+
+	req.Header.Set("Date", "tampered")
+	assert.True(t, errors.Is(auth.Verify(req), ErrInvalidSign))
+
+	unencoded := false
+	header.B64, header.Crit = &unencoded, []string{"b64"}
+	req = newJWSRequest(t)
+	signJWS(t, req, header, hs512)
+	assert.NoError(t, auth.Verify(req))
+
+	header.Alg = "HS256"
+	req = newJWSRequest(t)
+	signJWS(t, req, header, hs512)
+	assert.True(t, errors.Is(auth.Verify(req), ErrIncorrectAlgorithm))
+}
+
+func TestJWSVerifyES256(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	pubDER, err := x509.MarshalPKIXPublicKey(key.Public())
+	require.NoError(t, err)
+	secret := &Secret{
+		PublicKey: string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pubDER})),
+		Algorithm: &crypto.EcdsaSha256{},
+	}
+	auth := NewAuthenticator(Secrets{"partner": secret}, WithSignatureFormat(JWS), WithRequiredHeaders([]string{date}), WithValidator(&dateAlwaysValid{}))
+
+	req := newJWSRequest(t)
+	signJWS(t, req, jwsHeader{Alg: "ES256", Kid: "partner", Headers: []string{date}}, func(input string) []byte {
+		hashed := sha256.Sum256([]byte(input))
+		r, s, err := ecdsa.Sign(rand.Reader, key, hashed[:])
+		require.NoError(t, err)
+		// JWS ECDSA signatures are the fixed size R || S
+		signature := make([]byte, 64)
+		r.FillBytes(signature[:32])
+		s.FillBytes(signature[32:])
+		return signature
+	})
+	assert.NoError(t, auth.Verify(req))
+}
+
+func TestParseJWSErrors(t *testing.T) {
+	encode := func(header string) string {
+		return base64.RawURLEncoding.EncodeToString([]byte(header))
+	}
+	var tests = []struct {
+		name string
+		jws  string
+		err  error
+	}{
+		{name: "missing", err: ErrNoSignature},
+		{name: "attached payload", jws: encode(`{"alg":"HS512","kid":"read"}`) + ".cGF5bG9hZA.c2ln", err: ErrInvalidJWS},
+		{name: "two parts", jws: encode(`{"alg":"HS512","kid":"read"}`) + ".c2ln", err: ErrInvalidJWS},
+		{name: "invalid json", jws: encode(`{"alg":`) + "..c2ln", err: ErrInvalidJWS},
+		{name: "unknown crit", jws: encode(`{"alg":"HS512","kid":"read","crit":["exp"]}`) + "..c2ln", err: ErrInvalidJWS},
+		{name: "b64 not critical", jws: encode(`{"alg":"HS512","kid":"read","b64":false}`) + "..c2ln", err: ErrInvalidJWS},
+		{name: "missing kid", jws: encode(`{"alg":"HS512"}`) + "..c2ln", err: ErrMissingKeyID},
+		{name: "unknown alg", jws: encode(`{"alg":"none","kid":"read"}`) + "..c2ln", err: ErrIncorrectAlgorithm},
+		{name: "missing signature", jws: encode(`{"alg":"HS512","kid":"read"}`) + "..", err: ErrMissingSignature},
+	}
+	for _, test := range tests {
+		req := newJWSRequest(t)
+		if test.jws != "" {
+			req.Header.Set(jwsSignatureHeader, test.jws)
+		}
+		_, err := parseJWSRequest(req, true)
+		assert.Equal(t, test.err, err, test.name)
+	}
+
+	req := newJWSRequest(t)
+	req.Header.Set(jwsSignatureHeader, encode(`{"alg":"HS512","kid":"read"}`)+"..c2ln")
+	s, err := parseJWSRequest(req, true)
+	require.NoError(t, err)
+	assert.Equal(t, []string{date}, s.headers)
+	assert.Equal(t, "hmac-sha512", s.algorithm)
+}
@@ -0,0 +1,173 @@
+package httpsign
+
+import (
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// FailureLimiter limits the verification failures, e.g. per keyID or per
+// client IP. The Authenticator calls Allow before verifying a request and
+// rejects it with ReasonTooManyFailures, 429 Too Many Requests by default,
+// when it returns false. Failed is called on each verification failure.
+// keyID is empty when the signature could not be parsed. Failed only gets the
+// keyID of the failures where the signature was checked with the secrets of
+// the keyID, otherwise anyone could spend the failures of a keyID by sending
+// junk under it; the other failures are reported with an empty keyID. Allow
+// must account for these too, e.g. per client IP, otherwise the requests with
+// unknown keyIDs are never limited. Implementations must be safe for
+// concurrent use.
+type FailureLimiter interface {
+	Allow(r *http.Request, keyID KeyID) bool
+	Failed(r *http.Request, keyID KeyID)
+}
+
+// LimitKey returns the key the failures of a request are counted under.
+// keyID is empty for the failures without a proven keyID, see FailureLimiter.
+type LimitKey func(r *http.Request, keyID KeyID) string
+
+// LimitByKeyID counts the bad signatures per keyID. The TokenBucketLimiter
+// counts the other failures, like unknown keyIDs, per client IP. Beware that
+// anyone knowing a keyID can still lock its client out by sending bad
+// signatures under it, prefer LimitByRemoteIP or LimitByKeyIDAndRemoteIP
+// unless the keyIDs are secret.
+func LimitByKeyID(_ *http.Request, keyID KeyID) string {
+	return string(keyID)
+}
+
+// LimitByRemoteIP counts the failures per client IP, the host of
+// r.RemoteAddr. Behind a reverse proxy use a LimitKey reading the client IP
+// from the headers set by the proxy instead.
+func LimitByRemoteIP(r *http.Request, _ KeyID) string {
+	return remoteIP(r)
+}
+
+// LimitByKeyIDAndRemoteIP counts the bad signatures per keyID and client IP,
+// and the other failures per client IP.
+func LimitByKeyIDAndRemoteIP(r *http.Request, keyID KeyID) string {
+	return string(keyID) + " " + remoteIP(r)
+}
+
+func remoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// minSweep is the number of buckets from which the full buckets are removed
+const minSweep = 1024
+
+// TokenBucketLimiter is a FailureLimiter allowing burst failures per key,
+// then one failure every refill interval.
+type TokenBucketLimiter struct {
+	burst  float64
+	refill time.Duration
+	key    LimitKey
+	now    func() time.Time
+
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	sweepAt int
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// NewTokenBucketLimiter returns a FailureLimiter allowing burst failures per
+// key, refilled by one every refill interval. When the bucket of a key is
+// empty its requests are rejected until it is refilled.
+func NewTokenBucketLimiter(burst int, refill time.Duration, key LimitKey) *TokenBucketLimiter {
+	return &TokenBucketLimiter{
+		burst:   float64(burst),
+		refill:  refill,
+		key:     key,
+		now:     time.Now,
+		buckets: make(map[string]*bucket),
+		sweepAt: minSweep,
+	}
+}
+
+// Allow reports whether the bucket of the request has a token left, both the
+// bucket of keyID and the one of the failures without a proven keyID, see
+// FailureLimiter.
+func (l *TokenBucketLimiter) Allow(r *http.Request, keyID KeyID) bool {
+	k, unproven := l.key(r, keyID), l.unprovenKey(r)
+	now := l.now()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.hasToken(k, now) && l.hasToken(unproven, now)
+}
+
+// Failed takes a token from the bucket of the request, the bucket of the
+// failures without a proven keyID when keyID is empty.
+func (l *TokenBucketLimiter) Failed(r *http.Request, keyID KeyID) {
+	k := l.unprovenKey(r)
+	if keyID != "" {
+		k = l.key(r, keyID)
+	}
+	now := l.now()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	b, ok := l.buckets[k]
+	if !ok {
+		if len(l.buckets) >= l.sweepAt {
+			l.sweep(now)
+		}
+		b = &bucket{tokens: l.burst, last: now}
+		l.buckets[k] = b
+	}
+	b.tokens = l.tokens(b, now) - 1
+	if b.tokens < 0 {
+		b.tokens = 0
+	}
+	b.last = now
+}
+
+// unprovenKey returns the key of the failures of r without a proven keyID:
+// the key of an empty keyID, or the client IP when it is empty, like with
+// LimitByKeyID. The client IP is prefixed with a NUL byte, which header
+// values cannot hold, so that it does not collide with a keyID.
+func (l *TokenBucketLimiter) unprovenKey(r *http.Request) string {
+	if k := l.key(r, ""); k != "" {
+		return k
+	}
+	return "\x00" + remoteIP(r)
+}
+
+// hasToken reports whether the bucket of k has a token left. l.mu must be
+// held.
+func (l *TokenBucketLimiter) hasToken(k string, now time.Time) bool {
+	b, ok := l.buckets[k]
+	return !ok || l.tokens(b, now) >= 1
+}
+
+// tokens returns the tokens of b refilled until now
+func (l *TokenBucketLimiter) tokens(b *bucket, now time.Time) float64 {
+	tokens := b.tokens
+	if l.refill > 0 {
+		tokens += float64(now.Sub(b.last)) / float64(l.refill)
+	}
+	if tokens > l.burst {
+		tokens = l.burst
+	}
+	return tokens
+}
+
+// sweep removes the full buckets, which behave like missing ones, so that the
+// keys seen once do not accumulate.
+func (l *TokenBucketLimiter) sweep(now time.Time) {
+	for k, b := range l.buckets {
+		if l.tokens(b, now) >= l.burst {
+			delete(l.buckets, k)
+		}
+	}
+	l.sweepAt = 2 * len(l.buckets)
+	if l.sweepAt < minSweep {
+		l.sweepAt = minSweep
+	}
+}
@@ -0,0 +1,128 @@
+package httpsign
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTokenBucketLimiter(t *testing.T) {
+	now := time.Now()
+	l := NewTokenBucketLimiter(2, time.Minute, LimitByKeyIDAndRemoteIP)
+	l.now = func() time.Time { return now }
+
+	req, err := http.NewRequest("GET", "/", nil)
+	require.NoError(t, err)
+	req.RemoteAddr = "10.0.0.1:1234"
+	other, err := http.NewRequest("GET", "/", nil)
+	require.NoError(t, err)
+	other.RemoteAddr = "10.0.0.2:1234"
+
+	assert.True(t, l.Allow(req, readID))
+	l.Failed(req, readID)
+	assert.True(t, l.Allow(req, readID))
+	l.Failed(req, readID)
+	assert.False(t, l.Allow(req, readID))
+	assert.True(t, l.Allow(req, "other"), "keyIDs are counted apart")
+	assert.True(t, l.Allow(other, readID), "IPs are counted apart")
+
+	now = now.Add(30 * time.Second)
+	assert.False(t, l.Allow(req, readID))
+	now = now.Add(30 * time.Second)
+	assert.True(t, l.Allow(req, readID))
+	l.Failed(req, readID)
+	assert.False(t, l.Allow(req, readID))
+}
+
+func TestTokenBucketLimiterSweep(t *testing.T) {
+	now := time.Now()
+	l := NewTokenBucketLimiter(1, time.Second, LimitByKeyID)
+	l.now = func() time.Time { return now }
+
+	req, err := http.NewRequest("GET", "/", nil)
+	require.NoError(t, err)
+	for i := 0; i < minSweep; i++ {
+		l.Failed(req, KeyID(fmt.Sprint(i)))
+	}
+	require.Len(t, l.buckets, minSweep)
+
+	now = now.Add(time.Second)
+	l.Failed(req, readID)
+	assert.Len(t, l.buckets, 1, "the refilled buckets are removed")
+}
+
+func TestFailureLimiter(t *testing.T) {
+	limiter := NewTokenBucketLimiter(2, time.Hour, LimitByRemoteIP)
+	auth := NewAuthenticator(secrets, WithValidator(&dateAlwaysValid{}), WithFailureLimiter(limiter))
+
+	signed := func() *http.Request {
+		req := httptest.NewRequest("GET", "/", nil)
+		require.NoError(t, NewSigner(readID, secrets[readID], nil).Sign(req))
+		return req
+	}
+	unsigned := httptest.NewRequest("GET", "/", nil)
+
+	require.NoError(t, auth.Verify(signed()))
+	for i := 0; i < 2; i++ {
+		assert.True(t, errors.Is(auth.Verify(unsigned), ErrNoSignature))
+	}
+	err := auth.Verify(signed())
+	assert.True(t, errors.Is(err, ErrTooManyFailures))
+	assert.Equal(t, http.StatusTooManyRequests, StatusCode(err))
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(auth.Authenticated())
+	r.GET("/", httpTestGet)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, signed())
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+}
+
+func TestFailureLimiterByKeyID(t *testing.T) {
+	request := func(ip string, keyID KeyID, secret *Secret) *http.Request {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.RemoteAddr = ip + ":1234"
+		if secret != nil {
+			require.NoError(t, NewSigner(keyID, secret, nil).Sign(req))
+		} else {
+			req.Header.Set(authorizationHeader, generateSignature(keyID, algoHmacSha512, []string{"x-missing"}, requestBodySig))
+		}
+		return req
+	}
+	badSignature := func(ip string) *http.Request {
+		req := request(ip, readID, secrets[readID])
+		req.Header.Set("Date", "changed")
+		return req
+	}
+
+	for name, key := range map[string]LimitKey{"keyID": LimitByKeyID, "keyID and IP": LimitByKeyIDAndRemoteIP} {
+		limiter := NewTokenBucketLimiter(2, time.Hour, key)
+		auth := NewAuthenticator(secrets, WithValidator(&dateAlwaysValid{}), WithFailureLimiter(limiter))
+
+		var limited int
+		for i := 0; i < 10; i++ {
+			err := auth.Verify(request("10.0.0.1", KeyID(fmt.Sprint("unknown", i)), secrets[readID]))
+			if errors.Is(err, ErrTooManyFailures) {
+				limited++
+				continue
+			}
+			assert.True(t, errors.Is(err, ErrInvalidKeyID), name)
+		}
+		assert.Equal(t, 8, limited, "%s: the unknown keyIDs are limited per IP", name)
+		assert.True(t, errors.Is(auth.Verify(request("10.0.0.1", readID, nil)), ErrTooManyFailures), "%s: junk under a known keyID too", name)
+		require.NoError(t, auth.Verify(request("10.0.0.2", readID, secrets[readID])), "%s: the keyID is not locked out by junk", name)
+
+		for i := 0; i < 2; i++ {
+			assert.True(t, errors.Is(auth.Verify(badSignature("10.0.0.2")), ErrInvalidSign), name)
+		}
+		assert.True(t, errors.Is(auth.Verify(request("10.0.0.2", readID, secrets[readID])), ErrTooManyFailures), "%s: bad signatures count", name)
+	}
+}
@@ -0,0 +1,67 @@
+package httpsign
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Logger is the destination of the messages of the Authenticator, e.g. a
+// *log.Logger.
+type Logger interface {
+	Printf(format string, args ...interface{})
+}
+
+// stdoutLogger is the Logger used by WithDebug when no Logger is configured
+type stdoutLogger struct{}
+
+func (stdoutLogger) Printf(format string, args ...interface{}) {
+	fmt.Printf("%s [HTTP_SIGN] "+format+"\n", append([]interface{}{time.Now().Format(time.StampMilli)}, args...)...)
+}
+
+// DebugFormat is the format of the messages of the Authenticator, see
+// WithDebugFormat
+type DebugFormat int
+
+const (
+	// Text formats the messages as human readable lines
+	Text DebugFormat = iota
+	// JSON formats the messages as JSON objects, without the signatures
+	JSON
+)
+
+// debugEntry is a message in the JSON format
+type debugEntry struct {
+	Time          time.Time     `json:"time"`
+	Level         string        `json:"level"`
+	Reason        FailureReason `json:"reason"`
+	KeyID         KeyID         `json:"keyID,omitempty"`
+	Path          string        `json:"path"`
+	Error         string        `json:"error"`
+	Algorithm     string        `json:"algorithm,omitempty"`
+	Headers       []string      `json:"headers,omitempty"`
+	SigningString string        `json:"signingString,omitempty"`
+}
+
+// printer returns the Logger, or the stdout logger
+func (a *Authenticator) printer() Logger {
+	if a.logger != nil {
+		return a.logger
+	}
+	return stdoutLogger{}
+}
+
+// printJSON logs entry as a JSON object with the Logger, or as a line of
+// stdout
+func (a *Authenticator) printJSON(entry debugEntry) {
+	entry.Time = time.Now()
+	b, err := json.Marshal(entry)
+	if err != nil {
+		return
+	}
+	if a.logger != nil {
+		a.logger.Printf("%s", b)
+		return
+	}
+	fmt.Println(string(b))
+}
@@ -0,0 +1,15 @@
+//go:build go1.19
+
+package httpsign
+
+import (
+	"errors"
+	"net/http"
+)
+
+// isMaxBytesError tells whether err is returned by a body wrapped with
+// http.MaxBytesReader once the limit is exceeded
+func isMaxBytesError(err error) bool {
+	var merr *http.MaxBytesError
+	return errors.As(err, &merr)
+}
@@ -0,0 +1,10 @@
+//go:build !go1.19
+
+package httpsign
+
+// isMaxBytesError tells whether err is returned by a body wrapped with
+// http.MaxBytesReader once the limit is exceeded. http.MaxBytesError only
+// exists since Go 1.19, the error message is compared before.
+func isMaxBytesError(err error) bool {
+	return err != nil && err.Error() == "http: request body too large"
+}
@@ -0,0 +1,11 @@
+package httpsign
+
+import "time"
+
+// MetricsHooks receives the outcome of each verification of the
+// Authenticator, e.g. to export metrics. dur is the time spent verifying.
+// The hooks are called synchronously and must be safe for concurrent use.
+type MetricsHooks interface {
+	OnSuccess(keyID KeyID, dur time.Duration)
+	OnFailure(reason FailureReason, dur time.Duration)
+}
@@ -0,0 +1,32 @@
+package httpsign
+
+import (
+	"net/http"
+	"time"
+)
+
+// Middleware returns a net/http middleware performing the same checks as
+// Authenticated. Requests failing the verification are answered with the
+// status code of the error and its status text, without the reason of the
+// failure, and never reach next. The requests selected by
+// WithSkipper reach next without verification.
+func (a *Authenticator) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if a.skip(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		a.limitBody(w, r)
+		start := time.Now()
+		err := a.Verify(r)
+		a.setVerifyDurationHeader(w.Header(), time.Since(start))
+		if err != nil {
+			// The reason of the failure is only logged, the client could
+			// otherwise probe the keyIDs and the checks
+			status := StatusCode(err)
+			http.Error(w, http.StatusText(status), status)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,151 @@
+package httpsign
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stremovskyy/httpsign/validator"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMiddleware(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		body, err := ioutil.ReadAll(r.Body)
+		require.NoError(t, err)
+		_, _ = w.Write(body)
+	})
+	handler := NewAuthenticator(secrets).Middleware(mux)
+
+	req, err := http.NewRequest("POST", "/", strings.NewReader(sampleBodyContent))
+	require.NoError(t, err)
+	require.NoError(t, NewSigner(readID, secrets[readID], nil).Sign(req))
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, sampleBodyContent, w.Body.String())
+
+	req, err = http.NewRequest("POST", "/", strings.NewReader(sampleBodyContent))
+	require.NoError(t, err)
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Equal(t, http.StatusText(http.StatusUnauthorized)+"\n", w.Body.String(), "the reason is not sent")
+
+	req, err = http.NewRequest("POST", "/", strings.NewReader(sampleBodyContent))
+	require.NoError(t, err)
+	req.Header.Set(authorizationHeader, generateSignature("unknown", algoHmacSha512, submitHeader, requestBodySig))
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.NotContains(t, w.Body.String(), ErrInvalidKeyID.Error(), "the unknown keyIDs are not told apart")
+}
+
+func TestMiddlewareSkipPaths(t *testing.T) {
+	handler := NewAuthenticator(secrets, WithSkipPaths("/healthz", "/debug/")).Middleware(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}),
+	)
+
+	var tests = []struct {
+		path string
+		code int
+	}{
+		{path: "/healthz", code: http.StatusOK},
+		{path: "/healthz/deep", code: http.StatusUnauthorized},
+		{path: "/debug/pprof", code: http.StatusOK},
+		{path: "/debug", code: http.StatusUnauthorized},
+		{path: "/", code: http.StatusUnauthorized},
+	}
+	for _, tc := range tests {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest("GET", tc.path, nil))
+		assert.Equal(t, tc.code, w.Code, tc.path)
+	}
+}
+
+func TestMiddlewareTrailers(t *testing.T) {
+	checksum := TrailerHeader("X-Checksum")
+	auth := NewAuthenticator(secrets, WithValidator(&dateAlwaysValid{}), WithRequiredHeaders([]string{date, checksum}))
+	server := httptest.NewServer(auth.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := ioutil.ReadAll(r.Body)
+		require.NoError(t, err)
+		_, _ = w.Write(body)
+	})))
+	defer server.Close()
+
+	send := func(signed, sent string) *http.Response {
+		req, err := http.NewRequest("POST", server.URL, ioutil.NopCloser(strings.NewReader(sampleBodyContent)))
+		require.NoError(t, err)
+		req.ContentLength = -1
+		req.Trailer = http.Header{"X-Checksum": {signed}}
+		require.NoError(t, NewSigner(readID, secrets[readID], []string{date, checksum}).Sign(req))
+		req.Trailer.Set("X-Checksum", sent)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		return resp
+	}
+
+	resp := send("abc", "abc")
+	body, err := ioutil.ReadAll(resp.Body)
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, sampleBodyContent, string(body), "the body is restored")
+
+	resp = send("abc", "tampered")
+	_ = resp.Body.Close()
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	resp = send("abc", "")
+	_ = resp.Body.Close()
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+}
+
+func TestTrailersMaxBodySize(t *testing.T) {
+	checksum := TrailerHeader("X-Checksum")
+	auth := NewAuthenticator(secrets, WithRequiredHeaders([]string{date, checksum}),
+		WithValidator(&dateAlwaysValid{}, validator.NewDigestValidator(validator.WithMaxBodySize(4))))
+
+	req := httptest.NewRequest("POST", "/", ioutil.NopCloser(strings.NewReader(sampleBodyContent)))
+	req.ContentLength = -1
+	req.Trailer = http.Header{"X-Checksum": {"abc"}}
+	require.NoError(t, NewSigner(readID, secrets[readID], []string{date, checksum}).Sign(req))
+
+	// the validators do not run, the body is read for the trailers only
+	err := auth.VerifySignatureOnly(req)
+	assert.True(t, errors.Is(err, validator.ErrBodyTooLarge))
+	var verr *VerifyError
+	require.True(t, errors.As(err, &verr))
+	assert.Equal(t, ReasonBodyTooLarge, verr.Reason)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, StatusCode(err))
+}
+
+func TestMiddlewareMaxBodySize(t *testing.T) {
+	for _, digestValidator := range []*validator.DigestValidator{
+		validator.NewDigestValidator(validator.WithMaxBodySize(4)),
+		validator.NewDigestValidator(validator.WithMaxBodySize(4), validator.WithStreamingDigest()),
+	} {
+		handler := NewAuthenticator(secrets, WithValidator(&dateAlwaysValid{}, digestValidator)).Middleware(
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				// in streaming mode the handler reads the error
+				if _, err := ioutil.ReadAll(r.Body); errors.Is(err, validator.ErrBodyTooLarge) {
+					http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+				}
+			}),
+		)
+
+		// a chunked body has no Content-Length to reject it upfront
+		req, err := http.NewRequest("POST", "/", ioutil.NopCloser(strings.NewReader(sampleBodyContent)))
+		require.NoError(t, err)
+		req.ContentLength = -1
+		require.NoError(t, NewSigner(readID, secrets[readID], nil).Sign(req))
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code, "streaming %t", digestValidator.Streaming)
+	}
+}
@@ -3,6 +3,7 @@ package httpsign
 import (
 	"bytes"
 	"io"
+	"strings"
 )
 
 type parser struct {
@@ -33,6 +34,24 @@ func (p *parser) peekChar() byte {
 	return p.input[p.pos+1]
 }
 
+// peekNonSpace returns the first character after the current one which is not
+// a whitespace.
+func (p *parser) peekNonSpace() byte {
+	for i := p.pos + 1; i < len(p.input); i++ {
+		if !isSpace(p.input[i]) {
+			return p.input[i]
+		}
+	}
+	return 0
+}
+
+func isSpace(ch byte) bool {
+	return ch == ' ' || ch == '\t'
+}
+
+// nextParam reads the next key="value" parameter. Whitespaces around the key,
+// the = character and the quoted value are ignored. Keys are case insensitive
+// and returned in lower case.
 func (p *parser) nextParam() (string, string, error) {
 	var (
 		key       = bytes.NewBuffer(nil)
@@ -53,12 +72,16 @@ func (p *parser) nextParam() (string, string, error) {
 				return "", "", ErrUnterminatedParameter
 			}
 			p.readChar()
-			return key.String(), val.String(), nil
+			name := strings.ToLower(strings.TrimSpace(key.String()))
+			if name == "" {
+				return "", "", ErrMissingParameterName
+			}
+			return name, val.String(), nil
 		case '"':
 			if !keyParsed {
 				return "", "", ErrMissingEqualCharacter
 			}
-			if p.peekChar() != ',' && p.peekChar() != 0 {
+			if next := p.peekNonSpace(); next != ',' && next != 0 {
 				if err := val.WriteByte(p.ch); err != nil {
 					return "", "", err
 				}
@@ -69,6 +92,9 @@ func (p *parser) nextParam() (string, string, error) {
 		case '=':
 			if !keyParsed {
 				p.readChar()
+				for isSpace(p.ch) {
+					p.readChar()
+				}
 				if p.ch != '"' {
 					return "", "", ErrMissingDoubleQuote
 				}
@@ -80,6 +106,10 @@ func (p *parser) nextParam() (string, string, error) {
 			}
 			p.readChar()
 		default:
+			if valParsed && isSpace(p.ch) {
+				p.readChar()
+				continue
+			}
 			if !keyParsed {
 				if err := key.WriteByte(p.ch); err != nil {
 					return "", "", err
@@ -104,6 +134,9 @@ func (p *parser) parse() (map[string]string, error) {
 		} else if err != nil {
 			return nil, err
 		}
+		if _, ok := params[key]; ok {
+			return nil, ErrDuplicateParameter
+		}
 		params[key] = val
 	}
 }
@@ -0,0 +1,39 @@
+//go:build go1.18
+// +build go1.18
+
+package httpsign
+
+import (
+	"errors"
+	"testing"
+)
+
+func FuzzParseSignatureHeader(f *testing.F) {
+	for _, seed := range []string{
+		`keyId="rsa-key-1",algorithm="rsa-sha256",headers="(request-target) host date digest",signature="Hello world"`,
+		`keyId="rsa-key-1",algorithm"rsa-sha256"`,
+		`keyId="rsa-key-1,algorithm="rsa-sha256"`,
+		`keyId=rsa-key-1"`,
+		`keyId="a",keyId="b"`,
+		`="a"`,
+		` KeyId = "a" , signature= "b" `,
+		`keyId="a",created="x",signature="b"`,
+		`"`, `=`, `,`, ``,
+	} {
+		f.Add(seed)
+	}
+
+	known := []error{ErrMalformedSignatureHeader, ErrMissingKeyID, ErrMissingSignature}
+	f.Fuzz(func(t *testing.T, input string) {
+		s, err := parseSignatureString(input, true)
+		if err != nil {
+			for _, e := range known {
+				if errors.Is(err, e) {
+					return
+				}
+			}
+			t.Fatalf("unexpected error %v for %q", err, input)
+		}
+		_ = s.String()
+	})
+}
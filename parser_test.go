@@ -1,6 +1,7 @@
 package httpsign
 
 import (
+	"errors"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -29,22 +30,43 @@ func TestParser(t *testing.T) {
 			input: `keyId="rsa-key-1",algorithm=rsa-sha256",headers="(request-target) host date digest",signature="Hello world"`,
 			err:   ErrMissingDoubleQuote,
 		},
+		{
+			name:  `Duplicate parameter`,
+			input: `keyId="rsa-key-1",KEYID="rsa-key-2",signature="Hello world"`,
+			err:   ErrDuplicateParameter,
+		},
+		{
+			name:  `Missing parameter name`,
+			input: `keyId="rsa-key-1", ="rsa-sha256"`,
+			err:   ErrMissingParameterName,
+		},
 		{
 			name:  `empty value`,
 			input: `keyId="",algorithm="rsa-sha256",headers="(request-target) host date digest",signature="Hello world"`,
 			params: map[string]string{
-				"keyId":     "",
+				"keyid":     "",
 				"algorithm": "rsa-sha256",
 				"headers":   "(request-target) host date digest",
 				"signature": "Hello world",
 			},
 			err: nil,
 		},
+		{
+			name:  `whitespaces and mixed case names`,
+			input: ` KeyId = "rsa-key-1" , Algorithm="rsa-sha256",	headers ="(request-target) host date" ,signature= "Hello world" `,
+			params: map[string]string{
+				"keyid":     "rsa-key-1",
+				"algorithm": "rsa-sha256",
+				"headers":   "(request-target) host date",
+				"signature": "Hello world",
+			},
+			err: nil,
+		},
 		{
 			name:  `correct test`,
 			input: `keyId="rsa-key-1",algorithm="rsa-sha256",headers="(request-target) host date digest",signature="70AaN3BDO0XC9QbtgksgCy2jJvmOvshq8VmjSthdXC+sgcgrKrl9WME4DbZv4W7UZKElvCemhDLHQ1Nln9GMkQ=="`,
 			params: map[string]string{
-				"keyId":     "rsa-key-1",
+				"keyid":     "rsa-key-1",
 				"algorithm": "rsa-sha256",
 				"headers":   "(request-target) host date digest",
 				"signature": "70AaN3BDO0XC9QbtgksgCy2jJvmOvshq8VmjSthdXC+sgcgrKrl9WME4DbZv4W7UZKElvCemhDLHQ1Nln9GMkQ==",
@@ -57,6 +79,7 @@ func TestParser(t *testing.T) {
 		results, err := p.parse()
 		require.Equal(t, tc.err, err, tc.name)
 		if err != nil {
+			assert.True(t, errors.Is(err, ErrMalformedSignatureHeader), tc.name)
 			continue
 		}
 		assert.Equal(t, tc.params, results, tc.name)
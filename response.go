@@ -0,0 +1,265 @@
+package httpsign
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stremovskyy/httpsign/crypto"
+)
+
+// ResponseSigner signs the responses of the handlers, so that the clients
+// could verify them with VerifyResponse. The responses are buffered to compute
+// their digest, then sent with their Signature header.
+//
+// The covered headers are the response headers, except (request-target) and
+// host which are the ones of the request the response answers, binding the
+// response to it. Date and Digest headers are set when covered and missing.
+//
+// A negotiating ResponseSigner, see NewNegotiatingResponseSigner, signs with
+// the first of its signers the client accepts in its Accept-Signature header.
+type ResponseSigner struct {
+	signers   []*Signer
+	negotiate bool
+	fallback  NegotiationFallback
+}
+
+// NegotiationFallback selects what a negotiating ResponseSigner does when the
+// client accepts none of its keys and algorithms.
+type NegotiationFallback int
+
+const (
+	// UnsignedFallback sends the response unsigned. This is the default.
+	UnsignedFallback NegotiationFallback = iota
+	// ErrorFallback responds 406 Not Acceptable with ErrNoAcceptableSignature,
+	// or 400 Bad Request with ErrInvalidAcceptSignature when the
+	// Accept-Signature header could not be parsed.
+	ErrorFallback
+)
+
+// NewResponseSigner creates a ResponseSigner signing the responses with secret
+// on behalf of keyID. headers is the ordered list of covered fields,
+// defaultRequiredHeaders when empty. See NewSigner for the options.
+func NewResponseSigner(keyID KeyID, secret *Secret, headers []string, options ...SignerOption) *ResponseSigner {
+	return &ResponseSigner{signers: []*Signer{NewSigner(keyID, secret, headers, options...)}}
+}
+
+// NewNegotiatingResponseSigner creates a ResponseSigner choosing the signer of
+// each response from the Accept-Signature header of the request: the first
+// signer matching the keyid and alg of the most preferred signature the
+// client accepts. The covered components asked by the client are ignored,
+// the ones of the signer are used. Responses to requests without
+// Accept-Signature are signed with the first signer, fallback applies when
+// no signer is accepted.
+func NewNegotiatingResponseSigner(signers []*Signer, fallback NegotiationFallback) *ResponseSigner {
+	return &ResponseSigner{signers: signers, negotiate: true, fallback: fallback}
+}
+
+// Signed returns a gin middleware signing the responses of the next handlers.
+// Flushing the response is not supported, it is sent once the handlers
+// returned.
+func (s *ResponseSigner) Signed() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		w := &bufferedGinWriter{ResponseWriter: c.Writer, status: http.StatusOK}
+		c.Writer = w
+		c.Next()
+		c.Writer = w.ResponseWriter
+
+		if err := s.sign(c.Request, w.Header(), w.buf.Bytes()); err != nil {
+			_ = c.AbortWithError(responseErrorStatus(err), err)
+			return
+		}
+		w.ResponseWriter.WriteHeader(w.status)
+		_, _ = w.ResponseWriter.Write(w.buf.Bytes())
+	}
+}
+
+// Middleware returns a net/http middleware signing the responses of next.
+// See Signed.
+func (s *ResponseSigner) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		w := &bufferedWriter{ResponseWriter: rw, status: http.StatusOK}
+		next.ServeHTTP(w, r)
+
+		if err := s.sign(r, rw.Header(), w.buf.Bytes()); err != nil {
+			http.Error(rw, err.Error(), responseErrorStatus(err))
+			return
+		}
+		rw.WriteHeader(w.status)
+		_, _ = rw.Write(w.buf.Bytes())
+	})
+}
+
+// sign sets the Signature header of the response of r
+func (s *ResponseSigner) sign(r *http.Request, header http.Header, body []byte) error {
+	if s.negotiate {
+		header.Add("Vary", acceptSignatureHeader)
+	}
+	signer, err := s.choose(r)
+	if signer == nil {
+		return err
+	}
+	return signer.Sign(responseRequest(r, header, ioutil.NopCloser(bytes.NewReader(body))))
+}
+
+// choose returns the signer of the response of r, nil when the response is
+// sent unsigned.
+func (s *ResponseSigner) choose(r *http.Request) (*Signer, error) {
+	if !s.negotiate {
+		return s.signers[0], nil
+	}
+	accepted, err := ParseAcceptSignature(r)
+	if err == nil && len(accepted) == 0 && len(s.signers) > 0 {
+		return s.signers[0], nil
+	}
+	for i := range accepted {
+		for _, signer := range s.signers {
+			if accepted[i].accepts(signer) {
+				return signer, nil
+			}
+		}
+	}
+	if s.fallback == UnsignedFallback {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return nil, ErrNoAcceptableSignature
+}
+
+// responseErrorStatus returns the status of the response which could not be
+// signed because of err
+func responseErrorStatus(err error) int {
+	switch err {
+	case ErrInvalidAcceptSignature:
+		return http.StatusBadRequest
+	case ErrNoAcceptableSignature:
+		return http.StatusNotAcceptable
+	}
+	return http.StatusInternalServerError
+}
+
+// responseRequest returns the request the response is signed as: the method,
+// URL and host of r, which the response answers, with the response header and
+// body.
+func responseRequest(r *http.Request, header http.Header, body io.ReadCloser) *http.Request {
+	if body == nil {
+		body = http.NoBody
+	}
+	resp := &http.Request{Header: header, Body: body, ContentLength: -1}
+	if r != nil {
+		resp.Method, resp.URL, resp.Host = r.Method, r.URL, r.Host
+	}
+	if resp.URL == nil {
+		resp.URL = &url.URL{}
+	}
+	return resp
+}
+
+// VerifyResponse verifies the signature of a response signed by a
+// ResponseSigner with secret. When the digest is covered it is checked against
+// the body, which is restored to be read again. resp.Request is the request
+// the response answers, for (request-target) and host.
+//
+// options are the ones of the signer of the response, e.g.
+// WithSignerEncoding or WithSignerDigestHeader, so that the signature is
+// decoded and its signing string built the same way. WithSignatureExpiry is
+// ignored, the expires parameter is checked whatever the options.
+func VerifyResponse(resp *http.Response, secret *Secret, options ...SignerOption) error {
+	signer := NewSigner("", secret, nil, options...)
+	r := responseRequest(resp.Request, resp.Header, resp.Body)
+	r.ContentLength = resp.ContentLength
+
+	sigHeader, err := NewSignatureHeader(r)
+	if err != nil {
+		return err
+	}
+	if sigHeader.algorithm != "" && sigHeader.algorithm != secret.Algorithm.Name() {
+		return ErrIncorrectAlgorithm
+	}
+	if !sigHeader.expires.IsZero() && time.Now().After(sigHeader.expires) {
+		return ErrSignatureExpired
+	}
+	if isCovered(sigHeader.headers, signer.digestHeader) {
+		err := signer.digestValidator().Validate(r)
+		resp.Body = r.Body
+		if err != nil {
+			return err
+		}
+	}
+
+	signString, err := signer.canonicalizer().Canonicalize(r, sigHeader)
+	if err != nil {
+		return err
+	}
+	signature, err := signer.encoding.decode(sigHeader.signature)
+	if err != nil {
+		return ErrInvalidSign
+	}
+	err = secret.Algorithm.Verify(signString, signature, secret.verifyingKey())
+	if errors.Is(err, crypto.ErrInvalidSignature) {
+		return ErrInvalidSign
+	}
+	if err != nil {
+		return &SigningError{Op: "verify", KeyID: sigHeader.keyID, Err: err}
+	}
+	return nil
+}
+
+// bufferedWriter buffers the response until the handler returned
+type bufferedWriter struct {
+	http.ResponseWriter
+	status int
+	buf    bytes.Buffer
+}
+
+func (w *bufferedWriter) WriteHeader(status int) {
+	w.status = status
+}
+
+func (w *bufferedWriter) Write(b []byte) (int, error) {
+	return w.buf.Write(b)
+}
+
+// bufferedGinWriter is the gin.ResponseWriter buffering the response until
+// the handlers returned
+type bufferedGinWriter struct {
+	gin.ResponseWriter
+	status int
+	buf    bytes.Buffer
+}
+
+func (w *bufferedGinWriter) WriteHeader(status int) {
+	w.status = status
+}
+
+func (w *bufferedGinWriter) WriteHeaderNow() {}
+
+func (w *bufferedGinWriter) Write(b []byte) (int, error) {
+	return w.buf.Write(b)
+}
+
+func (w *bufferedGinWriter) WriteString(s string) (int, error) {
+	return w.buf.WriteString(s)
+}
+
+func (w *bufferedGinWriter) Status() int {
+	return w.status
+}
+
+func (w *bufferedGinWriter) Size() int {
+	return w.buf.Len()
+}
+
+func (w *bufferedGinWriter) Written() bool {
+	return false
+}
+
+// Flush does nothing, the response is sent once signed
+func (w *bufferedGinWriter) Flush() {}
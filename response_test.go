@@ -0,0 +1,187 @@
+package httpsign
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stremovskyy/httpsign/crypto"
+	"github.com/stremovskyy/httpsign/validator"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResponseSigner(t *testing.T) {
+	signer := NewResponseSigner(readID, secrets[readID], []string{requestTarget, date, digest, "content-type"})
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(signer.Signed())
+	r.GET("/items", func(c *gin.Context) {
+		c.String(http.StatusCreated, sampleBodyContent)
+	})
+	mux := http.NewServeMux()
+	mux.HandleFunc("/items", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte(sampleBodyContent))
+	})
+
+	for name, handler := range map[string]http.Handler{"gin": r, "net/http": signer.Middleware(mux)} {
+		req := httptest.NewRequest("GET", "/items", nil)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		resp := w.Result()
+		resp.Request = req
+
+		assert.Equal(t, http.StatusCreated, resp.StatusCode, name)
+		assert.NotEmpty(t, resp.Header.Get(signatureHeader), name)
+		require.NoError(t, VerifyResponse(resp, secrets[readID]), name)
+		body, err := ioutil.ReadAll(resp.Body)
+		require.NoError(t, err, name)
+		assert.Equal(t, sampleBodyContent, string(body), "%s: the body is restored", name)
+
+		resp.Body = ioutil.NopCloser(strings.NewReader("tampered"))
+		assert.Equal(t, validator.ErrInvalidDigest, VerifyResponse(resp, secrets[readID]), name)
+
+		resp.Body = ioutil.NopCloser(strings.NewReader(sampleBodyContent))
+		resp.Header.Set("Content-Type", "application/json")
+		assert.Equal(t, ErrInvalidSign, VerifyResponse(resp, secrets[readID]), name)
+
+		resp.Body = ioutil.NopCloser(strings.NewReader(sampleBodyContent))
+		resp.Header.Set("Content-Type", w.Header().Get("Content-Type"))
+		resp.Request = httptest.NewRequest("GET", "/other", nil)
+		assert.Equal(t, ErrInvalidSign, VerifyResponse(resp, secrets[readID]), "%s: the response is bound to the request", name)
+	}
+}
+
+func TestVerifyResponseSignerOptions(t *testing.T) {
+	const jsonBody = `{"b": 1, "a": 2}`
+	headers := []string{requestTarget, date, "content-type"}
+	var tests = []struct {
+		name    string
+		options []SignerOption
+		headers []string
+		// differs is whether the verification without the options fails
+		differs bool
+	}{
+		{name: "hex encoding", options: []SignerOption{WithSignerEncoding(Hex)}, differs: true},
+		{name: "strict header values", options: []SignerOption{WithSignerStrictHeaderValues(true)}},
+		{name: "path only", options: []SignerOption{WithSignerRequestTargetMode(PathOnly)}, differs: true},
+		{name: "preserved method", options: []SignerOption{WithSignerRequestTargetMethodCase(Preserve)}, differs: true},
+		{name: "digest header", options: []SignerOption{WithSignerDigestHeader("X-Digest")}, headers: []string{"x-digest"}},
+		{name: "body canonicalizer", options: []SignerOption{WithSignerBodyCanonicalizer(validator.CanonicalizeJSON)}, headers: []string{digest}, differs: true},
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/items", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(jsonBody))
+	})
+	for _, tc := range tests {
+		signer := NewResponseSigner(readID, secrets[readID], append(headers, tc.headers...), tc.options...)
+		req := httptest.NewRequest("GET", "/items?page=2", nil)
+		w := httptest.NewRecorder()
+		signer.Middleware(mux).ServeHTTP(w, req)
+		resp := w.Result()
+		resp.Request = req
+
+		require.NoError(t, VerifyResponse(resp, secrets[readID], tc.options...), tc.name)
+		if tc.differs {
+			resp.Body = ioutil.NopCloser(strings.NewReader(jsonBody))
+			assert.Error(t, VerifyResponse(resp, secrets[readID]), tc.name)
+		}
+		if len(tc.headers) > 0 {
+			resp.Body = ioutil.NopCloser(strings.NewReader(`{"b": 1, "a": 3}`))
+			assert.Equal(t, validator.ErrInvalidDigest, VerifyResponse(resp, secrets[readID], tc.options...), "%s: the digest is checked", tc.name)
+		}
+	}
+}
+
+func TestVerifyResponseErrors(t *testing.T) {
+	resp := &http.Response{Header: http.Header{}}
+	assert.Equal(t, ErrNoSignature, VerifyResponse(resp, secrets[readID]))
+
+	resp.Header.Set(signatureHeader, strings.TrimPrefix(generateSignature(readID, "rsa-sha256", []string{date}, "c2ln"), "Signature "))
+	assert.Equal(t, ErrIncorrectAlgorithm, VerifyResponse(resp, secrets[readID]))
+}
+
+func TestParseAcceptSignature(t *testing.T) {
+	r := httptest.NewRequest("GET", "/", nil)
+	accepted, err := ParseAcceptSignature(r)
+	require.NoError(t, err)
+	assert.Empty(t, accepted)
+
+	r.Header.Set(acceptSignatureHeader, `sig1=("@method" "content-digest");keyid="read";alg="rsa-v1_5-sha256", sig2=();alg="hmac-sha512"`)
+	accepted, err = ParseAcceptSignature(r)
+	require.NoError(t, err)
+	assert.Equal(t, []AcceptSignature{
+		{Label: "sig1", KeyID: readID, Algorithm: "rsa-sha256", Components: []string{"@method", "content-digest"}},
+		{Label: "sig2", Algorithm: algoHmacSha512},
+	}, accepted)
+
+	for _, header := range []string{`sig1="token"`, `sig1=(1 2)`, `sig1=();keyid=1`, `sig1=();alg=?1`, `sig1=(`} {
+		r.Header.Set(acceptSignatureHeader, header)
+		_, err = ParseAcceptSignature(r)
+		assert.Equal(t, ErrInvalidAcceptSignature, err, header)
+	}
+}
+
+func TestNegotiatingResponseSigner(t *testing.T) {
+	sha256Secret := &Secret{Key: "1234", Algorithm: &crypto.HmacSha256{}}
+	headers := []string{requestTarget, date, digest}
+	signers := []*Signer{
+		NewSigner(readID, secrets[readID], headers),
+		NewSigner(readID, sha256Secret, headers),
+		NewSigner(writeID, secrets[writeID], headers),
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(sampleBodyContent))
+	})
+
+	tests := []struct {
+		name   string
+		accept string
+		status int
+		secret *Secret
+		keyID  KeyID
+	}{
+		{name: "no header", status: http.StatusOK, secret: secrets[readID], keyID: readID},
+		{name: "algorithm", accept: `sig1=();alg="hmac-sha256"`, status: http.StatusOK, secret: sha256Secret, keyID: readID},
+		{name: "key", accept: `sig1=();keyid="write"`, status: http.StatusOK, secret: secrets[writeID], keyID: writeID},
+		{name: "preference", accept: `sig1=();alg="ed25519", sig2=();keyid="write";alg="hmac-sha512", sig3=()`, status: http.StatusOK, secret: secrets[writeID], keyID: writeID},
+		{name: "no overlap", accept: `sig1=();alg="ed25519"`, status: http.StatusNotAcceptable},
+		{name: "invalid", accept: `sig1="token"`, status: http.StatusBadRequest},
+	}
+	for _, fallback := range []NegotiationFallback{UnsignedFallback, ErrorFallback} {
+		handler := NewNegotiatingResponseSigner(signers, fallback).Middleware(mux)
+		for _, tt := range tests {
+			req := httptest.NewRequest("GET", "/", nil)
+			if tt.accept != "" {
+				req.Header.Set(acceptSignatureHeader, tt.accept)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+			resp := w.Result()
+			resp.Request = req
+			assert.Equal(t, acceptSignatureHeader, resp.Header.Get("Vary"), tt.name)
+
+			if tt.secret == nil && fallback == UnsignedFallback {
+				assert.Equal(t, http.StatusOK, resp.StatusCode, tt.name)
+				assert.Empty(t, resp.Header.Get(signatureHeader), "%s: the response is unsigned", tt.name)
+				continue
+			}
+			require.Equal(t, tt.status, resp.StatusCode, tt.name)
+			if tt.secret == nil {
+				continue
+			}
+			sigHeader, err := NewSignatureHeader(responseRequest(req, resp.Header, nil))
+			require.NoError(t, err, tt.name)
+			assert.Equal(t, tt.keyID, sigHeader.keyID, tt.name)
+			assert.NoError(t, VerifyResponse(resp, tt.secret), tt.name)
+		}
+	}
+}
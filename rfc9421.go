@@ -0,0 +1,278 @@
+package httpsign
+
+import (
+	"encoding/asn1"
+	"encoding/base64"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// SignatureFormat is the wire format of the signature carried by requests.
+type SignatureFormat int
+
+const (
+	// Cavage is the format of draft-cavage-http-signatures: a single
+	// Signature (or Authorization: Signature) header. This is the default.
+	Cavage SignatureFormat = iota
+	// RFC9421 is the format of RFC 9421 HTTP Message Signatures: the
+	// Signature-Input and Signature structured field headers.
+	RFC9421
+	// JWS is a detached JWS (RFC 7515) in the X-JWS-Signature header. Its
+	// protected header holds the alg, the kid and the headers covered by the
+	// signature, and the payload is the Cavage signing string of these
+	// headers.
+	JWS
+)
+
+// SignaturePolicy selects the RFC 9421 signatures verified when a request
+// carries several, e.g. one of the client and one of an intermediary.
+type SignaturePolicy int
+
+const (
+	// FirstSignature verifies the first signature of Signature-Input and
+	// ignores the others. This is the default.
+	FirstSignature SignaturePolicy = iota
+	// AllSignatures requires all the signatures to be valid.
+	AllSignatures
+	// AnySignature requires at least one valid signature. The labels of the
+	// valid ones are available with LabelsFromContext.
+	AnySignature
+)
+
+const (
+	signatureInputHeader = "Signature-Input"
+
+	rfc9421KeyID   = "keyid"
+	rfc9421Alg     = "alg"
+	rfc9421Created = "created"
+	rfc9421Expires = "expires"
+
+	rfc9421SignatureParams = "@signature-params"
+)
+
+// Derived components of RFC 9421
+const (
+	componentMethod        = "@method"
+	componentTargetURI     = "@target-uri"
+	componentAuthority     = "@authority"
+	componentScheme        = "@scheme"
+	componentRequestTarget = "@request-target"
+	componentPath          = "@path"
+	componentQuery         = "@query"
+)
+
+var defaultRFC9421RequiredHeaders = []string{componentMethod, componentRequestTarget, date, digest}
+
+// rfc9421Algorithms maps the algorithm names registered by RFC 9421 to the
+// names of the algorithms of the crypto package.
+var rfc9421Algorithms = map[string]string{
+	"rsa-v1_5-sha256":   "rsa-sha256",
+	"ecdsa-p256-sha256": "ecdsa-sha256",
+	"ecdsa-p384-sha384": "ecdsa-sha384",
+}
+
+// parseRFC9421Request parses the Signature-Input and Signature headers of the
+// request. The first signature of Signature-Input is used. The keyid
+// parameter may be missing unless requireKeyID is set, see WithKeyIDResolver.
+func parseRFC9421Request(r *http.Request, requireKeyID bool) (*SignatureHeader, error) {
+	sigHeaders, err := parseRFC9421Headers(r, 1, requireKeyID)
+	if err != nil {
+		return nil, err
+	}
+	return sigHeaders[0], nil
+}
+
+// parseRFC9421Signatures parses all the signatures of the Signature-Input and
+// Signature headers of the request, in the order of Signature-Input.
+func parseRFC9421Signatures(r *http.Request, requireKeyID bool) ([]*SignatureHeader, error) {
+	return parseRFC9421Headers(r, -1, requireKeyID)
+}
+
+// parseRFC9421Headers parses the n first signatures of the request, all of
+// them when n is negative.
+func parseRFC9421Headers(r *http.Request, n int, requireKeyID bool) ([]*SignatureHeader, error) {
+	input := strings.Join(r.Header.Values(signatureInputHeader), ", ")
+	if input == "" {
+		return nil, ErrNoSignature
+	}
+	inputs, err := parseDictionary(input)
+	if err != nil || len(inputs) == 0 {
+		return nil, ErrInvalidSignatureInput
+	}
+	signatures, err := parseDictionary(strings.Join(r.Header.Values(signatureHeader), ", "))
+	if err != nil {
+		return nil, ErrMissingSignature
+	}
+	if n < 0 || n > len(inputs) {
+		n = len(inputs)
+	}
+
+	sigHeaders := make([]*SignatureHeader, 0, n)
+	for i := range inputs[:n] {
+		sigHeader, err := parseSignatureInput(&inputs[i], requireKeyID)
+		if err != nil {
+			return nil, err
+		}
+		if sigHeader.signature, err = findRFC9421Signature(signatures, sigHeader.label); err != nil {
+			return nil, err
+		}
+		sigHeaders = append(sigHeaders, sigHeader)
+	}
+	return sigHeaders, nil
+}
+
+// findRFC9421Signature returns the base64 encoded signature labeled label
+func findRFC9421Signature(signatures []sfMember, label string) (string, error) {
+	for _, s := range signatures {
+		if s.key != label {
+			continue
+		}
+		signature, ok := s.item.value.([]byte)
+		if s.isList || !ok {
+			return "", ErrMissingSignature
+		}
+		return base64.StdEncoding.EncodeToString(signature), nil
+	}
+	return "", ErrMissingSignature
+}
+
+func parseSignatureInput(input *sfMember, requireKeyID bool) (*SignatureHeader, error) {
+	if !input.isList {
+		return nil, ErrInvalidSignatureInput
+	}
+
+	sigHeader := &SignatureHeader{
+		label:  input.key,
+		params: input.raw,
+	}
+	for _, item := range input.list {
+		component, ok := item.value.(string)
+		if !ok || len(item.params) > 0 {
+			return nil, ErrInvalidSignatureInput
+		}
+		sigHeader.headers = append(sigHeader.headers, component)
+	}
+
+	for _, p := range input.params {
+		switch p.key {
+		case rfc9421KeyID:
+			keyID, ok := p.value.(string)
+			if !ok {
+				return nil, ErrInvalidSignatureInput
+			}
+			sigHeader.keyID = KeyID(keyID)
+		case rfc9421Alg:
+			alg, ok := p.value.(string)
+			if !ok {
+				return nil, ErrInvalidSignatureInput
+			}
+			if name, ok := rfc9421Algorithms[alg]; ok {
+				alg = name
+			}
+			sigHeader.algorithm = alg
+		case rfc9421Created, rfc9421Expires:
+			ts, ok := p.value.(int64)
+			if !ok {
+				return nil, ErrInvalidSignatureInput
+			}
+			if p.key == rfc9421Created {
+				sigHeader.created = time.Unix(ts, 0)
+			} else {
+				sigHeader.expires = time.Unix(ts, 0)
+			}
+		}
+	}
+
+	if sigHeader.keyID == "" && requireKeyID {
+		return nil, ErrMissingKeyID
+	}
+	return sigHeader, nil
+}
+
+// RFC9421Canonicalizer builds the signature base of RFC 9421 section 2.5:
+// a "component": value line for each covered component, lowercased and
+// quoted, followed by the "@signature-params" line, separated by \n.
+type RFC9421Canonicalizer struct{}
+
+// Canonicalize returns the signature base of the components covered by
+// sigHeader.
+func (RFC9421Canonicalizer) Canonicalize(r *http.Request, sigHeader *SignatureHeader) (string, error) {
+	signBuffer := getSignBuffer()
+	defer putSignBuffer(signBuffer)
+
+	for _, component := range sigHeader.headers {
+		value, err := rfc9421ComponentValue(r, component)
+		if err != nil {
+			return "", err
+		}
+		signBuffer.WriteString(fmt.Sprintf("%q: %s\n", component, value))
+	}
+	signBuffer.WriteString(fmt.Sprintf("%q: %s", rfc9421SignatureParams, sigHeader.params))
+
+	return signBuffer.String(), nil
+}
+
+func rfc9421ComponentValue(r *http.Request, component string) (string, error) {
+	switch component {
+	case componentMethod:
+		return r.Method, nil
+	case componentTargetURI:
+		return fmt.Sprintf("%s://%s%s", requestScheme(r), requestAuthority(r), r.URL.RequestURI()), nil
+	case componentAuthority:
+		return requestAuthority(r), nil
+	case componentScheme:
+		return requestScheme(r), nil
+	case componentRequestTarget:
+		return r.URL.RequestURI(), nil
+	case componentPath:
+		if path := r.URL.EscapedPath(); path != "" {
+			return path, nil
+		}
+		return "/", nil
+	case componentQuery:
+		return "?" + r.URL.RawQuery, nil
+	}
+	if strings.HasPrefix(component, "@") || component != strings.ToLower(component) {
+		return "", ErrUnsupportedComponent
+	}
+
+	if len(r.Header.Values(component)) == 0 {
+		return "", newMissingHeaderError(component)
+	}
+	return headerValue(r.Header, component, false), nil
+}
+
+func requestScheme(r *http.Request) string {
+	if r.URL.Scheme != "" {
+		return strings.ToLower(r.URL.Scheme)
+	}
+	if r.TLS != nil {
+		return "https"
+	}
+	return "http"
+}
+
+func requestAuthority(r *http.Request) string {
+	if r.Host != "" {
+		return strings.ToLower(r.Host)
+	}
+	return strings.ToLower(r.URL.Host)
+}
+
+// ecdsaSignatureToASN1 converts the fixed size r||s ECDSA signatures used by
+// RFC 9421 and JWS to the ASN.1 encoding expected by the crypto package.
+func ecdsaSignatureToASN1(signature []byte) ([]byte, error) {
+	if len(signature) == 0 || len(signature)%2 != 0 {
+		return nil, ErrInvalidSign
+	}
+	size := len(signature) / 2
+	return asn1.Marshal(struct {
+		R, S *big.Int
+	}{
+		R: new(big.Int).SetBytes(signature[:size]),
+		S: new(big.Int).SetBytes(signature[size:]),
+	})
+}
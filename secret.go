@@ -1,15 +1,212 @@
 package httpsign
 
-import "github.com/stremovskyy/httpsign/crypto"
+import (
+	"context"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"time"
+
+	"github.com/stremovskyy/httpsign/crypto"
+)
 
 // KeyID define type
 type KeyID string
 
-// Secret define secret key and algorithm that key use
+// Secret define secret key and algorithm that key use.
+//
+// For the hmac algorithms Key is the shared secret used both to sign and to
+// verify, PublicKey is not used.
+// For the asymmetric algorithms (rsa, ecdsa and ed25519) Key is the private key
+// used by the Signer and PublicKey is the public key used by the Authenticator
+// to verify signatures, so servers never need to hold the private key. When
+// PublicKey is empty the public key is derived from Key.
 type Secret struct {
 	Key       string
+	PublicKey string
 	Algorithm crypto.Crypto
+
+	// rotated are the other secrets of the keyID, see Secrets.Add
+	rotated []*Secret
+	// validUntil is the cutover of a secret added with Secrets.AddTimed
+	validUntil time.Time
+}
+
+// TimedSecret is a secret accepted only for the requests dated before
+// ValidUntil, see Secrets.AddTimed.
+type TimedSecret struct {
+	Secret     *Secret
+	ValidUntil time.Time
+}
+
+// SecretProvider looks the secrets up by keyID, e.g. from a database or a KMS.
+// Get returns ErrInvalidKeyID, or a nil Secret, when keyID is unknown. Any
+// other error fails the verification with ReasonInternal.
+type SecretProvider interface {
+	Get(ctx context.Context, keyID KeyID) (*Secret, error)
 }
 
 // Secrets map with keyID and secret
 type Secrets map[KeyID]*Secret
+
+// Get returns the secret of keyID, it makes Secrets a SecretProvider.
+func (s Secrets) Get(_ context.Context, keyID KeyID) (*Secret, error) {
+	secret, ok := s[keyID]
+	if !ok {
+		return nil, ErrInvalidKeyID
+	}
+	return secret, nil
+}
+
+// Add adds secret to the secrets of keyID. When keyID already has a secret,
+// both are accepted by the Authenticator, which allows to rotate keys: add the
+// new secret, move the clients to it, then keep only the new one with
+// secrets[keyID] = secret.
+// Secrets are not safe for concurrent use, Add must not be called while an
+// Authenticator is verifying requests with them: use RotatingSecrets to rotate
+// the keys while serving.
+func (s Secrets) Add(keyID KeyID, secret *Secret) {
+	current, ok := s[keyID]
+	if !ok {
+		s[keyID] = secret
+		return
+	}
+	merged := *current
+	merged.rotated = append(append([]*Secret{}, current.rotated...), secret)
+	s[keyID] = &merged
+}
+
+// AddTimed adds secret to the secrets of keyID like Add, but the secret is
+// only accepted for the requests dated before secret.ValidUntil. It rotates
+// the HMAC shared secrets without accepting the old one indefinitely: set
+// the new secret with secrets[keyID] = secret, then add the old one with the
+// cutover date.
+//
+// The date of a request is only taken from the values checked by the
+// validators: the created parameter when the signature covers it and a
+// validator.CreatedValidator checks it, else the date header when the
+// signature covers it and a validator.DateValidator checks it. Requests
+// without such a date are never verified with a timed secret, nor the
+// requests verified by VerifySignatureOnly or in HistoricalMode, whose time
+// checks are skipped.
+func (s Secrets) AddTimed(keyID KeyID, secret TimedSecret) {
+	timed := *secret.Secret
+	timed.rotated = nil
+	timed.validUntil = secret.ValidUntil
+	s.Add(keyID, &timed)
+}
+
+// Validate returns an error when the algorithm of a secret, rotated secrets
+// included, is missing or unknown: neither registered with crypto.Register
+// nor crypto.HmacSha1. See MustNewAuthenticator.
+func (s Secrets) Validate() error {
+	for keyID, secret := range s {
+		if secret == nil {
+			return fmt.Errorf("httpsign: secret %s is nil", keyID)
+		}
+		for _, secret := range secret.all() {
+			if err := checkAlgorithm(secret.Algorithm); err != nil {
+				return fmt.Errorf("httpsign: secret %s: %w", keyID, err)
+			}
+		}
+	}
+	return nil
+}
+
+// checkAlgorithm returns an error when algorithm is missing or unknown
+func checkAlgorithm(algorithm crypto.Crypto) error {
+	if algorithm == nil {
+		return errors.New("missing algorithm")
+	}
+	if _, ok := crypto.AlgorithmFromName(algorithm.Name()); ok {
+		return nil
+	}
+	// HmacSha1 is not registered so that it is only used explicitly
+	if _, ok := algorithm.(*crypto.HmacSha1); ok {
+		return nil
+	}
+	return fmt.Errorf("%w: %q", crypto.ErrUnknownAlgorithm, algorithm.Name())
+}
+
+// NewSecretFromPEM creates a Secret from a PEM encoded private key. PKCS#1 and
+// PKCS#8 keys are supported. algName selects the algorithm using the key,
+// e.g. rsa-sha256 or ed25519, among the algorithms registered with
+// crypto.Register. When algName is empty the default algorithm of the key
+// type is used, see crypto.DefaultAlgorithm. Algorithms incompatible with the
+// key type, like hmac-sha256 with a RSA key, are rejected.
+func NewSecretFromPEM(pemBytes []byte, algName string) (*Secret, error) {
+	if algName == "" {
+		name, err := crypto.DefaultAlgorithm(string(pemBytes))
+		if err != nil {
+			return nil, fmt.Errorf("httpsign: %w", err)
+		}
+		algName = name
+	}
+	algorithm, err := crypto.Get(algName)
+	if err != nil {
+		return nil, fmt.Errorf("httpsign: %w", err)
+	}
+
+	block, _ := pem.Decode(pemBytes)
+	if block == nil {
+		return nil, fmt.Errorf("httpsign: no PEM block found for %s key", algName)
+	}
+
+	secret := &Secret{
+		Key:       string(pemBytes),
+		Algorithm: algorithm,
+	}
+	// Signing once makes sure the key is of a type usable by the algorithm, so
+	// that configuration errors are reported here instead of on each request.
+	if _, err := algorithm.Sign("", secret.Key); err != nil {
+		return nil, fmt.Errorf("httpsign: unsupported %s key in %q PEM block: %w", algName, block.Type, err)
+	}
+	if err := crypto.CheckKeyType(algorithm, secret.Key); err != nil {
+		return nil, fmt.Errorf("httpsign: %w", err)
+	}
+	return secret, nil
+}
+
+// NewSecretFromEncryptedPEM creates a Secret from a PEM encoded private key
+// encrypted with passphrase, see crypto.DecryptPEM for the supported
+// encryptions, and NewSecretFromPEM for algName. The error wraps
+// crypto.ErrIncorrectPassphrase when passphrase does not decrypt the key, and
+// crypto.ErrInvalidKey when the PEM is malformed. The Secret holds the
+// decrypted key.
+func NewSecretFromEncryptedPEM(pemBytes []byte, passphrase string, algName string) (*Secret, error) {
+	decrypted, err := crypto.DecryptPEM(pemBytes, passphrase)
+	if err != nil {
+		return nil, fmt.Errorf("httpsign: %w", err)
+	}
+	return NewSecretFromPEM(decrypted, algName)
+}
+
+// NewSecretFromPEMFile creates a Secret from a PEM encoded private key file.
+// See NewSecretFromPEM.
+func NewSecretFromPEMFile(path string, algName string) (*Secret, error) {
+	pemBytes, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return NewSecretFromPEM(pemBytes, algName)
+}
+
+// all returns the secret and its rotated secrets
+func (s *Secret) all() []*Secret {
+	return append([]*Secret{s}, s.rotated...)
+}
+
+// validAt reports whether the secret is accepted for a request dated date,
+// see Secrets.AddTimed
+func (s *Secret) validAt(date time.Time, dated bool) bool {
+	return s.validUntil.IsZero() || dated && date.Before(s.validUntil)
+}
+
+// verifyingKey returns the key used to verify signatures
+func (s *Secret) verifyingKey() string {
+	if s.PublicKey != "" {
+		return s.PublicKey
+	}
+	return s.Key
+}
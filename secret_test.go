@@ -0,0 +1,329 @@
+package httpsign
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stremovskyy/httpsign/crypto"
+	"github.com/stremovskyy/httpsign/validator"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func generateRSAKeyPEM(t *testing.T) (pkcs1 []byte, pkcs8 []byte) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+	pkcs1 = pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	pkcs8 = pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+	return pkcs1, pkcs8
+}
+
+func TestNewSecretFromPEM(t *testing.T) {
+	pkcs1, pkcs8 := generateRSAKeyPEM(t)
+
+	for name, pemBytes := range map[string][]byte{"pkcs1": pkcs1, "pkcs8": pkcs8} {
+		secret, err := NewSecretFromPEM(pemBytes, "rsa-sha256")
+		require.NoError(t, err, name)
+		assert.Equal(t, "rsa-sha256", secret.Algorithm.Name(), name)
+
+		auth := NewAuthenticator(Secrets{readID: secret})
+		req, err := http.NewRequest("GET", "/", nil)
+		require.NoError(t, err, name)
+		require.NoError(t, NewSigner(readID, secret, nil).Sign(req), name)
+		assert.NoError(t, auth.Verify(req), name)
+	}
+}
+
+func TestNewSecretFromPEMFile(t *testing.T) {
+	_, pkcs8 := generateRSAKeyPEM(t)
+	path := filepath.Join(t.TempDir(), "key.pem")
+	require.NoError(t, ioutil.WriteFile(path, pkcs8, 0600))
+
+	secret, err := NewSecretFromPEMFile(path, "rsa-sha256")
+	require.NoError(t, err)
+	assert.Equal(t, string(pkcs8), secret.Key)
+
+	_, err = NewSecretFromPEMFile(filepath.Join(t.TempDir(), "missing.pem"), "rsa-sha256")
+	assert.Error(t, err)
+}
+
+func TestNewSecretFromPEMErrors(t *testing.T) {
+	_, pkcs8 := generateRSAKeyPEM(t)
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	der, err := x509.MarshalPKCS8PrivateKey(edKey)
+	require.NoError(t, err)
+	edPEM := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+
+	_, err = NewSecretFromPEM(pkcs8, "unknown")
+	assert.True(t, errors.Is(err, crypto.ErrUnknownAlgorithm))
+
+	_, err = NewSecretFromPEM([]byte("not a pem"), "rsa-sha256")
+	assert.EqualError(t, err, "httpsign: no PEM block found for rsa-sha256 key")
+
+	_, err = NewSecretFromPEM(edPEM, "rsa-sha256")
+	assert.Contains(t, err.Error(), "is not a rsa private key")
+
+	secret, err := NewSecretFromPEM(edPEM, "ed25519")
+	require.NoError(t, err)
+	assert.Equal(t, "ed25519", secret.Algorithm.Name())
+}
+
+func TestNewSecretFromEncryptedPEM(t *testing.T) {
+	pkcs1, _ := generateRSAKeyPEM(t)
+	block, _ := pem.Decode(pkcs1)
+	encrypted, err := x509.EncryptPEMBlock(rand.Reader, block.Type, block.Bytes, []byte("passphrase"), x509.PEMCipherAES256)
+	require.NoError(t, err)
+	encryptedPEM := pem.EncodeToMemory(encrypted)
+
+	secret, err := NewSecretFromEncryptedPEM(encryptedPEM, "passphrase", "")
+	require.NoError(t, err)
+	assert.Equal(t, "rsa-sha256", secret.Algorithm.Name())
+	assert.Equal(t, string(pkcs1), secret.Key)
+
+	req, err := http.NewRequest("GET", "/", nil)
+	require.NoError(t, err)
+	require.NoError(t, NewSigner(readID, secret, []string{date}).Sign(req))
+	auth := NewAuthenticator(Secrets{readID: secret}, WithRequiredHeaders([]string{date}), WithValidator(&dateAlwaysValid{}))
+	assert.NoError(t, auth.Verify(req))
+
+	_, err = NewSecretFromEncryptedPEM(encryptedPEM, "wrong", "")
+	assert.True(t, errors.Is(err, crypto.ErrIncorrectPassphrase))
+	_, err = NewSecretFromEncryptedPEM([]byte("not a pem"), "passphrase", "")
+	assert.True(t, errors.Is(err, crypto.ErrInvalidKey))
+	assert.False(t, errors.Is(err, crypto.ErrIncorrectPassphrase))
+}
+
+func TestNewSecretFromPEMDefaultAlgorithm(t *testing.T) {
+	_, pkcs8 := generateRSAKeyPEM(t)
+	secret, err := NewSecretFromPEM(pkcs8, "")
+	require.NoError(t, err)
+	assert.Equal(t, "rsa-sha256", secret.Algorithm.Name())
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	der, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+	secret, err = NewSecretFromPEM(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der}), "")
+	require.NoError(t, err)
+	assert.Equal(t, "ecdsa-sha256", secret.Algorithm.Name())
+
+	_, err = NewSecretFromPEM(pkcs8, "hmac-sha256")
+	assert.True(t, errors.Is(err, crypto.ErrIncompatibleKey))
+	_, err = NewSecretFromPEM([]byte("not a pem"), "")
+	assert.True(t, errors.Is(err, crypto.ErrInvalidKey))
+}
+
+func TestNewSecretFromPEMECDSA(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	der, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+	secret, err := NewSecretFromPEM(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der}), "ecdsa-sha256")
+	require.NoError(t, err)
+
+	auth := NewAuthenticator(Secrets{readID: secret})
+	req, err := http.NewRequest("POST", "/", strings.NewReader(sampleBodyContent))
+	require.NoError(t, err)
+	require.NoError(t, NewSigner(readID, secret, nil).Sign(req))
+	assert.NoError(t, auth.Verify(req))
+
+	req.Header.Set("Date", time.Now().Add(time.Second).UTC().Format(http.TimeFormat))
+	assert.True(t, errors.Is(auth.Verify(req), ErrInvalidSign))
+}
+
+func TestSecretPublicKeyOnlyVerifies(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	require.NoError(t, err)
+	privDER, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+	pubDER, err := x509.MarshalPKIXPublicKey(key.Public())
+	require.NoError(t, err)
+
+	signingSecret, err := NewSecretFromPEM(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privDER}), "ecdsa-sha384")
+	require.NoError(t, err)
+	verifyingSecret := &Secret{
+		PublicKey: string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pubDER})),
+		Algorithm: signingSecret.Algorithm,
+	}
+
+	req, err := http.NewRequest("GET", "/", nil)
+	require.NoError(t, err)
+	require.NoError(t, NewSigner(readID, signingSecret, nil).Sign(req))
+	assert.NoError(t, NewAuthenticator(Secrets{readID: verifyingSecret}).Verify(req))
+
+	assert.Error(t, NewSigner(readID, verifyingSecret, nil).Sign(req))
+}
+
+func TestSecretsAddRotation(t *testing.T) {
+	oldSecret := &Secret{Key: "old", Algorithm: &crypto.HmacSha512{}}
+	newSecret := &Secret{Key: "new", Algorithm: &crypto.HmacSha512{}}
+	otherSecret := &Secret{Key: "other", Algorithm: &crypto.HmacSha512{}}
+
+	rotating := Secrets{}
+	rotating.Add(readID, oldSecret)
+	assert.Same(t, oldSecret, rotating[readID])
+	rotating.Add(readID, newSecret)
+	assert.Empty(t, oldSecret.rotated, "Add must not modify the added secrets")
+
+	auth := NewAuthenticator(rotating, WithValidator(&dateAlwaysValid{}))
+	for _, secret := range []*Secret{oldSecret, newSecret} {
+		req, err := http.NewRequest("GET", "/", nil)
+		require.NoError(t, err)
+		require.NoError(t, NewSigner(readID, secret, nil).Sign(req))
+		assert.NoError(t, auth.Verify(req), secret.Key)
+	}
+
+	req, err := http.NewRequest("GET", "/", nil)
+	require.NoError(t, err)
+	require.NoError(t, NewSigner(readID, otherSecret, nil).Sign(req))
+	assert.True(t, errors.Is(auth.Verify(req), ErrInvalidSign))
+
+	rotating[readID] = newSecret
+	require.NoError(t, NewSigner(readID, oldSecret, nil).Sign(req))
+	assert.True(t, errors.Is(auth.Verify(req), ErrInvalidSign))
+}
+
+type secretProviderFunc func(ctx context.Context, keyID KeyID) (*Secret, error)
+
+func (f secretProviderFunc) Get(ctx context.Context, keyID KeyID) (*Secret, error) {
+	return f(ctx, keyID)
+}
+
+func TestSecretsAddTimed(t *testing.T) {
+	oldSecret := &Secret{Key: "old", Algorithm: &crypto.HmacSha512{}}
+	newSecret := &Secret{Key: "new", Algorithm: &crypto.HmacSha512{}}
+	cutover := time.Now().Truncate(time.Second)
+	before, after := cutover.Add(-time.Hour), cutover.Add(time.Hour)
+
+	rotating := Secrets{readID: newSecret}
+	rotating.AddTimed(readID, TimedSecret{Secret: oldSecret, ValidUntil: cutover})
+	assert.True(t, oldSecret.validUntil.IsZero(), "AddTimed must not modify the added secret")
+
+	withDate := []string{requestTarget, date}
+	withCreated := []string{requestTarget, date, created}
+	dateChecked := func(now time.Time) validator.Validator {
+		return validator.NewDateValidator(validator.WithClock(func() time.Time { return now }))
+	}
+	createdChecked := func(now time.Time) validator.Validator {
+		v := validator.NewCreatedValidator(time.Minute, time.Minute)
+		v.Clock = func() time.Time { return now }
+		return v
+	}
+	unchecked := func(time.Time) validator.Validator { return &dateAlwaysValid{} }
+
+	var tests = []struct {
+		name      string
+		secret    *Secret
+		now       time.Time
+		headers   []string
+		created   time.Time
+		validator func(time.Time) validator.Validator
+		valid     bool
+	}{
+		{name: "new after cutover", secret: newSecret, now: after, headers: withDate, validator: dateChecked, valid: true},
+		{name: "old before cutover", secret: oldSecret, now: before, headers: withDate, validator: dateChecked, valid: true},
+		{name: "old after cutover", secret: oldSecret, now: after, headers: withDate, validator: dateChecked},
+		{name: "old with a backdated uncovered created", secret: oldSecret, now: after, headers: withDate, created: before, validator: dateChecked},
+		{name: "old with covered created", secret: oldSecret, now: before, headers: withCreated, created: before, validator: createdChecked, valid: true},
+		{name: "old with covered created after cutover", secret: oldSecret, now: after, headers: withCreated, created: after, validator: createdChecked},
+		{name: "old with unchecked date", secret: oldSecret, now: before, headers: withDate, validator: unchecked},
+	}
+	for _, tc := range tests {
+		req, err := http.NewRequest("GET", "/", nil)
+		require.NoError(t, err, tc.name)
+		req.Header.Set("Date", tc.now.UTC().Format(http.TimeFormat))
+		sigHeader := &SignatureHeader{keyID: readID, algorithm: algoHmacSha512, headers: tc.headers, created: tc.created}
+		signString, err := constructSignMessage(req, sigHeader)
+		require.NoError(t, err, tc.name)
+		signature, err := tc.secret.Algorithm.Sign(signString, tc.secret.Key)
+		require.NoError(t, err, tc.name)
+		sigHeader.signature = Base64.encode(signature)
+		req.Header.Set(signatureHeader, sigHeader.String())
+
+		auth := NewAuthenticator(rotating, WithRequiredHeaders(withDate), WithValidator(tc.validator(tc.now)))
+		if tc.valid {
+			assert.NoError(t, auth.Verify(req), tc.name)
+		} else {
+			assert.True(t, errors.Is(auth.Verify(req), ErrInvalidSign), tc.name)
+		}
+	}
+}
+
+func TestSecretProvider(t *testing.T) {
+	revoked := false
+	backendDown := errors.New("backend down")
+	var providerErr error
+	provider := secretProviderFunc(func(ctx context.Context, keyID KeyID) (*Secret, error) {
+		if providerErr != nil {
+			return nil, providerErr
+		}
+		if keyID != readID || revoked {
+			return nil, nil
+		}
+		return secrets[readID], nil
+	})
+	auth := NewAuthenticator(nil, WithSecretProvider(provider), WithValidator(&dateAlwaysValid{}))
+
+	req, err := http.NewRequest("GET", "/", nil)
+	require.NoError(t, err)
+	require.NoError(t, NewSigner(readID, secrets[readID], nil).Sign(req))
+	assert.NoError(t, auth.Verify(req))
+
+	revoked = true
+	var verr *VerifyError
+	require.True(t, errors.As(auth.Verify(req), &verr))
+	assert.Equal(t, ReasonUnknownKeyID, verr.Reason)
+
+	providerErr = backendDown
+	err = auth.Verify(req)
+	assert.True(t, errors.Is(err, backendDown))
+	assert.Equal(t, http.StatusInternalServerError, StatusCode(err))
+
+	_, err = secrets.Get(context.Background(), "unknown")
+	assert.Equal(t, ErrInvalidKeyID, err)
+}
+
+// unregisteredHmac is an algorithm not registered with crypto.Register
+type unregisteredHmac struct {
+	crypto.HmacSha256
+}
+
+func (*unregisteredHmac) Name() string {
+	return "unregistered-hmac"
+}
+
+func TestSecretsValidate(t *testing.T) {
+	assert.NoError(t, secrets.Validate())
+	assert.NoError(t, Secrets{readID: &Secret{Key: "1234", Algorithm: &crypto.HmacSha1{}}}.Validate())
+
+	unknown := &Secret{Key: "1234", Algorithm: &unregisteredHmac{}}
+	err := Secrets{readID: unknown}.Validate()
+	assert.True(t, errors.Is(err, crypto.ErrUnknownAlgorithm))
+	assert.Contains(t, err.Error(), "secret read")
+
+	rotated := Secrets{readID: &Secret{Key: "1234", Algorithm: hmacsha512}}
+	rotated.Add(readID, &Secret{Key: "5678"})
+	assert.EqualError(t, rotated.Validate(), "httpsign: secret read: missing algorithm")
+
+	assert.NotPanics(t, func() { MustNewAuthenticator(secrets) })
+	assert.Panics(t, func() { MustNewAuthenticator(Secrets{readID: unknown}) })
+	assert.NotPanics(t, func() {
+		MustNewAuthenticator(nil, WithSecretProvider(NewPrefixSecrets(Secrets{readID: unknown})))
+	}, "only Secrets are checked")
+}
@@ -0,0 +1,170 @@
+package httpsign
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// CachingSecretProvider is a SecretProvider caching the secrets of another
+// provider. Concurrent lookups of the same keyID are collapsed into a single
+// call to the inner provider.
+type CachingSecretProvider struct {
+	inner      SecretProvider
+	ttl        time.Duration
+	now        func() time.Time
+	maxUnknown int
+
+	mu      sync.Mutex
+	entries map[KeyID]cachedSecret
+	unknown int
+	calls   map[KeyID]*secretCall
+}
+
+const (
+	// maxUnknownKeyIDs bounds the unknown keyIDs cached by a
+	// CachingSecretProvider, clients can send any number of them.
+	maxUnknownKeyIDs = 1024
+	// secretLookupTimeout bounds a lookup of the inner provider, it is not
+	// tied to the request which started it.
+	secretLookupTimeout = 10 * time.Second
+)
+
+type cachedSecret struct {
+	secret  *Secret
+	expires time.Time
+}
+
+// secretCall is a lookup of the inner provider in progress
+type secretCall struct {
+	done   chan struct{}
+	secret *Secret
+	err    error
+}
+
+// NewCachingSecretProvider returns a SecretProvider caching the secrets of
+// inner for ttl. Up to 1024 unknown keyIDs are cached too, so that clients
+// sending invalid keyIDs do not reach inner on every request; other errors are
+// not cached. Use Invalidate to apply a revocation or a new key at once.
+//
+// A lookup of inner is shared by the concurrent requests of the same keyID, so
+// it does not end when the request which started it is canceled. Its context
+// keeps the values of that request and times out after 10 seconds.
+func NewCachingSecretProvider(inner SecretProvider, ttl time.Duration) *CachingSecretProvider {
+	return &CachingSecretProvider{
+		inner:      inner,
+		ttl:        ttl,
+		now:        time.Now,
+		maxUnknown: maxUnknownKeyIDs,
+		entries:    make(map[KeyID]cachedSecret),
+		calls:      make(map[KeyID]*secretCall),
+	}
+}
+
+// Get returns the secret of keyID from the cache, or from the inner provider
+// when it is not cached or expired.
+func (p *CachingSecretProvider) Get(ctx context.Context, keyID KeyID) (*Secret, error) {
+	p.mu.Lock()
+	if entry, ok := p.entries[keyID]; ok {
+		if p.now().Before(entry.expires) {
+			p.mu.Unlock()
+			return entry.lookupResult()
+		}
+		p.deleteEntry(keyID)
+	}
+	call, inFlight := p.calls[keyID]
+	if !inFlight {
+		call = &secretCall{done: make(chan struct{})}
+		p.calls[keyID] = call
+		go p.lookup(detachedContext{ctx}, keyID, call)
+	}
+	p.mu.Unlock()
+
+	select {
+	case <-call.done:
+		return call.secret, call.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// lookup gets keyID from the inner provider for call and caches the result
+func (p *CachingSecretProvider) lookup(ctx context.Context, keyID KeyID, call *secretCall) {
+	ctx, cancel := context.WithTimeout(ctx, secretLookupTimeout)
+	defer cancel()
+	call.secret, call.err = p.inner.Get(ctx, keyID)
+	if call.err == nil && call.secret == nil {
+		call.err = ErrInvalidKeyID
+	}
+
+	p.mu.Lock()
+	// The call is no longer registered when keyID was invalidated meanwhile,
+	// its result could be stale.
+	if p.calls[keyID] == call {
+		delete(p.calls, keyID)
+		if call.err == nil {
+			p.entries[keyID] = cachedSecret{secret: call.secret, expires: p.now().Add(p.ttl)}
+		} else if errors.Is(call.err, ErrInvalidKeyID) && p.roomForUnknown() {
+			p.entries[keyID] = cachedSecret{expires: p.now().Add(p.ttl)}
+			p.unknown++
+		}
+	}
+	p.mu.Unlock()
+	close(call.done)
+}
+
+// roomForUnknown reports whether another unknown keyID can be cached, the
+// expired ones are removed when the limit is reached. p.mu must be held.
+func (p *CachingSecretProvider) roomForUnknown() bool {
+	if p.unknown < p.maxUnknown {
+		return true
+	}
+	now := p.now()
+	for keyID, entry := range p.entries {
+		if entry.secret == nil && !now.Before(entry.expires) {
+			p.deleteEntry(keyID)
+		}
+	}
+	return p.unknown < p.maxUnknown
+}
+
+// deleteEntry removes keyID from the cache. p.mu must be held.
+func (p *CachingSecretProvider) deleteEntry(keyID KeyID) {
+	if entry, ok := p.entries[keyID]; ok {
+		if entry.secret == nil {
+			p.unknown--
+		}
+		delete(p.entries, keyID)
+	}
+}
+
+// Invalidate removes keyID from the cache, the next lookup reaches the inner
+// provider.
+func (p *CachingSecretProvider) Invalidate(keyID KeyID) {
+	p.mu.Lock()
+	p.deleteEntry(keyID)
+	delete(p.calls, keyID)
+	p.mu.Unlock()
+}
+
+func (e cachedSecret) lookupResult() (*Secret, error) {
+	if e.secret == nil {
+		return nil, ErrInvalidKeyID
+	}
+	return e.secret, nil
+}
+
+// detachedContext keeps the values of a context without its cancellation and
+// deadline.
+type detachedContext struct {
+	parent context.Context
+}
+
+func (detachedContext) Deadline() (time.Time, bool) { return time.Time{}, false }
+func (detachedContext) Done() <-chan struct{}       { return nil }
+func (detachedContext) Err() error                  { return nil }
+
+func (c detachedContext) Value(key interface{}) interface{} {
+	return c.parent.Value(key)
+}
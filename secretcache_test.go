@@ -0,0 +1,152 @@
+package httpsign
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// countingProvider is a slow SecretProvider counting its calls
+type countingProvider struct {
+	calls   int32
+	delay   time.Duration
+	secrets Secrets
+	err     error
+}
+
+func (p *countingProvider) Get(ctx context.Context, keyID KeyID) (*Secret, error) {
+	atomic.AddInt32(&p.calls, 1)
+	time.Sleep(p.delay)
+	if p.err != nil {
+		return nil, p.err
+	}
+	return p.secrets.Get(ctx, keyID)
+}
+
+func TestCachingSecretProviderSingleFlight(t *testing.T) {
+	inner := &countingProvider{delay: 50 * time.Millisecond, secrets: secrets}
+	cache := NewCachingSecretProvider(inner, time.Minute)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			secret, err := cache.Get(context.Background(), readID)
+			assert.NoError(t, err)
+			assert.Same(t, secrets[readID], secret)
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, int32(1), atomic.LoadInt32(&inner.calls))
+
+	_, err := cache.Get(context.Background(), readID)
+	require.NoError(t, err)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&inner.calls), "served from the cache")
+}
+
+func TestCachingSecretProviderExpiry(t *testing.T) {
+	inner := &countingProvider{secrets: secrets}
+	cache := NewCachingSecretProvider(inner, time.Minute)
+	now := time.Now()
+	cache.now = func() time.Time { return now }
+
+	_, err := cache.Get(context.Background(), readID)
+	require.NoError(t, err)
+	_, err = cache.Get(context.Background(), "unknown")
+	assert.Equal(t, ErrInvalidKeyID, err)
+	_, err = cache.Get(context.Background(), "unknown")
+	assert.Equal(t, ErrInvalidKeyID, err)
+	assert.Equal(t, int32(2), inner.calls, "unknown keyIDs are cached")
+
+	now = now.Add(time.Minute)
+	_, err = cache.Get(context.Background(), readID)
+	require.NoError(t, err)
+	assert.Equal(t, int32(3), inner.calls)
+
+	cache.Invalidate(readID)
+	_, err = cache.Get(context.Background(), readID)
+	require.NoError(t, err)
+	assert.Equal(t, int32(4), inner.calls)
+
+	inner.err = errors.New("backend down")
+	cache.Invalidate(readID)
+	for i := 0; i < 2; i++ {
+		_, err = cache.Get(context.Background(), readID)
+		assert.Equal(t, inner.err, err)
+	}
+	assert.Equal(t, int32(6), inner.calls, "errors are not cached")
+}
+
+func TestCachingSecretProviderContext(t *testing.T) {
+	inner := &countingProvider{delay: 100 * time.Millisecond, secrets: secrets}
+	cache := NewCachingSecretProvider(inner, time.Minute)
+
+	go func() {
+		_, _ = cache.Get(context.Background(), readID)
+	}()
+	time.Sleep(10 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err := cache.Get(ctx, readID)
+	assert.Equal(t, context.DeadlineExceeded, err)
+}
+
+func TestCachingSecretProviderLeaderCanceled(t *testing.T) {
+	inner := &countingProvider{delay: 50 * time.Millisecond, secrets: secrets}
+	cache := NewCachingSecretProvider(inner, time.Minute)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	leader := make(chan error, 1)
+	go func() {
+		_, err := cache.Get(ctx, readID)
+		leader <- err
+	}()
+	time.Sleep(10 * time.Millisecond)
+
+	follower := make(chan error, 1)
+	go func() {
+		_, err := cache.Get(context.Background(), readID)
+		follower <- err
+	}()
+	time.Sleep(10 * time.Millisecond)
+	cancel()
+
+	assert.Equal(t, context.Canceled, <-leader)
+	assert.NoError(t, <-follower, "the lookup is not tied to the first request")
+	assert.Equal(t, int32(1), atomic.LoadInt32(&inner.calls))
+}
+
+func TestCachingSecretProviderUnknownBound(t *testing.T) {
+	inner := &countingProvider{secrets: secrets}
+	cache := NewCachingSecretProvider(inner, time.Minute)
+	cache.maxUnknown = 2
+	now := time.Now()
+	cache.now = func() time.Time { return now }
+
+	for _, keyID := range []KeyID{"unknown1", "unknown2", "unknown3", "unknown3"} {
+		_, err := cache.Get(context.Background(), keyID)
+		assert.Equal(t, ErrInvalidKeyID, err)
+	}
+	assert.Equal(t, int32(4), inner.calls, "unknown3 is not cached")
+
+	_, err := cache.Get(context.Background(), readID)
+	require.NoError(t, err)
+	_, err = cache.Get(context.Background(), readID)
+	require.NoError(t, err)
+	assert.Equal(t, int32(5), inner.calls, "known keyIDs are not bounded")
+
+	now = now.Add(time.Minute)
+	for _, keyID := range []KeyID{"unknown3", "unknown3"} {
+		_, err := cache.Get(context.Background(), keyID)
+		assert.Equal(t, ErrInvalidKeyID, err)
+	}
+	assert.Equal(t, int32(6), inner.calls, "the expired unknown keyIDs are removed")
+}
@@ -0,0 +1,105 @@
+package httpsign
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/stremovskyy/httpsign/crypto"
+	"gopkg.in/yaml.v3"
+)
+
+// SecretConfig is an entry of the secrets configuration files read by
+// LoadSecretsFromJSON and LoadSecretsFromYAML.
+type SecretConfig struct {
+	KeyID     KeyID  `json:"keyId" yaml:"keyId"`
+	Algorithm string `json:"algorithm" yaml:"algorithm"`
+	Key       string `json:"key" yaml:"key"`
+	PublicKey string `json:"publicKey" yaml:"publicKey"`
+}
+
+// LoadSecretsFromJSON reads Secrets from a JSON array of SecretConfig, e.g.
+//
+//	[{"keyId": "read", "algorithm": "hmac-sha512", "key": "secret"}]
+//
+// Algorithms are resolved with crypto.Get, or derived from the key type when
+// omitted, and keys are checked, errors name the keyId of the invalid entry.
+// Entries sharing a keyId are added with Secrets.Add, for key rotation.
+func LoadSecretsFromJSON(r io.Reader) (Secrets, error) {
+	var configs []SecretConfig
+	if err := json.NewDecoder(r).Decode(&configs); err != nil {
+		return nil, fmt.Errorf("httpsign: invalid secrets JSON: %w", err)
+	}
+	return NewSecrets(configs)
+}
+
+// LoadSecretsFromYAML reads Secrets from a YAML list of SecretConfig.
+// See LoadSecretsFromJSON.
+func LoadSecretsFromYAML(r io.Reader) (Secrets, error) {
+	var configs []SecretConfig
+	if err := yaml.NewDecoder(r).Decode(&configs); err != nil && err != io.EOF {
+		return nil, fmt.Errorf("httpsign: invalid secrets YAML: %w", err)
+	}
+	return NewSecrets(configs)
+}
+
+// NewSecrets creates Secrets from configs. See LoadSecretsFromJSON.
+func NewSecrets(configs []SecretConfig) (Secrets, error) {
+	secrets := Secrets{}
+	for i, config := range configs {
+		if config.KeyID == "" {
+			return nil, fmt.Errorf("httpsign: secret #%d: missing keyId", i)
+		}
+		secret, err := config.secret()
+		if err != nil {
+			return nil, fmt.Errorf("httpsign: secret %q: %w", config.KeyID, err)
+		}
+		secrets.Add(config.KeyID, secret)
+	}
+	return secrets, nil
+}
+
+func (c *SecretConfig) secret() (*Secret, error) {
+	if c.Key == "" && c.PublicKey == "" {
+		return nil, errors.New("missing key")
+	}
+	name := c.Algorithm
+	if name == "" {
+		key := c.Key
+		if key == "" {
+			key = c.PublicKey
+		}
+		var err error
+		if name, err = crypto.DefaultAlgorithm(key); err != nil {
+			return nil, fmt.Errorf("missing algorithm: %w", err)
+		}
+	}
+	algorithm, err := crypto.Get(name)
+	if err != nil {
+		return nil, err
+	}
+	if c.Key != "" {
+		if _, err := algorithm.Sign("", c.Key); err != nil {
+			return nil, fmt.Errorf("invalid key: %w", err)
+		}
+		if err := crypto.CheckKeyType(algorithm, c.Key); err != nil {
+			return nil, fmt.Errorf("invalid key: %w", err)
+		}
+	}
+	if c.PublicKey != "" {
+		// Verifying an empty signature parses the key first
+		err := algorithm.Verify("", nil, c.PublicKey)
+		if err != nil && !errors.Is(err, crypto.ErrInvalidSignature) {
+			return nil, fmt.Errorf("invalid publicKey: %w", err)
+		}
+		if err := crypto.CheckKeyType(algorithm, c.PublicKey); err != nil {
+			return nil, fmt.Errorf("invalid publicKey: %w", err)
+		}
+	}
+	return &Secret{
+		Key:       c.Key,
+		PublicKey: c.PublicKey,
+		Algorithm: algorithm,
+	}, nil
+}
@@ -0,0 +1,78 @@
+package httpsign
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadSecretsFromJSON(t *testing.T) {
+	pkcs1, _ := generateRSAKeyPEM(t)
+	config, err := json.Marshal([]SecretConfig{
+		{KeyID: "read", Algorithm: "hmac-sha512", Key: "secret"},
+		{KeyID: "partner", Key: string(pkcs1)},
+	})
+	require.NoError(t, err)
+
+	loaded, err := LoadSecretsFromJSON(strings.NewReader(string(config)))
+	require.NoError(t, err)
+	require.Len(t, loaded, 2)
+	assert.Equal(t, "hmac-sha512", loaded["read"].Algorithm.Name())
+	assert.Equal(t, "rsa-sha256", loaded["partner"].Algorithm.Name())
+
+	auth := NewAuthenticator(loaded)
+	req, err := http.NewRequest("GET", "/", nil)
+	require.NoError(t, err)
+	require.NoError(t, NewSigner("partner", loaded["partner"], nil).Sign(req))
+	assert.NoError(t, auth.Verify(req))
+}
+
+func TestLoadSecretsFromYAML(t *testing.T) {
+	loaded, err := LoadSecretsFromYAML(strings.NewReader(`
+- keyId: read
+  algorithm: hmac-sha512
+  key: secret
+- keyId: read
+  algorithm: hmac-sha512
+  key: rotated
+`))
+	require.NoError(t, err)
+	require.Len(t, loaded, 1)
+	assert.Equal(t, "secret", loaded["read"].Key)
+	assert.Len(t, loaded["read"].all(), 2)
+
+	loaded, err = LoadSecretsFromYAML(strings.NewReader(""))
+	require.NoError(t, err)
+	assert.Empty(t, loaded)
+}
+
+func TestLoadSecretsErrors(t *testing.T) {
+	pkcs1, _ := generateRSAKeyPEM(t)
+	rsaKey, err := json.Marshal(string(pkcs1))
+	require.NoError(t, err)
+	rsaKeyJSON := string(rsaKey)
+
+	var tests = []struct {
+		name   string
+		config string
+		err    string
+	}{
+		{name: "syntax", config: `[{"keyId": }]`, err: "httpsign: invalid secrets JSON"},
+		{name: "missing keyId", config: `[{"algorithm": "hmac-sha512", "key": "secret"}]`, err: "httpsign: secret #0: missing keyId"},
+		{name: "unknown algorithm", config: `[{"keyId": "read", "algorithm": "md5", "key": "secret"}]`, err: `httpsign: secret "read": unknown algorithm: "md5"`},
+		{name: "missing key", config: `[{"keyId": "read", "algorithm": "hmac-sha512"}]`, err: `httpsign: secret "read": missing key`},
+		{name: "invalid key", config: `[{"keyId": "partner", "algorithm": "rsa-sha256", "key": "not a pem"}]`, err: `httpsign: secret "partner": invalid key`},
+		{name: "hmac with a pem key", config: `[{"keyId": "partner", "algorithm": "hmac-sha256", "key": ` + rsaKeyJSON + `}]`, err: `httpsign: secret "partner": invalid key: key type is incompatible`},
+		{name: "missing algorithm", config: `[{"keyId": "read", "key": "secret"}]`, err: `httpsign: secret "read": missing algorithm`},
+		{name: "invalid public key", config: `[{"keyId": "partner", "algorithm": "ed25519", "publicKey": "not a key"}]`, err: `httpsign: secret "partner": invalid publicKey`},
+	}
+	for _, test := range tests {
+		_, err := LoadSecretsFromJSON(strings.NewReader(test.config))
+		require.Error(t, err, test.name)
+		assert.True(t, strings.HasPrefix(err.Error(), test.err), "%s: %v", test.name, err)
+	}
+}
@@ -0,0 +1,57 @@
+package httpsign
+
+import (
+	"context"
+	"sort"
+	"strings"
+)
+
+// keyIDWildcard ends the keyIDs of PrefixSecrets matching by prefix
+const keyIDWildcard = "*"
+
+// PrefixSecrets is a SecretProvider matching the keyIDs exactly or by prefix,
+// so that a single secret covers a family of keyIDs like tenantA:client1 and
+// tenantA:client2. An exact match beats the prefixes, and the longest matching
+// prefix wins.
+type PrefixSecrets struct {
+	exact    Secrets
+	prefixes []prefixSecret
+}
+
+type prefixSecret struct {
+	prefix string
+	secret *Secret
+}
+
+// NewPrefixSecrets creates a PrefixSecrets from secrets, whose keyIDs ending
+// with * are prefixes: tenantA:* matches the keyIDs starting with tenantA:,
+// and * alone matches any keyID. The other keyIDs are matched exactly.
+// secrets must not be modified afterwards.
+func NewPrefixSecrets(secrets Secrets) *PrefixSecrets {
+	p := &PrefixSecrets{exact: make(Secrets)}
+	for keyID, secret := range secrets {
+		id := string(keyID)
+		if strings.HasSuffix(id, keyIDWildcard) {
+			p.prefixes = append(p.prefixes, prefixSecret{prefix: strings.TrimSuffix(id, keyIDWildcard), secret: secret})
+			continue
+		}
+		p.exact[keyID] = secret
+	}
+	sort.Slice(p.prefixes, func(i, j int) bool {
+		return len(p.prefixes[i].prefix) > len(p.prefixes[j].prefix)
+	})
+	return p
+}
+
+// Get returns the secret of keyID, or of its longest matching prefix.
+func (p *PrefixSecrets) Get(_ context.Context, keyID KeyID) (*Secret, error) {
+	if secret, ok := p.exact[keyID]; ok {
+		return secret, nil
+	}
+	for _, ps := range p.prefixes {
+		if strings.HasPrefix(string(keyID), ps.prefix) {
+			return ps.secret, nil
+		}
+	}
+	return nil, ErrInvalidKeyID
+}
@@ -0,0 +1,63 @@
+package httpsign
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPrefixSecrets(t *testing.T) {
+	tenant := &Secret{Key: "tenant", Algorithm: hmacsha512}
+	team := &Secret{Key: "team", Algorithm: hmacsha512}
+	client := &Secret{Key: "client", Algorithm: hmacsha512}
+	p := NewPrefixSecrets(Secrets{
+		"tenantA:*":      tenant,
+		"tenantA:team:*": team,
+		"tenantA:client": client,
+	})
+
+	var tests = []struct {
+		keyID  KeyID
+		secret *Secret
+	}{
+		{keyID: "tenantA:client", secret: client},
+		{keyID: "tenantA:client2", secret: tenant},
+		{keyID: "tenantA:team:client", secret: team},
+		{keyID: "tenantA:", secret: tenant},
+		{keyID: "tenantB:client"},
+		{keyID: "tenantA"},
+	}
+	for _, tc := range tests {
+		secret, err := p.Get(context.Background(), tc.keyID)
+		if tc.secret == nil {
+			assert.Equal(t, ErrInvalidKeyID, err, tc.keyID)
+			continue
+		}
+		require.NoError(t, err, tc.keyID)
+		assert.Same(t, tc.secret, secret, tc.keyID)
+	}
+
+	secret, err := NewPrefixSecrets(Secrets{"*": tenant}).Get(context.Background(), "any")
+	require.NoError(t, err)
+	assert.Same(t, tenant, secret)
+}
+
+func TestAuthenticatorPrefixSecrets(t *testing.T) {
+	tenant := &Secret{Key: "tenant", Algorithm: hmacsha512}
+	auth := NewAuthenticator(nil, WithSecretProvider(NewPrefixSecrets(Secrets{"tenantA:*": tenant})),
+		WithValidator(&dateAlwaysValid{}))
+
+	req, err := http.NewRequest("GET", "/", nil)
+	require.NoError(t, err)
+	require.NoError(t, NewSigner("tenantA:client1", tenant, nil).Sign(req))
+	assert.NoError(t, auth.Verify(req))
+
+	req, err = http.NewRequest("GET", "/", nil)
+	require.NoError(t, err)
+	require.NoError(t, NewSigner("tenantB:client1", tenant, nil).Sign(req))
+	assert.True(t, errors.Is(auth.Verify(req), ErrInvalidKeyID))
+}
@@ -0,0 +1,61 @@
+package httpsign
+
+import (
+	"context"
+	"sync"
+)
+
+// RotatingSecrets is a SecretProvider whose secrets can be changed while an
+// Authenticator verifies requests with them, e.g. to rotate keys without a
+// restart. Unlike Secrets it is safe for concurrent use. Use it with
+// WithSecretProvider.
+type RotatingSecrets struct {
+	mu      sync.RWMutex
+	secrets Secrets
+}
+
+// NewRotatingSecrets creates a RotatingSecrets holding a copy of secrets
+func NewRotatingSecrets(secrets Secrets) *RotatingSecrets {
+	s := &RotatingSecrets{secrets: make(Secrets, len(secrets))}
+	for keyID, secret := range secrets {
+		s.secrets[keyID] = secret
+	}
+	return s
+}
+
+// Get returns the secret of keyID
+func (s *RotatingSecrets) Get(ctx context.Context, keyID KeyID) (*Secret, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.secrets.Get(ctx, keyID)
+}
+
+// Set replaces the secrets of keyID with secret
+func (s *RotatingSecrets) Set(keyID KeyID, secret *Secret) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.secrets[keyID] = secret
+}
+
+// Add adds secret to the secrets of keyID, see Secrets.Add
+func (s *RotatingSecrets) Add(keyID KeyID, secret *Secret) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.secrets.Add(keyID, secret)
+}
+
+// AddTimed adds secret to the secrets of keyID until secret.ValidUntil, see
+// Secrets.AddTimed
+func (s *RotatingSecrets) AddTimed(keyID KeyID, secret TimedSecret) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.secrets.AddTimed(keyID, secret)
+}
+
+// Delete removes the secrets of keyID, its requests are rejected with
+// ErrInvalidKeyID
+func (s *RotatingSecrets) Delete(keyID KeyID) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.secrets, keyID)
+}
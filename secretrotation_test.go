@@ -0,0 +1,71 @@
+package httpsign
+
+import (
+	"errors"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRotatingSecrets(t *testing.T) {
+	oldSecret := &Secret{Key: "old", Algorithm: hmacsha512}
+	newSecret := &Secret{Key: "new", Algorithm: hmacsha512}
+	initial := Secrets{readID: oldSecret}
+	secrets := NewRotatingSecrets(initial)
+	auth := NewAuthenticator(nil, WithSecretProvider(secrets), WithValidator(&dateAlwaysValid{}))
+
+	signed := func(secret *Secret) error {
+		req := httptest.NewRequest("GET", "/", nil)
+		require.NoError(t, NewSigner(readID, secret, nil).Sign(req))
+		return auth.Verify(req)
+	}
+	assert.NoError(t, signed(oldSecret))
+	assert.True(t, errors.Is(signed(newSecret), ErrInvalidSign))
+
+	secrets.Add(readID, newSecret)
+	assert.Same(t, oldSecret, initial[readID], "the secrets given are copied")
+	assert.NoError(t, signed(oldSecret))
+	assert.NoError(t, signed(newSecret))
+
+	secrets.Set(readID, newSecret)
+	assert.True(t, errors.Is(signed(oldSecret), ErrInvalidSign))
+	assert.NoError(t, signed(newSecret))
+
+	secrets.Delete(readID)
+	assert.True(t, errors.Is(signed(newSecret), ErrInvalidKeyID))
+}
+
+func TestRotatingSecretsConcurrent(t *testing.T) {
+	oldSecret := &Secret{Key: "old", Algorithm: hmacsha512}
+	newSecret := &Secret{Key: "new", Algorithm: hmacsha512}
+	secrets := NewRotatingSecrets(Secrets{readID: oldSecret})
+	auth := NewAuthenticator(nil, WithSecretProvider(secrets), WithValidator(&dateAlwaysValid{}))
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			secrets.Add(readID, newSecret)
+			secrets.Set(readID, oldSecret)
+		}
+	}()
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				req := httptest.NewRequest("GET", "/", nil)
+				if err := NewSigner(readID, oldSecret, nil).Sign(req); err != nil {
+					t.Error(err)
+					return
+				}
+				assert.NoError(t, auth.Verify(req), "the old secret is kept during the rotation")
+			}
+		}()
+	}
+	wg.Wait()
+}
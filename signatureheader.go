@@ -1,8 +1,11 @@
 package httpsign
 
 import (
+	"fmt"
 	"net/http"
+	"strconv"
 	"strings"
+	"time"
 )
 
 const (
@@ -13,37 +16,54 @@ const (
 	signingAlgorithm              = "algorithm"
 	signingSignature              = "signature"
 	signingHeaders                = "headers"
+	signingCreated                = "created"
+	signingExpires                = "expires"
 )
 
-//SignatureHeader contains basic info signature header
+// SignatureHeader contains basic info signature header
 type SignatureHeader struct {
 	keyID     KeyID
 	headers   []string
 	signature string
 	algorithm string
+	created   time.Time
+	expires   time.Time
+
+	// label and params are only set for RFC 9421 signatures: the label of
+	// the signature and its serialized signature parameters. For JWS
+	// signatures params is the encoded protected header, and unencoded is set
+	// for RFC 7797 unencoded payloads.
+	label     string
+	params    string
+	unencoded bool
 }
 
-//NewSignatureHeader new instace of SignatureHeader
+// NewSignatureHeader new instace of SignatureHeader. The signature is read from
+// the Signature header, or from the Authorization header with the Signature
+// scheme when there is no Signature header.
 func NewSignatureHeader(r *http.Request) (*SignatureHeader, error) {
-	return parseHTTPRequest(r)
+	return parseHTTPRequest(r, false, true)
 }
 
-func parseHTTPRequest(r *http.Request) (*SignatureHeader, error) {
-	s, err := getSignatureString(r)
+// parseHTTPRequest parses the Signature or Authorization header of r. The
+// keyId parameter may be missing unless requireKeyID is set, see
+// WithKeyIDResolver.
+func parseHTTPRequest(r *http.Request, preferAuthorization bool, requireKeyID bool) (*SignatureHeader, error) {
+	s, err := getSignatureString(r, preferAuthorization)
 	if err != nil {
 		return nil, err
 	}
-	return parseSignatureString(s)
+	return parseSignatureString(s, requireKeyID)
 }
 
-func parseSignatureString(s string) (*SignatureHeader, error) {
+func parseSignatureString(s string, requireKeyID bool) (*SignatureHeader, error) {
 	p := newParser(s)
 	results, err := p.parse()
 	if err != nil {
 		return nil, err
 	}
-	keyID, ok := results[signingKeyID]
-	if !ok {
+	keyID, ok := results[strings.ToLower(signingKeyID)]
+	if !ok && requireKeyID {
 		return nil, ErrMissingKeyID
 	}
 	signature, ok := results[signingSignature]
@@ -59,27 +79,104 @@ func parseSignatureString(s string) (*SignatureHeader, error) {
 	}
 	algorithm, _ := results[signingAlgorithm]
 
-	return &SignatureHeader{
+	sigHeader := &SignatureHeader{
 		keyID:     KeyID(keyID),
 		signature: signature,
 		headers:   headers,
 		algorithm: algorithm,
-	}, nil
+	}
+	if sigHeader.created, err = parseTimestamp(results, signingCreated); err != nil {
+		return nil, err
+	}
+	if sigHeader.expires, err = parseTimestamp(results, signingExpires); err != nil {
+		return nil, err
+	}
+	return sigHeader, nil
 }
 
-func getSignatureString(r *http.Request) (string, error) {
-	s := r.Header.Get(signatureHeader)
-	if s != "" {
-		return s, nil
+// parseTimestamp parses the Unix timestamp parameter name. The zero time is
+// returned when the parameter is missing.
+func parseTimestamp(results map[string]string, name string) (time.Time, error) {
+	value, ok := results[name]
+	if !ok {
+		return time.Time{}, nil
 	}
+	ts, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return time.Time{}, ErrInvalidTimestamp
+	}
+	return time.Unix(ts, 0), nil
+}
+
+// KeyID returns the keyId of the signature
+func (s *SignatureHeader) KeyID() KeyID {
+	return s.keyID
+}
+
+// Algorithm returns the algorithm of the signature, empty when the signature
+// does not name it
+func (s *SignatureHeader) Algorithm() string {
+	return s.algorithm
+}
 
-	s = r.Header.Get(authorizationHeader)
-	if s != "" {
-		if strings.Index(s, authorizationHeaderInitString) != 0 {
+// Headers returns a copy of the ordered list of the fields covered by the
+// signature
+func (s *SignatureHeader) Headers() []string {
+	return append([]string(nil), s.headers...)
+}
+
+// Signature returns the encoded signature, as sent in the Signature header,
+// or base64 encoded for the RFC 9421 and JWS formats
+func (s *SignatureHeader) Signature() string {
+	return s.signature
+}
+
+// String returns the Signature header value of the signature
+func (s *SignatureHeader) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, `%s="%s",%s="%s"`, signingKeyID, s.keyID, signingAlgorithm, s.algorithm)
+	if !s.created.IsZero() {
+		fmt.Fprintf(&b, `,%s="%d"`, signingCreated, s.created.Unix())
+	}
+	if !s.expires.IsZero() {
+		fmt.Fprintf(&b, `,%s="%d"`, signingExpires, s.expires.Unix())
+	}
+	fmt.Fprintf(&b, `,%s="%s",%s="%s"`, signingHeaders, strings.Join(s.headers, " "), signingSignature, s.signature)
+	return b.String()
+}
+
+// getSignatureString returns the signature parameters from the Signature
+// header or the Authorization header, the one tried first depends on
+// preferAuthorization.
+func getSignatureString(r *http.Request, preferAuthorization bool) (string, error) {
+	if !preferAuthorization {
+		if s := r.Header.Get(signatureHeader); s != "" {
+			return s, nil
+		}
+	}
+
+	if s := r.Header.Get(authorizationHeader); s != "" {
+		params, ok := authorizationSignature(s)
+		if ok {
+			return params, nil
+		}
+		if !preferAuthorization || r.Header.Get(signatureHeader) == "" {
 			return "", ErrInvalidAuthorizationHeader
 		}
-		return strings.TrimPrefix(s, authorizationHeaderInitString), nil
 	}
 
+	if s := r.Header.Get(signatureHeader); s != "" {
+		return s, nil
+	}
 	return "", ErrNoSignature
 }
+
+// authorizationSignature returns the parameters of an Authorization header
+// value with the Signature scheme. The scheme is case-insensitive.
+func authorizationSignature(s string) (string, bool) {
+	if len(s) < len(authorizationHeaderInitString) ||
+		!strings.EqualFold(s[:len(authorizationHeaderInitString)], authorizationHeaderInitString) {
+		return "", false
+	}
+	return s[len(authorizationHeaderInitString):], true
+}
@@ -96,13 +96,9 @@ func TestFromSignatureString(t *testing.T) {
 			signature: "70AaN3BDO0XC9QbtgksgCy2jJvmOvshq8VmjSthdXC+sgcgrKrl9WME4DbZv4W7UZKElvCemhDLHQ1Nln9GMkQ==",
 		},
 		{
-			name:      `Authorization Repeated params`,
-			header:    newAuthorizationHeader(`Signature keyId="sample_key_id",algorithm="hmac-sha512",headers="(request-target) date digest",signature="70AaN3BDO0XC9QbtgksgCy2jJvmOvshq8VmjSthdXC+sgcgrKrl9WME4DbZv4W7UZKElvCemhDLHQ1Nln9GMkQ==",keyId="sample_key_id_2"`),
-			err:       nil,
-			keyID:     "sample_key_id_2",
-			algorithm: "hmac-sha512",
-			headers:   []string{"(request-target)", "date", "digest"},
-			signature: "70AaN3BDO0XC9QbtgksgCy2jJvmOvshq8VmjSthdXC+sgcgrKrl9WME4DbZv4W7UZKElvCemhDLHQ1Nln9GMkQ==",
+			name:   `Authorization Repeated params`,
+			header: newAuthorizationHeader(`Signature keyId="sample_key_id",algorithm="hmac-sha512",headers="(request-target) date digest",signature="70AaN3BDO0XC9QbtgksgCy2jJvmOvshq8VmjSthdXC+sgcgrKrl9WME4DbZv4W7UZKElvCemhDLHQ1Nln9GMkQ==",keyId="sample_key_id_2"`),
+			err:    ErrDuplicateParameter,
 		},
 		{
 			name:   `Signature missing keyId`,
@@ -142,14 +138,37 @@ func TestFromSignatureString(t *testing.T) {
 			signature: "70AaN3BDO0XC9QbtgksgCy2jJvmOvshq8VmjSthdXC+sgcgrKrl9WME4DbZv4W7UZKElvCemhDLHQ1Nln9GMkQ==",
 		},
 		{
-			name:      `Repeated params`,
-			header:    newSignatureHeader(`keyId="sample_key_id",algorithm="hmac-sha512",headers="(request-target) date digest",signature="70AaN3BDO0XC9QbtgksgCy2jJvmOvshq8VmjSthdXC+sgcgrKrl9WME4DbZv4W7UZKElvCemhDLHQ1Nln9GMkQ==",keyId="sample_key_id_2"`),
+			name:   `Repeated params`,
+			header: newSignatureHeader(`keyId="sample_key_id",algorithm="hmac-sha512",headers="(request-target) date digest",signature="70AaN3BDO0XC9QbtgksgCy2jJvmOvshq8VmjSthdXC+sgcgrKrl9WME4DbZv4W7UZKElvCemhDLHQ1Nln9GMkQ==",keyId="sample_key_id_2"`),
+			err:    ErrDuplicateParameter,
+		},
+		{
+			name:      `Signature whitespaces around =`,
+			header:    newSignatureHeader(`keyId = "sample_key_id", algorithm = "hmac-sha512", headers = "(request-target) date digest", signature = "70AaN3BDO0XC9QbtgksgCy2jJvmOvshq8VmjSthdXC+sgcgrKrl9WME4DbZv4W7UZKElvCemhDLHQ1Nln9GMkQ=="`),
+			err:       nil,
+			keyID:     "sample_key_id",
+			algorithm: "hmac-sha512",
+			headers:   []string{"(request-target)", "date", "digest"},
+			signature: "70AaN3BDO0XC9QbtgksgCy2jJvmOvshq8VmjSthdXC+sgcgrKrl9WME4DbZv4W7UZKElvCemhDLHQ1Nln9GMkQ==",
+		},
+		{
+			name:      `Signature mixed case names`,
+			header:    newSignatureHeader(`KeyId="sample_key_id",Algorithm="hmac-sha512",HEADERS="(request-target) date digest",Signature="70AaN3BDO0XC9QbtgksgCy2jJvmOvshq8VmjSthdXC+sgcgrKrl9WME4DbZv4W7UZKElvCemhDLHQ1Nln9GMkQ=="`),
 			err:       nil,
-			keyID:     "sample_key_id_2",
+			keyID:     "sample_key_id",
 			algorithm: "hmac-sha512",
 			headers:   []string{"(request-target)", "date", "digest"},
 			signature: "70AaN3BDO0XC9QbtgksgCy2jJvmOvshq8VmjSthdXC+sgcgrKrl9WME4DbZv4W7UZKElvCemhDLHQ1Nln9GMkQ==",
 		},
+		{
+			name:      `Authorization padded by load balancer`,
+			header:    newAuthorizationHeader(`Signature  keyId="sample_key_id" ,	algorithm="hmac-sha512" , headers="date" , signature="70AaN3BDO0XC9QbtgksgCy2jJvmOvshq8VmjSthdXC+sgcgrKrl9WME4DbZv4W7UZKElvCemhDLHQ1Nln9GMkQ==" `),
+			err:       nil,
+			keyID:     "sample_key_id",
+			algorithm: "hmac-sha512",
+			headers:   []string{"date"},
+			signature: "70AaN3BDO0XC9QbtgksgCy2jJvmOvshq8VmjSthdXC+sgcgrKrl9WME4DbZv4W7UZKElvCemhDLHQ1Nln9GMkQ==",
+		},
 	}
 
 	for _, tc := range tests {
@@ -169,3 +188,61 @@ func TestFromSignatureString(t *testing.T) {
 		assert.Equal(t, tc.signature, s.signature, tc.name)
 	}
 }
+
+func TestSignaturePlacement(t *testing.T) {
+	const (
+		fromSignature     = `keyId="signature",algorithm="hmac-sha512",headers="date",signature="c2ln"`
+		fromAuthorization = `keyId="authorization",algorithm="hmac-sha512",headers="date",signature="c2ln"`
+	)
+
+	var tests = []struct {
+		name                string
+		signature           string
+		authorization       string
+		preferAuthorization bool
+		keyID               KeyID
+		err                 error
+	}{
+		{name: "signature header", signature: fromSignature, keyID: "signature"},
+		{name: "authorization header", authorization: "Signature " + fromAuthorization, keyID: "authorization"},
+		{name: "lowercase scheme", authorization: "signature " + fromAuthorization, keyID: "authorization"},
+		{name: "both", signature: fromSignature, authorization: "Signature " + fromAuthorization, keyID: "signature"},
+		{name: "both, prefer authorization", signature: fromSignature, authorization: "Signature " + fromAuthorization, preferAuthorization: true, keyID: "authorization"},
+		{name: "prefer authorization, signature header", signature: fromSignature, preferAuthorization: true, keyID: "signature"},
+		{name: "prefer authorization, other scheme", signature: fromSignature, authorization: "Bearer token", preferAuthorization: true, keyID: "signature"},
+		{name: "other scheme", authorization: "Bearer token", err: ErrInvalidAuthorizationHeader},
+		{name: "prefer authorization, other scheme only", authorization: "Bearer token", preferAuthorization: true, err: ErrInvalidAuthorizationHeader},
+	}
+	for _, test := range tests {
+		r := &http.Request{Header: http.Header{}}
+		if test.signature != "" {
+			r.Header.Set(signatureHeader, test.signature)
+		}
+		if test.authorization != "" {
+			r.Header.Set(authorizationHeader, test.authorization)
+		}
+		s, err := parseHTTPRequest(r, test.preferAuthorization, true)
+		if test.err != nil {
+			assert.Equal(t, test.err, err, test.name)
+			continue
+		}
+		require.NoError(t, err, test.name)
+		assert.Equal(t, test.keyID, s.keyID, test.name)
+	}
+}
+
+func TestSignatureHeaderAccessors(t *testing.T) {
+	r, err := http.NewRequest("GET", "/", nil)
+	require.NoError(t, err)
+	r.Header.Set(signatureHeader, `keyId="read",algorithm="hmac-sha512",headers="(request-target) date",signature="c2ln"`)
+	s, err := NewSignatureHeader(r)
+	require.NoError(t, err)
+
+	assert.Equal(t, readID, s.KeyID())
+	assert.Equal(t, algoHmacSha512, s.Algorithm())
+	assert.Equal(t, []string{requestTarget, date}, s.Headers())
+	assert.Equal(t, "c2ln", s.Signature())
+
+	s.Headers()[0] = host
+	assert.Equal(t, []string{requestTarget, date}, s.Headers(), "the covered headers are read-only")
+}
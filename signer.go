@@ -0,0 +1,219 @@
+package httpsign
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/stremovskyy/httpsign/validator"
+)
+
+// Signer signs outgoing HTTP requests so that they are accepted by an
+// Authenticator configured with the same secret and required headers.
+type Signer struct {
+	keyID    KeyID
+	secret   *Secret
+	headers  []string
+	expiry   time.Duration
+	encoding SignatureEncoding
+	strict   bool
+	// digestHeader is the name of the digest header populated by Sign
+	digestHeader string
+	// requestTargetMode selects the parts of the URI in (request-target)
+	requestTargetMode RequestTargetMode
+	// methodCase is the case of the method in (request-target)
+	methodCase MethodCase
+	// canonicalize is the canonicalizer of the body the digest is computed
+	// over, if any
+	canonicalize validator.BodyCanonicalizer
+}
+
+// SignerOption is the option to the Signer constructor.
+type SignerOption func(*Signer)
+
+// WithSignatureExpiry configures the Signer to set the expires parameter of
+// the signatures to d after their creation. It is required to cover the
+// (expires) pseudo header.
+func WithSignatureExpiry(d time.Duration) SignerOption {
+	return func(s *Signer) {
+		s.expiry = d
+	}
+}
+
+// WithSignerEncoding configures how the Signer encodes the signatures. The
+// default is Base64, use the same encoding as the Authenticator.
+func WithSignerEncoding(encoding SignatureEncoding) SignerOption {
+	return func(s *Signer) {
+		s.encoding = encoding
+	}
+}
+
+// WithSignerStrictHeaderValues configures the Signer to use the header values
+// byte for byte in the signing string, see WithStrictHeaderValues.
+func WithSignerStrictHeaderValues(strict bool) SignerOption {
+	return func(s *Signer) {
+		s.strict = strict
+	}
+}
+
+// WithSignerDigestHeader configures the name of the digest header the Signer
+// populates when it is covered, e.g. content-digest, see
+// validator.NewDigestValidatorWithHeader. The default is digest.
+func WithSignerDigestHeader(name string) SignerOption {
+	return func(s *Signer) {
+		s.digestHeader = strings.ToLower(name)
+	}
+}
+
+// WithSignerRequestTargetMode configures the parts of the request URI in the
+// (request-target) field, see WithRequestTargetMode. The default is
+// PathAndQuery.
+func WithSignerRequestTargetMode(mode RequestTargetMode) SignerOption {
+	return func(s *Signer) {
+		s.requestTargetMode = mode
+	}
+}
+
+// WithSignerRequestTargetMethodCase configures the case of the method in the
+// (request-target) field, see WithRequestTargetMethodCase. The default is
+// Lower.
+func WithSignerRequestTargetMethodCase(methodCase MethodCase) SignerOption {
+	return func(s *Signer) {
+		s.methodCase = methodCase
+	}
+}
+
+// WithSignerBodyCanonicalizer configures the Signer to compute the digest
+// over the canonical form of the body returned by fn, e.g.
+// validator.CanonicalizeJSON. The body is sent as is. The Authenticator must
+// use a DigestValidator with the same canonicalizer, see
+// validator.WithBodyCanonicalizer.
+func WithSignerBodyCanonicalizer(fn validator.BodyCanonicalizer) SignerOption {
+	return func(s *Signer) {
+		s.canonicalize = fn
+	}
+}
+
+// NewSigner creates a new Signer which signs requests with given secret on
+// behalf of keyID. headers is the ordered list of fields that are covered by
+// the signature. If not provided, defaultRequiredHeaders is used.
+func NewSigner(keyID KeyID, secret *Secret, headers []string, options ...SignerOption) *Signer {
+	if len(headers) == 0 {
+		headers = defaultRequiredHeaders
+	}
+	s := &Signer{
+		keyID:        keyID,
+		secret:       secret,
+		headers:      headers,
+		digestHeader: digest,
+	}
+	for _, fn := range options {
+		fn(s)
+	}
+	return s
+}
+
+// Sign computes the signature of the request and sets it to the Signature header.
+// Date and digest headers are populated when they are part of the signed
+// headers and not yet present on the request.
+func (s *Signer) Sign(r *http.Request) error {
+	now := time.Now()
+	sigHeader := &SignatureHeader{
+		keyID:     s.keyID,
+		algorithm: s.secret.Algorithm.Name(),
+		headers:   s.headers,
+	}
+
+	for _, h := range s.headers {
+		switch strings.ToLower(h) {
+		case date:
+			if r.Header.Get(date) == "" {
+				r.Header.Set(date, time.Now().UTC().Format(http.TimeFormat))
+			}
+		case s.digestHeader:
+			if r.Header.Get(s.digestHeader) == "" {
+				d, err := calculateBodyDigest(r, s.canonicalize)
+				if err != nil {
+					return err
+				}
+				r.Header.Set(s.digestHeader, d)
+			}
+		case created:
+			sigHeader.created = now
+		case expires:
+			if s.expiry <= 0 {
+				return ErrMissingSignatureExpiry
+			}
+			sigHeader.expires = now.Add(s.expiry)
+		}
+	}
+
+	signString, err := s.canonicalizer().Canonicalize(r, sigHeader)
+	if err != nil {
+		return err
+	}
+
+	signature, err := s.secret.Algorithm.Sign(signString, s.secret.Key)
+	if err != nil {
+		return &SigningError{Op: "sign", KeyID: s.keyID, Err: err}
+	}
+
+	sigHeader.signature = s.encoding.encode(signature)
+	r.Header.Set(signatureHeader, sigHeader.String())
+	return nil
+}
+
+// canonicalizer returns the canonicalizer of the signing strings of s
+func (s *Signer) canonicalizer() CavageCanonicalizer {
+	return CavageCanonicalizer{
+		Strict:            s.strict,
+		RequestTargetMode: s.requestTargetMode,
+		MethodCase:        s.methodCase,
+	}
+}
+
+// digestValidator returns the validator of the digest header populated by s
+func (s *Signer) digestValidator() *validator.DigestValidator {
+	var options []validator.DigestOption
+	if s.canonicalize != nil {
+		options = append(options, validator.WithBodyCanonicalizer(s.canonicalize))
+	}
+	return validator.NewDigestValidatorWithHeader(s.digestHeader, options...)
+}
+
+// calculateBodyDigest returns the SHA-256 digest of the request body in the
+// format expected by validator.DigestValidator, over the body as sent:
+// compressed when it has a Content-Encoding. The body is restored so it can
+// still be sent. The digest is computed over the body canonicalized by
+// canonicalize when not nil.
+func calculateBodyDigest(r *http.Request, canonicalize validator.BodyCanonicalizer) (string, error) {
+	var body []byte
+	if r.Body != nil {
+		var err error
+		body, err = ioutil.ReadAll(r.Body)
+		if err != nil {
+			return "", err
+		}
+		_ = r.Body.Close()
+		r.Body = ioutil.NopCloser(bytes.NewReader(body))
+		r.GetBody = func() (io.ReadCloser, error) {
+			return ioutil.NopCloser(bytes.NewReader(body)), nil
+		}
+	}
+
+	if canonicalize != nil && len(body) > 0 {
+		canonical, err := canonicalize(body)
+		if err != nil {
+			return "", err
+		}
+		body = canonical
+	}
+	h := sha256.Sum256(body)
+	return fmt.Sprintf("SHA-256=%s", base64.StdEncoding.EncodeToString(h[:])), nil
+}
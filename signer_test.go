@@ -0,0 +1,232 @@
+package httpsign
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSignerSignedRequestIsAccepted(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	r := gin.New()
+	auth := NewAuthenticator(secrets)
+	r.Use(auth.Authenticated())
+	r.GET("/", httpTestGet)
+	r.POST("/", httpTestPost)
+
+	signer := NewSigner(readID, secrets[readID], nil)
+
+	req, err := http.NewRequest("POST", "/?a=b", strings.NewReader(sampleBodyContent))
+	require.NoError(t, err)
+	require.NoError(t, signer.Sign(req))
+	assert.NotEmpty(t, req.Header.Get("Date"))
+	assert.Equal(t, requestBodyDigest, req.Header.Get("Digest"))
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, sampleBodyContent, w.Body.String())
+
+	req, err = http.NewRequest("GET", "/", nil)
+	require.NoError(t, err)
+	require.NoError(t, signer.Sign(req))
+	assert.Equal(t, requestBodyEmptyDigest, req.Header.Get("Digest"))
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestSignerKeepsExistingHeaders(t *testing.T) {
+	req, err := http.NewRequest("GET", "/", nil)
+	require.NoError(t, err)
+	req.Header.Set("Date", requestTime.Format(http.TimeFormat))
+	req.Header.Set("Digest", requestBodyEmptyDigest)
+
+	signer := NewSigner(readID, secrets[readID], submitHeader)
+	require.NoError(t, signer.Sign(req))
+
+	s, err := NewSignatureHeader(req)
+	require.NoError(t, err)
+	assert.Equal(t, readID, s.keyID)
+	assert.Equal(t, algoHmacSha512, s.algorithm)
+	assert.Equal(t, submitHeader, s.headers)
+	assert.Equal(t, requestEmptyBodySig, s.signature)
+}
+
+func TestSignerCreatedExpires(t *testing.T) {
+	headers := []string{requestTarget, created, expires, date}
+	auth := NewAuthenticator(secrets, WithRequiredHeaders(headers), WithValidator(&dateAlwaysValid{}))
+
+	req, err := http.NewRequest("GET", "/", nil)
+	require.NoError(t, err)
+	require.NoError(t, NewSigner(readID, secrets[readID], headers, WithSignatureExpiry(time.Minute)).Sign(req))
+
+	s, err := NewSignatureHeader(req)
+	require.NoError(t, err)
+	assert.False(t, s.created.IsZero())
+	assert.Equal(t, time.Minute, s.expires.Sub(s.created))
+	signString, err := constructSignMessage(req, s)
+	require.NoError(t, err)
+	assert.Contains(t, signString, fmt.Sprintf("(created): %d\n(expires): %d\n", s.created.Unix(), s.expires.Unix()))
+	assert.NoError(t, auth.Verify(req))
+
+	assert.Equal(t, ErrMissingSignatureExpiry, NewSigner(readID, secrets[readID], headers).Sign(req))
+
+	s.expires = time.Now().Add(-time.Second)
+	req.Header.Set(signatureHeader, s.String())
+	assert.True(t, errors.Is(auth.Verify(req), ErrSignatureExpired))
+}
+
+func TestCreatedExpiresErrors(t *testing.T) {
+	req, err := http.NewRequest("GET", "/", nil)
+	require.NoError(t, err)
+
+	req.Header.Set(signatureHeader, `keyId="read",expires="soon",headers="(expires)",signature="x"`)
+	_, err = NewSignatureHeader(req)
+	assert.Equal(t, ErrInvalidTimestamp, err)
+
+	req.Header.Set(signatureHeader, `keyId="read",headers="(created)",signature="x"`)
+	err = NewAuthenticator(secrets, WithRequiredHeaders([]string{created}), WithValidator(&dateAlwaysValid{})).Verify(req)
+	assert.True(t, errors.Is(err, ErrEmptyHeader))
+}
+
+func TestSignatureEncoding(t *testing.T) {
+	for _, encoding := range []SignatureEncoding{Base64, Hex} {
+		req, err := http.NewRequest("POST", "/", strings.NewReader(sampleBodyContent))
+		require.NoError(t, err)
+		require.NoError(t, NewSigner(readID, secrets[readID], nil, WithSignerEncoding(encoding)).Sign(req))
+
+		s, err := NewSignatureHeader(req)
+		require.NoError(t, err)
+		signature, err := encoding.decode(s.signature)
+		require.NoError(t, err)
+		assert.Equal(t, encoding.encode(signature), s.signature)
+
+		auth := NewAuthenticator(secrets, WithValidator(&dateAlwaysValid{}), WithSignatureEncoding(encoding))
+		assert.NoError(t, auth.Verify(req), encoding)
+
+		other := Hex
+		if encoding == Hex {
+			other = Base64
+		}
+		auth = NewAuthenticator(secrets, WithValidator(&dateAlwaysValid{}), WithSignatureEncoding(other))
+		assert.True(t, errors.Is(auth.Verify(req), ErrInvalidSign), encoding)
+	}
+
+	req, err := http.NewRequest("GET", "/", nil)
+	require.NoError(t, err)
+	require.NoError(t, NewSigner(readID, secrets[readID], nil, WithSignerEncoding(Hex)).Sign(req))
+	s, err := NewSignatureHeader(req)
+	require.NoError(t, err)
+	assert.Equal(t, strings.ToLower(s.signature), s.signature)
+}
+
+func TestSignerMultiValuedHeader(t *testing.T) {
+	headers := []string{requestTarget, date, "x-forwarded-for"}
+	auth := NewAuthenticator(secrets, WithRequiredHeaders(headers), WithValidator(&dateAlwaysValid{}))
+
+	req, err := http.NewRequest("GET", "/", nil)
+	require.NoError(t, err)
+	req.Header.Add("X-Forwarded-For", "10.0.0.1")
+	req.Header.Add("X-Forwarded-For", " 10.0.0.2 ")
+	require.NoError(t, NewSigner(readID, secrets[readID], headers).Sign(req))
+
+	s, err := NewSignatureHeader(req)
+	require.NoError(t, err)
+	signString, err := constructSignMessage(req, s)
+	require.NoError(t, err)
+	assert.True(t, strings.HasSuffix(signString, "\nx-forwarded-for: 10.0.0.1, 10.0.0.2"), signString)
+	assert.NoError(t, auth.Verify(req))
+
+	req.Header.Set("X-Forwarded-For", "10.0.0.1")
+	assert.True(t, errors.Is(auth.Verify(req), ErrInvalidSign), "a dropped value invalidates the signature")
+}
+
+func TestHeaderValueNormalization(t *testing.T) {
+	headers := []string{requestTarget, date, "x-client"}
+
+	req, err := http.NewRequest("GET", "/", nil)
+	require.NoError(t, err)
+	req.Header.Set("X-Client", "mobile app")
+	require.NoError(t, NewSigner(readID, secrets[readID], headers).Sign(req))
+
+	// an intermediary pads the value
+	req.Header.Set("X-Client", " \tmobile app  ")
+	auth := NewAuthenticator(secrets, WithRequiredHeaders(headers), WithValidator(&dateAlwaysValid{}))
+	assert.NoError(t, auth.Verify(req))
+	strict := NewAuthenticator(secrets, WithRequiredHeaders(headers), WithValidator(&dateAlwaysValid{}), WithStrictHeaderValues(true))
+	assert.True(t, errors.Is(strict.Verify(req), ErrInvalidSign))
+
+	require.NoError(t, NewSigner(readID, secrets[readID], headers, WithSignerStrictHeaderValues(true)).Sign(req))
+	assert.NoError(t, strict.Verify(req))
+	assert.True(t, errors.Is(auth.Verify(req), ErrInvalidSign))
+
+	assert.Equal(t, "a b", normalizeHeaderValue(" a\r\n  b\t"))
+	assert.Equal(t, "a b", normalizeHeaderValue("a\n\tb"))
+	h := http.Header{"X-Multi": []string{" a ", "b\r\n c"}}
+	assert.Equal(t, "a, b c", headerValue(h, "x-multi", false))
+	assert.Equal(t, " a , b\r\n c", headerValue(h, "x-multi", true))
+}
+
+func TestRequestTargetMode(t *testing.T) {
+	headers := []string{requestTarget, date}
+	var tests = []struct {
+		name      string
+		mode      RequestTargetMode
+		signed    string
+		reordered bool
+	}{
+		{name: "path and query", mode: PathAndQuery, signed: "(request-target): get /foo?b=2&a=1"},
+		{name: "path only", mode: PathOnly, signed: "(request-target): get /foo", reordered: true},
+	}
+	for _, tc := range tests {
+		req, err := http.NewRequest("GET", "/foo?b=2&a=1", nil)
+		require.NoError(t, err, tc.name)
+		sigHeader := &SignatureHeader{headers: []string{requestTarget}}
+		signString, err := CavageCanonicalizer{RequestTargetMode: tc.mode}.Canonicalize(req, sigHeader)
+		require.NoError(t, err, tc.name)
+		assert.Equal(t, tc.signed, signString, tc.name)
+
+		auth := NewAuthenticator(secrets, WithRequiredHeaders(headers), WithValidator(&dateAlwaysValid{}),
+			WithRequestTargetMode(tc.mode))
+		require.NoError(t, NewSigner(readID, secrets[readID], headers, WithSignerRequestTargetMode(tc.mode)).Sign(req), tc.name)
+		assert.NoError(t, auth.Verify(req), tc.name)
+
+		req.URL.RawQuery = "a=1&b=2"
+		if tc.reordered {
+			assert.NoError(t, auth.Verify(req), tc.name)
+		} else {
+			assert.True(t, errors.Is(auth.Verify(req), ErrInvalidSign), tc.name)
+		}
+	}
+}
+
+func TestRequestTargetMethodCase(t *testing.T) {
+	headers := []string{requestTarget, date}
+	lower := NewAuthenticator(secrets, WithRequiredHeaders(headers), WithValidator(&dateAlwaysValid{}))
+	preserve := NewAuthenticator(secrets, WithRequiredHeaders(headers), WithValidator(&dateAlwaysValid{}),
+		WithRequestTargetMethodCase(Preserve))
+
+	req, err := http.NewRequest("POST", "/foo", nil)
+	require.NoError(t, err)
+	require.NoError(t, NewSigner(readID, secrets[readID], headers, WithSignerRequestTargetMethodCase(Preserve)).Sign(req))
+	signString, err := CavageCanonicalizer{MethodCase: Preserve}.Canonicalize(req, &SignatureHeader{headers: []string{requestTarget}})
+	require.NoError(t, err)
+	assert.Equal(t, "(request-target): POST /foo", signString)
+	assert.NoError(t, preserve.Verify(req))
+	assert.True(t, errors.Is(lower.Verify(req), ErrInvalidSign))
+
+	require.NoError(t, NewSigner(readID, secrets[readID], headers).Sign(req))
+	assert.NoError(t, lower.Verify(req))
+	assert.True(t, errors.Is(preserve.Verify(req), ErrInvalidSign))
+}
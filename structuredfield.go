@@ -0,0 +1,297 @@
+package httpsign
+
+import (
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// errMalformedStructuredField error when a structured field (RFC 8941) could
+// not be parsed.
+var errMalformedStructuredField = errors.New("malformed structured field")
+
+// sfToken is a structured field token, to distinguish it from a string.
+type sfToken string
+
+// sfItem is a structured field bare item with its parameters. value is one of
+// string, sfToken, int64, bool or []byte.
+type sfItem struct {
+	value  interface{}
+	params []sfParam
+}
+
+type sfParam struct {
+	key   string
+	value interface{}
+}
+
+// sfMember is a member of a structured field dictionary. When isList is set,
+// list holds the items of the inner list, otherwise item is the value.
+// raw is the serialized value of the member, as received.
+type sfMember struct {
+	key    string
+	raw    string
+	isList bool
+	list   []sfItem
+	item   sfItem
+	params []sfParam
+}
+
+// param returns the value of the parameter with given key
+func (m *sfMember) param(key string) (interface{}, bool) {
+	for _, p := range m.params {
+		if p.key == key {
+			return p.value, true
+		}
+	}
+	return nil, false
+}
+
+type sfParser struct {
+	input string
+	pos   int
+}
+
+// parseDictionary parses a structured field dictionary keeping the order of
+// its members.
+func parseDictionary(input string) ([]sfMember, error) {
+	p := &sfParser{input: input}
+	p.skipSP()
+
+	var members []sfMember
+	for !p.eof() {
+		key, err := p.parseKey()
+		if err != nil {
+			return nil, err
+		}
+		member := sfMember{key: key}
+		if p.peek() == '=' {
+			p.pos++
+			start := p.pos
+			if p.peek() == '(' {
+				member.isList = true
+				if member.list, err = p.parseInnerList(); err != nil {
+					return nil, err
+				}
+			} else if member.item.value, err = p.parseBareItem(); err != nil {
+				return nil, err
+			}
+			if member.params, err = p.parseParams(); err != nil {
+				return nil, err
+			}
+			member.raw = p.input[start:p.pos]
+		} else {
+			member.item.value = true
+			if member.params, err = p.parseParams(); err != nil {
+				return nil, err
+			}
+		}
+		if !member.isList {
+			member.item.params = member.params
+		}
+		members = append(members, member)
+
+		p.skipOWS()
+		if p.eof() {
+			break
+		}
+		if p.peek() != ',' {
+			return nil, errMalformedStructuredField
+		}
+		p.pos++
+		p.skipOWS()
+		if p.eof() {
+			return nil, errMalformedStructuredField
+		}
+	}
+	return members, nil
+}
+
+func (p *sfParser) eof() bool {
+	return p.pos >= len(p.input)
+}
+
+func (p *sfParser) peek() byte {
+	if p.eof() {
+		return 0
+	}
+	return p.input[p.pos]
+}
+
+func (p *sfParser) skipSP() {
+	for p.peek() == ' ' {
+		p.pos++
+	}
+}
+
+func (p *sfParser) skipOWS() {
+	for p.peek() == ' ' || p.peek() == '\t' {
+		p.pos++
+	}
+}
+
+func (p *sfParser) parseKey() (string, error) {
+	c := p.peek()
+	if !(c >= 'a' && c <= 'z') && c != '*' {
+		return "", errMalformedStructuredField
+	}
+	start := p.pos
+	for !p.eof() {
+		c = p.peek()
+		if !(c >= 'a' && c <= 'z') && !(c >= '0' && c <= '9') && !strings.ContainsRune("_-.*", rune(c)) {
+			break
+		}
+		p.pos++
+	}
+	return p.input[start:p.pos], nil
+}
+
+func (p *sfParser) parseInnerList() ([]sfItem, error) {
+	p.pos++ // (
+	var items []sfItem
+	for !p.eof() {
+		p.skipSP()
+		if p.peek() == ')' {
+			p.pos++
+			return items, nil
+		}
+		value, err := p.parseBareItem()
+		if err != nil {
+			return nil, err
+		}
+		params, err := p.parseParams()
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, sfItem{value: value, params: params})
+		if c := p.peek(); c != ' ' && c != ')' {
+			return nil, errMalformedStructuredField
+		}
+	}
+	return nil, errMalformedStructuredField
+}
+
+func (p *sfParser) parseParams() ([]sfParam, error) {
+	var params []sfParam
+	for p.peek() == ';' {
+		p.pos++
+		p.skipSP()
+		key, err := p.parseKey()
+		if err != nil {
+			return nil, err
+		}
+		var value interface{} = true
+		if p.peek() == '=' {
+			p.pos++
+			if value, err = p.parseBareItem(); err != nil {
+				return nil, err
+			}
+		}
+		params = append(params, sfParam{key: key, value: value})
+	}
+	return params, nil
+}
+
+func (p *sfParser) parseBareItem() (interface{}, error) {
+	c := p.peek()
+	switch {
+	case c == '-' || (c >= '0' && c <= '9'):
+		return p.parseInteger()
+	case c == '"':
+		return p.parseString()
+	case c == ':':
+		return p.parseByteSequence()
+	case c == '?':
+		return p.parseBoolean()
+	case c == '*' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z'):
+		return p.parseToken()
+	}
+	return nil, errMalformedStructuredField
+}
+
+func (p *sfParser) parseInteger() (interface{}, error) {
+	start := p.pos
+	if p.peek() == '-' {
+		p.pos++
+	}
+	for c := p.peek(); c >= '0' && c <= '9'; c = p.peek() {
+		p.pos++
+	}
+	if p.pos-start > 15 {
+		return nil, errMalformedStructuredField
+	}
+	i, err := strconv.ParseInt(p.input[start:p.pos], 10, 64)
+	if err != nil {
+		return nil, errMalformedStructuredField
+	}
+	return i, nil
+}
+
+func (p *sfParser) parseString() (interface{}, error) {
+	p.pos++ // "
+	var b strings.Builder
+	for !p.eof() {
+		c := p.input[p.pos]
+		p.pos++
+		switch {
+		case c == '\\':
+			if p.eof() {
+				return nil, errMalformedStructuredField
+			}
+			next := p.input[p.pos]
+			if next != '"' && next != '\\' {
+				return nil, errMalformedStructuredField
+			}
+			b.WriteByte(next)
+			p.pos++
+		case c == '"':
+			return b.String(), nil
+		case c < 0x20 || c > 0x7e:
+			return nil, errMalformedStructuredField
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return nil, errMalformedStructuredField
+}
+
+func (p *sfParser) parseByteSequence() (interface{}, error) {
+	p.pos++ // :
+	end := strings.IndexByte(p.input[p.pos:], ':')
+	if end < 0 {
+		return nil, errMalformedStructuredField
+	}
+	encoded := p.input[p.pos : p.pos+end]
+	p.pos += end + 1
+	decoded, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, errMalformedStructuredField
+	}
+	return decoded, nil
+}
+
+func (p *sfParser) parseBoolean() (interface{}, error) {
+	p.pos++ // ?
+	switch p.peek() {
+	case '1':
+		p.pos++
+		return true, nil
+	case '0':
+		p.pos++
+		return false, nil
+	}
+	return nil, errMalformedStructuredField
+}
+
+func (p *sfParser) parseToken() (interface{}, error) {
+	start := p.pos
+	for !p.eof() {
+		c := p.peek()
+		if c <= ' ' || c >= 0x7f || strings.ContainsRune(`"(),;<=>?@[\]{}`, rune(c)) {
+			break
+		}
+		p.pos++
+	}
+	return sfToken(p.input[start:p.pos]), nil
+}
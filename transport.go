@@ -0,0 +1,56 @@
+package httpsign
+
+import (
+	"net/http"
+	"strings"
+)
+
+// signingTransport is the http.RoundTripper returned by Signer.Transport
+type signingTransport struct {
+	signer *Signer
+	next   http.RoundTripper
+}
+
+// Transport returns a http.RoundTripper signing the requests with s before
+// sending them with next, http.DefaultTransport when nil. Each attempt is
+// signed anew: the covered date and digest headers are recomputed from the
+// current time and body, so the requests retried by a retry middleware
+// wrapping the transport are not rejected with a stale date. The requests are
+// not modified, their copy is signed.
+//
+// The body is read from GetBody on each attempt, which http.NewRequest sets
+// for the usual in-memory bodies. Bodies without GetBody are buffered to be
+// signed but cannot be sent again.
+func (s *Signer) Transport(next http.RoundTripper) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return &signingTransport{signer: s, next: next}
+}
+
+// RoundTrip signs a copy of req and sends it
+func (t *signingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	r := req.Clone(req.Context())
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			_ = req.Body.Close()
+			return nil, err
+		}
+		_ = req.Body.Close()
+		r.Body = body
+	}
+	for _, h := range t.signer.headers {
+		// Sign matches the covered names case-insensitively
+		if strings.EqualFold(h, date) || strings.EqualFold(h, t.signer.digestHeader) {
+			r.Header.Del(h)
+		}
+	}
+	if err := t.signer.Sign(r); err != nil {
+		if r.Body != nil {
+			_ = r.Body.Close()
+		}
+		return nil, err
+	}
+	return t.next.RoundTrip(r)
+}
@@ -0,0 +1,94 @@
+package httpsign
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// roundTripperFunc is a http.RoundTripper calling the function
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
+
+func TestSignerTransport(t *testing.T) {
+	auth := NewAuthenticator(secrets)
+	var sent []*http.Request
+	transport := NewSigner(readID, secrets[readID], nil).Transport(roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		sent = append(sent, r)
+		return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody}, nil
+	}))
+
+	req, err := http.NewRequest("POST", "/", strings.NewReader(sampleBodyContent))
+	require.NoError(t, err)
+	stale := time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat)
+	req.Header.Set("Date", stale)
+	req.Header.Set("Digest", "SHA-256=stale")
+
+	// a retry middleware sends the request twice
+	for i := 0; i < 2; i++ {
+		_, err = transport.RoundTrip(req)
+		require.NoError(t, err)
+	}
+	require.Len(t, sent, 2)
+	for _, r := range sent {
+		assert.NotEqual(t, stale, r.Header.Get("Date"))
+		assert.Equal(t, requestBodyDigest, r.Header.Get("Digest"))
+		assert.NoError(t, auth.Verify(r))
+		body, err := ioutil.ReadAll(r.Body)
+		require.NoError(t, err)
+		assert.Equal(t, sampleBodyContent, string(body))
+	}
+	assert.Equal(t, stale, req.Header.Get("Date"), "the request is not modified")
+	assert.Empty(t, req.Header.Get(signatureHeader))
+
+	// without GetBody the body is buffered
+	req, err = http.NewRequest("POST", "/", ioutil.NopCloser(strings.NewReader(sampleBodyContent)))
+	require.NoError(t, err)
+	require.Nil(t, req.GetBody)
+	req.ContentLength = int64(len(sampleBodyContent))
+	_, err = transport.RoundTrip(req)
+	require.NoError(t, err)
+	assert.NoError(t, auth.Verify(sent[2]))
+
+	failing := errors.New("get body failed")
+	req.GetBody = func() (io.ReadCloser, error) { return nil, failing }
+	_, err = transport.RoundTrip(req)
+	assert.Equal(t, failing, err)
+}
+
+func TestSignerTransportMixedCaseHeaders(t *testing.T) {
+	auth := NewAuthenticator(secrets)
+	var sent []*http.Request
+	signer := NewSigner(readID, secrets[readID], []string{"(request-target)", "Date", "Digest"})
+	transport := signer.Transport(roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		sent = append(sent, r)
+		return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody}, nil
+	}))
+
+	req, err := http.NewRequest("POST", "/", strings.NewReader(sampleBodyContent))
+	require.NoError(t, err)
+	stale := time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat)
+	req.Header.Set("Date", stale)
+	req.Header.Set("Digest", "SHA-256=stale")
+
+	for i := 0; i < 2; i++ {
+		_, err = transport.RoundTrip(req)
+		require.NoError(t, err)
+	}
+	require.Len(t, sent, 2)
+	for _, r := range sent {
+		assert.NotEqual(t, stale, r.Header.Get("Date"), "the date is signed anew")
+		assert.Equal(t, requestBodyDigest, r.Header.Get("Digest"))
+		assert.NoError(t, auth.Verify(r))
+	}
+}
@@ -0,0 +1,49 @@
+package validator
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"net/http"
+)
+
+// ContentLengthValidator checking the body of the request has the length
+// declared by its Content-Length, so that a proxy and the application could
+// not disagree on where the body ends, e.g. to smuggle a request. The body is
+// buffered to be counted, then restored for the next validators and the
+// handlers. Requests of unknown length, e.g. chunked, are not checked.
+//
+// The DigestValidator checks the bytes it hashes the same way with
+// WithContentLengthCheck, which also works with WithStreamingDigest.
+type ContentLengthValidator struct{}
+
+// NewContentLengthValidator return pointer of new ContentLengthValidator
+func NewContentLengthValidator() *ContentLengthValidator {
+	return &ContentLengthValidator{}
+}
+
+// Validate return ErrBodyLengthMismatch when the length of the body differs
+// from the Content-Length of the request
+func (v *ContentLengthValidator) Validate(r *http.Request) error {
+	if r.ContentLength < 0 {
+		return nil
+	}
+	if r.Body == nil || r.Body == http.NoBody {
+		if r.ContentLength != 0 {
+			return ErrBodyLengthMismatch
+		}
+		return nil
+	}
+
+	// Reading one byte more than declared tells longer bodies apart without
+	// buffering them whole.
+	body, err := ioutil.ReadAll(io.LimitReader(r.Body, r.ContentLength+1))
+	if err != nil {
+		return err
+	}
+	r.Body = ioutil.NopCloser(bytes.NewReader(body))
+	if int64(len(body)) != r.ContentLength {
+		return ErrBodyLengthMismatch
+	}
+	return nil
+}
@@ -0,0 +1,44 @@
+package validator
+
+import (
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestContentLengthValidator(t *testing.T) {
+	var tests = []struct {
+		name          string
+		body          string
+		contentLength int64
+		err           error
+	}{
+		{name: "matching", body: sampleBody, contentLength: int64(len(sampleBody))},
+		{name: "empty", contentLength: 0},
+		{name: "unknown length", body: sampleBody, contentLength: -1},
+		{name: "truncated", body: sampleBody[:5], contentLength: int64(len(sampleBody)), err: ErrBodyLengthMismatch},
+		{name: "longer", body: sampleBody, contentLength: 5, err: ErrBodyLengthMismatch},
+		{name: "missing body", contentLength: 5, err: ErrBodyLengthMismatch},
+	}
+
+	v := NewContentLengthValidator()
+	for _, tc := range tests {
+		r, err := http.NewRequest("POST", "/", strings.NewReader(tc.body))
+		require.NoError(t, err, tc.name)
+		if tc.body == "" {
+			r.Body = http.NoBody
+		}
+		r.ContentLength = tc.contentLength
+
+		assert.Equal(t, tc.err, v.Validate(r), tc.name)
+		if tc.err == nil {
+			body, err := ioutil.ReadAll(r.Body)
+			require.NoError(t, err, tc.name)
+			assert.Equal(t, tc.body, string(body), tc.name)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package validator
+
+import (
+	"net/http"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrMissingCreated error when the signature has no created parameter
+	ErrMissingCreated = newPublicError(CodeMissingCreated, "Signature created parameter is missing")
+	// ErrCreatedNotInRange error when the created parameter is too old or in the future
+	ErrCreatedNotInRange = newPublicError(CodeCreatedNotInRange, "Signature created parameter is not in acceptable range")
+	// ErrCreatedNotCovered error when the created parameter is not covered by
+	// the signature, so that it could be rewritten
+	ErrCreatedNotCovered = newPublicError(CodeCreatedNotCovered, "Signature created parameter is not covered")
+)
+
+// createdPseudoHeader is the field covering the created parameter of the
+// Cavage signatures
+const createdPseudoHeader = "(created)"
+
+// CreatedValidator checks the created parameter of the signatures, e.g. of
+// RFC 9421 signatures, with its own tolerance, independent of the Date
+// header checked by the DateValidator.
+type CreatedValidator struct {
+	// MaxAge is how old the signatures could be
+	MaxAge time.Duration
+	// MaxSkew is how far in the future the signatures could be created, to
+	// tolerate the clock skew of the clients
+	MaxSkew time.Duration
+	// Clock returns the server time. Defaults to time.Now.
+	Clock func() time.Time
+}
+
+// NewCreatedValidator return CreatedValidator accepting the signatures
+// created at most maxAge ago and at most maxSkew in the future.
+func NewCreatedValidator(maxAge, maxSkew time.Duration) *CreatedValidator {
+	return &CreatedValidator{
+		MaxAge:  maxAge,
+		MaxSkew: maxSkew,
+		Clock:   time.Now,
+	}
+}
+
+// Validate does nothing, the created parameter is checked by
+// ValidateSignature once the signature is parsed.
+func (v *CreatedValidator) Validate(_ *http.Request) error {
+	return nil
+}
+
+// ValidateSignature return error when the created parameter of sig is missing,
+// not covered or out of range. The parameters of the RFC 9421 signatures are
+// always covered, the other signatures must cover the (created) field.
+func (v *CreatedValidator) ValidateSignature(_ *http.Request, sig *Signature) error {
+	if sig.Created.IsZero() {
+		return ErrMissingCreated
+	}
+	if sig.Label == "" && !covers(sig.Headers, createdPseudoHeader) {
+		return ErrCreatedNotCovered
+	}
+	now := time.Now()
+	if v.Clock != nil {
+		now = v.Clock()
+	}
+	if sig.Created.Before(now.Add(-v.MaxAge)) || sig.Created.After(now.Add(v.MaxSkew)) {
+		return ErrCreatedNotInRange
+	}
+	return nil
+}
+
+// covers reports whether name is one of the covered headers
+func covers(headers []string, name string) bool {
+	for _, h := range headers {
+		if strings.EqualFold(h, name) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,48 @@
+package validator
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCreatedValidator(t *testing.T) {
+	var tests = []struct {
+		name    string
+		created time.Time
+		err     error
+	}{
+		{name: "now", created: serverTime},
+		{name: "old in range", created: serverTime.Add(-5 * time.Minute)},
+		{name: "too old", created: serverTime.Add(-5*time.Minute - time.Second), err: ErrCreatedNotInRange},
+		{name: "skew in range", created: serverTime.Add(10 * time.Second)},
+		{name: "future", created: serverTime.Add(11 * time.Second), err: ErrCreatedNotInRange},
+		{name: "missing", err: ErrMissingCreated},
+	}
+
+	v := NewCreatedValidator(5*time.Minute, 10*time.Second)
+	v.Clock = frozenClock
+	r, err := http.NewRequest("GET", "/", nil)
+	require.NoError(t, err)
+	for _, tc := range tests {
+		assert.Equal(t, tc.err, v.ValidateSignature(r, &Signature{Created: tc.created, Headers: []string{"(created)"}}), tc.name)
+	}
+	assert.NoError(t, v.Validate(r))
+}
+
+func TestCreatedValidatorNotCovered(t *testing.T) {
+	v := NewCreatedValidator(5*time.Minute, 10*time.Second)
+	v.Clock = frozenClock
+	r, err := http.NewRequest("GET", "/", nil)
+	require.NoError(t, err)
+
+	// the created parameter of a Cavage signature not covering it could be
+	// rewritten on a captured request
+	assert.Equal(t, ErrCreatedNotCovered, v.ValidateSignature(r, &Signature{Created: serverTime, Headers: []string{"date"}}))
+	assert.NoError(t, v.ValidateSignature(r, &Signature{Created: serverTime, Headers: []string{"date", "(Created)"}}))
+	assert.NoError(t, v.ValidateSignature(r, &Signature{Created: serverTime, Label: "sig1", Headers: []string{"@method"}}),
+		"the parameters of RFC 9421 signatures are covered")
+}
@@ -2,71 +2,204 @@ package validator
 
 import (
 	"errors"
-	"fmt"
 	"net/http"
+	"strconv"
 	"time"
-
-	"github.com/gin-gonic/gin"
 )
 
 const maxTimeGap = 30 * time.Second // 30 secs
 
-func newPublicError(msg string) *gin.Error {
-	return &gin.Error{
-		Err:  errors.New(msg),
-		Type: gin.ErrorTypePublic,
-	}
-}
-
 // ErrDateNotInRange error when date not in aceptable range
-var ErrDateNotInRange = newPublicError("Date submit is not in aceptable range")
+var ErrDateNotInRange = newPublicError(CodeDateNotInRange, "Date submit is not in aceptable range")
+
+// DateFormat is the format of the date header read by the DateValidator
+type DateFormat int
+
+const (
+	// HTTPDate is the HTTP date format, e.g. Mon, 02 Jan 2006 15:04:05 GMT.
+	// Dates with a numeric offset, e.g. Mon, 02 Jan 2006 17:04:05 +0200, and
+	// RFC 3339 dates are accepted too. This is the default.
+	HTTPDate DateFormat = iota
+	// UnixSeconds is a Unix timestamp in seconds
+	UnixSeconds
+	// UnixMillis is a Unix timestamp in milliseconds
+	UnixMillis
+	// UnixAuto is a Unix timestamp in seconds or milliseconds. Values above
+	// 1e11, which is in year 5138 in seconds, are taken as milliseconds.
+	UnixAuto
+)
+
+// unixMillisThreshold is the smallest timestamp considered in milliseconds by
+// UnixAuto
+const unixMillisThreshold = 1e11
+
+var errInvalidTimestamp = errors.New("invalid unix timestamp")
 
 // DateValidator checking validate by time range
 type DateValidator struct {
 	// TimeGap is max time different between client submit timestamp
 	// and server time that considered valid. The time precision is millisecond.
-	TimeGap          time.Duration
+	TimeGap time.Duration
+	// MaxBackward is how far in the past of the server time the date may be,
+	// TimeGap when zero unless set with WithClockSkew.
+	MaxBackward time.Duration
+	// MaxForward is how far in the future of the server time the date may be,
+	// TimeGap when zero unless set with WithClockSkew.
+	MaxForward time.Duration
+	// skewSet is set by WithClockSkew, MaxBackward and MaxForward are then
+	// used even when zero
+	skewSet bool
+
 	HeaderName       string
 	StrictHeaderMode bool
+	// Clock returns the server time. Defaults to time.Now.
+	Clock func() time.Time
+	// Format is the format of the date header, HTTPDate by default.
+	Format DateFormat
 }
 
-// NewDateValidator return DateValidator with default value (30 second)
-func NewDateValidator() *DateValidator {
-	return &DateValidator{
-		TimeGap:    maxTimeGap,
-		HeaderName: "date",
+// DateOption is the option to the DateValidator constructors.
+type DateOption func(*DateValidator)
+
+// WithTimeGap configures the max time different accepted by the DateValidator.
+func WithTimeGap(d time.Duration) DateOption {
+	return func(v *DateValidator) {
+		v.TimeGap = d
+	}
+}
+
+// WithClockSkew configures the DateValidator to accept the dates up to
+// backward before and forward after the server time, e.g. 60s and 5s for
+// clients running slightly behind, see MaxBackward and MaxForward. A zero
+// duration is kept, e.g. forward 0 rejects the dates in the future.
+func WithClockSkew(backward, forward time.Duration) DateOption {
+	return func(v *DateValidator) {
+		v.MaxBackward = backward
+		v.MaxForward = forward
+		v.skewSet = true
+	}
+}
+
+// WithClock configures the DateValidator to read the server time from clock.
+func WithClock(clock func() time.Time) DateOption {
+	return func(v *DateValidator) {
+		v.Clock = clock
+	}
+}
+
+// WithDateFormat configures the format of the date header read by the
+// DateValidator.
+func WithDateFormat(format DateFormat) DateOption {
+	return func(v *DateValidator) {
+		v.Format = format
 	}
 }
 
 // NewDateValidator return DateValidator with default value (30 second)
-func NewCustomDateValidator(dateHeaderName string, strict bool) *DateValidator {
-	return &DateValidator{
+func NewDateValidator(options ...DateOption) *DateValidator {
+	return NewCustomDateValidator("date", false, options...)
+}
+
+// NewUnixDateValidator return DateValidator reading a Unix timestamp, in
+// seconds or milliseconds, from dateHeaderName. The format could be forced
+// with WithDateFormat(UnixSeconds) or WithDateFormat(UnixMillis).
+func NewUnixDateValidator(dateHeaderName string, options ...DateOption) *DateValidator {
+	return NewCustomDateValidator(dateHeaderName, true, append([]DateOption{WithDateFormat(UnixAuto)}, options...)...)
+}
+
+// NewCustomDateValidator return DateValidator reading the date from dateHeaderName.
+// Unless strict is set, the date header is used when dateHeaderName is missing.
+func NewCustomDateValidator(dateHeaderName string, strict bool, options ...DateOption) *DateValidator {
+	v := &DateValidator{
 		TimeGap:          maxTimeGap,
 		HeaderName:       dateHeaderName,
 		StrictHeaderMode: strict,
+		Clock:            time.Now,
+	}
+	for _, fn := range options {
+		fn(v)
 	}
+	return v
 }
 
 // Validate return error when checking if header date is valid or not
 func (v *DateValidator) Validate(r *http.Request) error {
-	dateString := r.Header.Get(v.HeaderName)
+	t, err := v.RequestTime(r)
+	if err != nil {
+		return wrapPublic(&ValidationError{
+			Code:    CodeInvalidDate,
+			Message: "Could not parse date header",
+			Err:     err,
+		})
+	}
+
+	serverTime := v.now()
+	start := serverTime.Add(-v.skew(v.MaxBackward))
+	stop := serverTime.Add(v.skew(v.MaxForward))
 
+	if t.Before(start) || t.After(stop) {
+		return ErrDateNotInRange
+	}
+
+	return nil
+}
+
+// RequestTime returns the date of r read from the date header, the Date
+// header when it is missing unless StrictHeaderMode is set. The range of the
+// date is not checked, see Validate.
+func (v *DateValidator) RequestTime(r *http.Request) (time.Time, error) {
+	dateString := r.Header.Get(v.HeaderName)
 	if dateString == "" && !v.StrictHeaderMode {
 		dateString = r.Header.Get("date")
 	}
+	return v.parse(dateString)
+}
 
-	t, err := http.ParseTime(dateString)
-	if err != nil {
-		return newPublicError(fmt.Sprintf("Could not parse date header. Error: %s", err.Error()))
+// dateLayouts are the layouts tried after http.ParseTime, for the clients
+// sending dates with a numeric offset instead of GMT
+var dateLayouts = []string{time.RFC1123Z, time.RFC3339}
+
+// parseHTTPDate parses date with http.ParseTime, or one of dateLayouts, and
+// returns it in UTC
+func parseHTTPDate(date string) (time.Time, error) {
+	t, err := http.ParseTime(date)
+	if err == nil {
+		return t.UTC(), nil
 	}
+	for _, layout := range dateLayouts {
+		if t, lerr := time.Parse(layout, date); lerr == nil {
+			return t.UTC(), nil
+		}
+	}
+	return time.Time{}, err
+}
 
-	serverTime := time.Now()
-	start := serverTime.Add(-v.TimeGap)
-	stop := serverTime.Add(v.TimeGap)
+func (v *DateValidator) parse(date string) (time.Time, error) {
+	if v.Format == HTTPDate {
+		return parseHTTPDate(date)
+	}
+	ts, err := strconv.ParseInt(date, 10, 64)
+	if err != nil || ts < 0 {
+		return time.Time{}, errInvalidTimestamp
+	}
+	if v.Format == UnixMillis || (v.Format == UnixAuto && ts >= unixMillisThreshold) {
+		return time.Unix(0, 0).Add(time.Duration(ts) * time.Millisecond), nil
+	}
+	return time.Unix(ts, 0), nil
+}
 
-	if t.Before(start) || t.After(stop) {
-		return ErrDateNotInRange
+// skew returns the accepted skew d, TimeGap when d is zero and was not set
+// with WithClockSkew
+func (v *DateValidator) skew(d time.Duration) time.Duration {
+	if d == 0 && !v.skewSet {
+		return v.TimeGap
 	}
+	return d
+}
 
-	return nil
+func (v *DateValidator) now() time.Time {
+	if v.Clock == nil {
+		return time.Now()
+	}
+	return v.Clock()
 }
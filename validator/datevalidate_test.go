@@ -0,0 +1,185 @@
+package validator
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var serverTime = time.Date(2018, time.October, 22, 7, 0, 7, 0, time.UTC)
+
+func frozenClock() time.Time {
+	return serverTime
+}
+
+func TestDateValidator(t *testing.T) {
+	var tests = []struct {
+		name    string
+		date    time.Time
+		timeGap time.Duration
+		err     error
+	}{
+		{name: "same time", date: serverTime},
+		{name: "in range before", date: serverTime.Add(-29 * time.Second)},
+		{name: "in range after", date: serverTime.Add(29 * time.Second)},
+		{name: "too old", date: serverTime.Add(-31 * time.Second), err: ErrDateNotInRange},
+		{name: "too new", date: serverTime.Add(31 * time.Second), err: ErrDateNotInRange},
+		{name: "custom gap in range", date: serverTime.Add(-2 * time.Minute), timeGap: 5 * time.Minute},
+		{name: "custom gap too old", date: serverTime.Add(-6 * time.Minute), timeGap: 5 * time.Minute, err: ErrDateNotInRange},
+	}
+
+	for _, tc := range tests {
+		options := []DateOption{WithClock(frozenClock)}
+		if tc.timeGap != 0 {
+			options = append(options, WithTimeGap(tc.timeGap))
+		}
+		v := NewDateValidator(options...)
+
+		r, err := http.NewRequest("GET", "/", nil)
+		require.NoError(t, err, tc.name)
+		r.Header.Set("Date", tc.date.Format(http.TimeFormat))
+		assert.Equal(t, tc.err, v.Validate(r), tc.name)
+	}
+}
+
+// maxBackward sets MaxBackward alone, MaxForward falls back to TimeGap
+func maxBackward(d time.Duration) DateOption {
+	return func(v *DateValidator) {
+		v.MaxBackward = d
+	}
+}
+
+func TestDateValidatorClockSkew(t *testing.T) {
+	skew := WithClockSkew(60*time.Second, 5*time.Second)
+	var tests = []struct {
+		name    string
+		date    time.Time
+		options []DateOption
+		err     error
+	}{
+		{name: "max backward", date: serverTime.Add(-60 * time.Second), options: []DateOption{skew}},
+		{name: "past max backward", date: serverTime.Add(-61 * time.Second), options: []DateOption{skew}, err: ErrDateNotInRange},
+		{name: "max forward", date: serverTime.Add(5 * time.Second), options: []DateOption{skew}},
+		{name: "past max forward", date: serverTime.Add(6 * time.Second), options: []DateOption{skew}, err: ErrDateNotInRange},
+		{name: "no future skew", date: serverTime, options: []DateOption{WithClockSkew(60*time.Second, 0)}},
+		{name: "past no future skew", date: serverTime.Add(time.Second), options: []DateOption{WithClockSkew(60*time.Second, 0)}, err: ErrDateNotInRange},
+		{name: "no past skew", date: serverTime, options: []DateOption{WithClockSkew(0, 5*time.Second)}},
+		{name: "past no past skew", date: serverTime.Add(-time.Second), options: []DateOption{WithClockSkew(0, 5*time.Second)}, err: ErrDateNotInRange},
+		{name: "time gap forward", date: serverTime.Add(30 * time.Second), options: []DateOption{maxBackward(60 * time.Second)}},
+		{name: "past time gap forward", date: serverTime.Add(31 * time.Second), options: []DateOption{maxBackward(60 * time.Second)}, err: ErrDateNotInRange},
+	}
+
+	for _, tc := range tests {
+		v := NewDateValidator(append([]DateOption{WithClock(frozenClock)}, tc.options...)...)
+
+		r, err := http.NewRequest("GET", "/", nil)
+		require.NoError(t, err, tc.name)
+		r.Header.Set("Date", tc.date.Format(http.TimeFormat))
+		assert.Equal(t, tc.err, v.Validate(r), tc.name)
+	}
+}
+
+func TestDateValidatorInvalidDate(t *testing.T) {
+	r, err := http.NewRequest("GET", "/", nil)
+	require.NoError(t, err)
+	r.Header.Set("Date", "yesterday")
+	assert.Error(t, NewDateValidator(WithClock(frozenClock)).Validate(r))
+}
+
+func TestDateValidatorOffsetDates(t *testing.T) {
+	plus2 := time.FixedZone("", 2*60*60)
+	var tests = []struct {
+		name string
+		date string
+		err  error
+	}{
+		{name: "numeric offset", date: serverTime.In(plus2).Format(time.RFC1123Z)},
+		{name: "numeric offset in range", date: serverTime.Add(-29 * time.Second).In(plus2).Format(time.RFC1123Z)},
+		{name: "numeric offset too old", date: serverTime.Add(-31 * time.Second).In(plus2).Format(time.RFC1123Z), err: ErrDateNotInRange},
+		{name: "rfc3339", date: serverTime.In(plus2).Format(time.RFC3339)},
+		{name: "rfc3339 utc", date: serverTime.Format(time.RFC3339)},
+		{name: "rfc3339 too new", date: serverTime.Add(31 * time.Second).In(plus2).Format(time.RFC3339), err: ErrDateNotInRange},
+	}
+
+	v := NewDateValidator(WithClock(frozenClock))
+	for _, tc := range tests {
+		r, err := http.NewRequest("GET", "/", nil)
+		require.NoError(t, err, tc.name)
+		r.Header.Set("Date", tc.date)
+		assert.Equal(t, tc.err, v.Validate(r), tc.name)
+	}
+
+	date, err := parseHTTPDate("Mon, 22 Oct 2018 09:00:07 +0200")
+	require.NoError(t, err)
+	assert.Equal(t, time.UTC, date.Location())
+	assert.Equal(t, serverTime, date)
+}
+
+func TestUnixDateValidator(t *testing.T) {
+	var tests = []struct {
+		name    string
+		date    string
+		options []DateOption
+		err     error
+	}{
+		{name: "seconds in range", date: strconv.FormatInt(serverTime.Add(-29*time.Second).Unix(), 10)},
+		{name: "milliseconds in range", date: strconv.FormatInt(serverTime.Add(29*time.Second).UnixNano()/1e6, 10)},
+		{name: "seconds too old", date: strconv.FormatInt(serverTime.Add(-31*time.Second).Unix(), 10), err: ErrDateNotInRange},
+		{name: "milliseconds too new", date: strconv.FormatInt(serverTime.Add(31*time.Second).UnixNano()/1e6, 10), err: ErrDateNotInRange},
+		{name: "custom gap", date: strconv.FormatInt(serverTime.Add(-2*time.Minute).Unix(), 10), options: []DateOption{WithTimeGap(5 * time.Minute)}},
+		{
+			name:    "forced seconds",
+			date:    strconv.FormatInt(serverTime.UnixNano()/1e6, 10),
+			options: []DateOption{WithDateFormat(UnixSeconds)},
+			err:     ErrDateNotInRange,
+		},
+		{
+			name:    "forced milliseconds",
+			date:    strconv.FormatInt(serverTime.UnixNano()/1e6, 10),
+			options: []DateOption{WithDateFormat(UnixMillis)},
+		},
+	}
+
+	for _, tc := range tests {
+		v := NewUnixDateValidator("X-Timestamp", append([]DateOption{WithClock(frozenClock)}, tc.options...)...)
+
+		r, err := http.NewRequest("GET", "/", nil)
+		require.NoError(t, err, tc.name)
+		r.Header.Set("X-Timestamp", tc.date)
+		assert.Equal(t, tc.err, v.Validate(r), tc.name)
+	}
+}
+
+func TestUnixDateValidatorInvalidDate(t *testing.T) {
+	v := NewUnixDateValidator("X-Timestamp", WithClock(frozenClock))
+	for _, date := range []string{"", "yesterday", "-1", serverTime.Format(http.TimeFormat)} {
+		r, err := http.NewRequest("GET", "/", nil)
+		require.NoError(t, err)
+		r.Header.Set("X-Timestamp", date)
+		var verr *ValidationError
+		require.True(t, errors.As(v.Validate(r), &verr), date)
+		assert.Equal(t, CodeInvalidDate, verr.Code, date)
+	}
+}
+
+func TestValidationErrorCompatibility(t *testing.T) {
+	for code, err := range map[int]*gin.Error{
+		CodeDateNotInRange:            ErrDateNotInRange,
+		CodeInvalidDigest:             ErrInvalidDigest,
+		CodeDigestAlgorithmNotAllowed: ErrDigestAlgorithmNotAllowed,
+		CodeMissingNonce:              ErrMissingNonce,
+		CodeNonceReused:               ErrNonceReused,
+	} {
+		assert.True(t, err.IsType(gin.ErrorTypePublic), err.Error())
+		var verr *ValidationError
+		require.True(t, errors.As(err, &verr), err.Error())
+		assert.Equal(t, code, verr.Code)
+		assert.Equal(t, verr.Error(), err.Error())
+	}
+}
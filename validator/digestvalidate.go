@@ -3,34 +3,223 @@ package validator
 import (
 	"bytes"
 	"crypto/sha256"
+	"crypto/sha512"
 	"encoding/base64"
-	"errors"
-	"fmt"
+	"hash"
+	"io"
 	"io/ioutil"
 	"net/http"
+	"strings"
+)
+
+var (
+	//ErrInvalidDigest error when digest of body do not match with submitted digest
+	ErrInvalidDigest = newPublicError(CodeInvalidDigest, "Digest of body is not match with digest header")
+	//ErrDigestAlgorithmNotAllowed error when digest algorithm is unknown or not accepted
+	ErrDigestAlgorithmNotAllowed = newPublicError(CodeDigestAlgorithmNotAllowed, "Digest algorithm is not allowed")
+	//ErrBodyTooLarge error when the body is larger than the MaxBodySize of the validator
+	ErrBodyTooLarge = newPublicError(CodeBodyTooLarge, "Body is too large")
+	//ErrBodyLengthMismatch error when the length of the body does not match the Content-Length header
+	ErrBodyLengthMismatch = newPublicError(CodeBodyLengthMismatch, "Body length does not match Content-Length")
+	//ErrInvalidBody error when the body could not be canonicalized by the BodyCanonicalizer of the validator
+	ErrInvalidBody = newPublicError(CodeInvalidBody, "Body could not be canonicalized")
+)
+
+const (
+	defaultDigestHeader = "digest"
+	contentDigestHeader = "content-digest"
+)
+
+// DigestFormat is the format of the digest header value
+type DigestFormat int
 
-	"github.com/gin-gonic/gin"
+const (
+	// DigestHeaderFormat is the ALGORITHM=base64 format of the Digest header
+	// of RFC 3230. This is the default.
+	DigestHeaderFormat DigestFormat = iota
+	// ContentDigestFormat is the algorithm=:base64: structured field format
+	// of the Content-Digest header of RFC 9530.
+	ContentDigestFormat
+	// AnyDigestFormat accepts both formats, e.g. while migrating the clients
+	// to RFC 9530.
+	AnyDigestFormat
 )
 
-//ErrInvalidDigest error when sha256 of body do not match with submitted digest
-var ErrInvalidDigest = &gin.Error{
-	Err:  errors.New("Sha256 of body is not match with digest"),
-	Type: gin.ErrorTypePublic,
+// BodyCanonicalizer returns the canonical form of a body, which the digest is
+// computed over, e.g. CanonicalizeJSON.
+type BodyCanonicalizer func(body []byte) ([]byte, error)
+
+var digestAlgorithms = map[string]func() hash.Hash{
+	"SHA-256": sha256.New,
+	"SHA-512": sha512.New,
 }
 
 // DigestValidator checking digest in header match body
+//
+// The digest covers the body as transferred: with a Content-Encoding, e.g.
+// gzip, the compressed bytes are hashed and never decoded, and the handlers
+// read the body still compressed.
 type DigestValidator struct {
+	// Algorithms is the list of digest algorithms accepted by the validator,
+	// e.g. SHA-256 or SHA-512. The labels are case-insensitive and the hyphen
+	// is optional, e.g. sha256 is SHA-256.
+	Algorithms []string
+	// Streaming checks the digest while the handler reads the body instead of
+	// buffering it in Validate. See WithStreamingDigest.
+	Streaming bool
+	// MaxBodySize is the max size in bytes of the bodies, 0 for no limit.
+	MaxBodySize int64
+	// CheckContentLength requires the number of bytes hashed to match the
+	// Content-Length. See WithContentLengthCheck.
+	CheckContentLength bool
+	// HeaderName is the name of the digest header, Digest when empty.
+	HeaderName string
+	// Format is the format of the digest header, DigestHeaderFormat by
+	// default.
+	Format DigestFormat
+	// Canonicalize, when set, canonicalizes the body before hashing it. See
+	// WithBodyCanonicalizer.
+	Canonicalize BodyCanonicalizer
+	// CoveredHeader validates the digest header covered by the signature,
+	// Digest or Content-Digest, instead of HeaderName. See
+	// WithCoveredDigestHeader.
+	CoveredHeader bool
+}
+
+// DigestOption is the option to the DigestValidator constructor.
+type DigestOption func(*DigestValidator)
+
+// WithStreamingDigest configures the DigestValidator to never buffer the body.
+// Validate only checks the digest header, and the body is replaced by a reader
+// hashing it on the fly: once the handler read the whole body, the read
+// returns ErrInvalidDigest instead of io.EOF when the digest does not match.
+// The request is then already authenticated, so handlers must not act on the
+// body before reading it to the end without error.
+func WithStreamingDigest() DigestOption {
+	return func(v *DigestValidator) {
+		v.Streaming = true
+	}
+}
+
+// WithMaxBodySize configures the DigestValidator to reject the bodies larger
+// than n bytes with ErrBodyTooLarge. Requests with a larger Content-Length
+// are rejected by Validate without reading the body. The middlewares of the
+// Authenticator also wrap the body with http.MaxBytesReader, so that chunked
+// bodies are not read past n bytes either, and answer with 413 Request Entity
+// Too Large.
+func WithMaxBodySize(n int64) DigestOption {
+	return func(v *DigestValidator) {
+		v.MaxBodySize = n
+	}
+}
+
+// WithContentLengthCheck configures the DigestValidator to reject with
+// ErrBodyLengthMismatch the bodies whose length differs from the
+// Content-Length of the request, like truncated uploads. Requests of unknown
+// length are not checked: the chunked requests, whose body is hashed until
+// EOF, and the ones without Content-Length.
+func WithContentLengthCheck() DigestOption {
+	return func(v *DigestValidator) {
+		v.CheckContentLength = true
+	}
+}
+
+// WithDigestFormat configures the format of the digest header read by the
+// DigestValidator.
+func WithDigestFormat(format DigestFormat) DigestOption {
+	return func(v *DigestValidator) {
+		v.Format = format
+	}
+}
+
+// WithBodyCanonicalizer configures the DigestValidator to compute the digest
+// over the canonical form of the body returned by fn, e.g. CanonicalizeJSON,
+// so that semantically equal bodies serialized differently have the same
+// digest. Bodies which fn fails to canonicalize are rejected with
+// ErrInvalidBody, empty bodies are hashed as is. The handlers still read the
+// body as sent.
+//
+// The clients must compute the digest over the same canonical form, with the
+// same canonicalizer, or their requests are rejected. Canonicalizing needs the
+// whole body, so the body is buffered even with WithStreamingDigest. The body
+// is canonicalized as transferred, so compressed bodies are rejected.
+func WithBodyCanonicalizer(fn BodyCanonicalizer) DigestOption {
+	return func(v *DigestValidator) {
+		v.Canonicalize = fn
+	}
+}
+
+// WithCoveredDigestHeader configures the DigestValidator to validate the
+// digest header covered by the signature, Digest in the DigestHeaderFormat or
+// Content-Digest in any format, e.g. while the clients migrate from one to
+// the other. The first one covered is validated, HeaderName when
+// the signature covers none. The authenticator must require the header the
+// clients cover.
+func WithCoveredDigestHeader() DigestOption {
+	return func(v *DigestValidator) {
+		v.CoveredHeader = true
+	}
+}
+
+// NewDigestValidator return pointer of new DigestValidator accepting SHA-256
+// and SHA-512 digests
+func NewDigestValidator(options ...DigestOption) *DigestValidator {
+	v := NewDigestValidatorWithAlgorithms("SHA-256", "SHA-512")
+	for _, fn := range options {
+		fn(v)
+	}
+	return v
+}
+
+// NewDigestValidatorWithAlgorithms return pointer of new DigestValidator
+// accepting only given digest algorithms
+func NewDigestValidatorWithAlgorithms(algorithms ...string) *DigestValidator {
+	return &DigestValidator{Algorithms: algorithms, HeaderName: defaultDigestHeader}
+}
+
+// NewDigestValidatorWithHeader return pointer of new DigestValidator reading
+// the digest from the headerName header, e.g. Content-Digest, instead of
+// Digest. The value has the same ALGORITHM=base64 format.
+func NewDigestValidatorWithHeader(headerName string, options ...DigestOption) *DigestValidator {
+	v := NewDigestValidator(options...)
+	v.HeaderName = headerName
+	return v
 }
 
-// NewDigestValidator return pointer of new DigestValidator
-func NewDigestValidator() *DigestValidator {
-	return &DigestValidator{}
+// NewContentDigestValidator return pointer of new DigestValidator reading the
+// Content-Digest header of RFC 9530, e.g. sha-256=:base64:. When the header
+// has several digests the first one of an accepted algorithm is checked.
+func NewContentDigestValidator(options ...DigestOption) *DigestValidator {
+	return NewDigestValidatorWithHeader("Content-Digest", append([]DigestOption{WithDigestFormat(ContentDigestFormat)}, options...)...)
 }
 
 // Validate return error when checking digest match body
 func (v *DigestValidator) Validate(r *http.Request) error {
-	headerDigest := r.Header.Get("digest")
-	digest, err := calculateDigest(r)
+	algorithm, headerDigest := v.parse(r.Header.Get(v.Header()))
+	newHash, err := v.hashFor(algorithm)
+	if err != nil {
+		return err
+	}
+	if v.MaxBodySize > 0 && r.ContentLength > v.MaxBodySize {
+		return ErrBodyTooLarge
+	}
+	if v.Streaming && v.Canonicalize == nil {
+		if r.Body != nil && r.Body != http.NoBody {
+			r.Body = &digestReader{
+				body:     r.Body,
+				hash:     newHash(),
+				expected: headerDigest,
+				max:      v.MaxBodySize,
+				length:   v.expectedLength(r),
+			}
+			return nil
+		}
+		if base64.StdEncoding.EncodeToString(newHash().Sum(nil)) != headerDigest {
+			return ErrInvalidDigest
+		}
+		return nil
+	}
+	digest, err := calculateDigest(r, newHash, v.MaxBodySize, v.expectedLength(r), v.Canonicalize)
 	if err != nil {
 		return err
 	}
@@ -40,26 +229,219 @@ func (v *DigestValidator) Validate(r *http.Request) error {
 	return nil
 }
 
-func calculateDigest(r *http.Request) (string, error) {
-	h := sha256.New()
+// ValidateSignature is Validate, validating the digest header covered by sig
+// when CoveredHeader is set.
+func (v *DigestValidator) ValidateSignature(r *http.Request, sig *Signature) error {
+	if v.CoveredHeader {
+		if covered := v.forCoveredHeader(sig.Headers); covered != nil {
+			return covered.Validate(r)
+		}
+	}
+	return v.Validate(r)
+}
 
-	if r.ContentLength == 0 {
-		_, _ = h.Write([]byte(""))
-		return fmt.Sprintf("SHA-256=%s", base64.StdEncoding.EncodeToString(h.Sum(nil))), nil
+// forCoveredHeader returns a copy of v validating the first digest header of
+// headers, nil when there is none.
+func (v *DigestValidator) forCoveredHeader(headers []string) *DigestValidator {
+	for _, h := range headers {
+		format := v.Format
+		switch strings.ToLower(h) {
+		case defaultDigestHeader:
+			if format != AnyDigestFormat {
+				format = DigestHeaderFormat
+			}
+		case contentDigestHeader:
+			format = AnyDigestFormat
+		default:
+			continue
+		}
+		covered := *v
+		covered.HeaderName, covered.Format = h, format
+		return &covered
 	}
+	return nil
+}
 
-	// TODO: Read body using buffer to prevent using too much memory
-	body, err := ioutil.ReadAll(r.Body)
+// Header returns the name of the digest header
+func (v *DigestValidator) Header() string {
+	if v.HeaderName == "" {
+		return defaultDigestHeader
+	}
+	return v.HeaderName
+}
+
+// expectedLength returns the length the body must have, -1 when it is not
+// checked: without WithContentLengthCheck, or for chunked requests, whose
+// Content-Length is unknown
+func (v *DigestValidator) expectedLength(r *http.Request) int64 {
+	if !v.CheckContentLength || isChunked(r) {
+		return -1
+	}
+	return r.ContentLength
+}
+
+// isChunked reports whether r has a chunked Transfer-Encoding
+func isChunked(r *http.Request) bool {
+	for _, te := range r.TransferEncoding {
+		if strings.EqualFold(te, "chunked") {
+			return true
+		}
+	}
+	return false
+}
+
+// hashFor returns the hash of algorithm, when allowed. The algorithm labels
+// are compared normalized, see normalizeDigestAlgorithm.
+func (v *DigestValidator) hashFor(algorithm string) (func() hash.Hash, error) {
+	algorithm = normalizeDigestAlgorithm(algorithm)
+	for _, allowed := range v.Algorithms {
+		if normalizeDigestAlgorithm(allowed) == algorithm {
+			if newHash, ok := digestAlgorithms[algorithm]; ok {
+				return newHash, nil
+			}
+		}
+	}
+	return nil, ErrDigestAlgorithmNotAllowed
+}
+
+// normalizeDigestAlgorithm returns the canonical label of a digest algorithm
+// spelled by the clients in any case and with or without hyphen, e.g. SHA-256
+// for sha-256 or SHA256.
+func normalizeDigestAlgorithm(algorithm string) string {
+	algorithm = strings.ToUpper(strings.TrimSpace(algorithm))
+	if digits := strings.TrimPrefix(algorithm, "SHA"); digits != algorithm && digits != "" && digits[0] >= '0' && digits[0] <= '9' {
+		return "SHA-" + digits
+	}
+	return algorithm
+}
+
+// parse returns the algorithm and the encoded digest of the digest header
+func (v *DigestValidator) parse(headerDigest string) (string, string) {
+	if v.Format == ContentDigestFormat || (v.Format == AnyDigestFormat && isContentDigest(headerDigest)) {
+		return v.parseContentDigest(headerDigest)
+	}
+	return parseDigest(headerDigest)
+}
+
+// isContentDigest reports whether the value of the digest header has the
+// structured field format, whose values are colon-delimited byte sequences.
+func isContentDigest(headerDigest string) bool {
+	_, digest := parseDigest(headerDigest)
+	return strings.HasPrefix(strings.TrimSpace(digest), ":")
+}
+
+// parseContentDigest returns the first digest of an accepted algorithm of a
+// Content-Digest dictionary like sha-256=:base64:, sha-512=:base64:. The
+// parameters of the members are ignored.
+func (v *DigestValidator) parseContentDigest(headerDigest string) (string, string) {
+	for _, member := range strings.Split(headerDigest, ",") {
+		algorithm, value := parseDigest(strings.TrimSpace(member))
+		if _, err := v.hashFor(algorithm); err != nil {
+			continue
+		}
+		if i := strings.Index(value, ";"); i >= 0 {
+			value = value[:i]
+		}
+		if len(value) < 2 || value[0] != ':' || value[len(value)-1] != ':' {
+			// Not a byte sequence, it matches no digest
+			return algorithm, ""
+		}
+		return algorithm, value[1 : len(value)-1]
+	}
+	return "", ""
+}
+
+// parseDigest splits a digest header value like SHA-256=base64 into the
+// algorithm and the encoded digest.
+func parseDigest(headerDigest string) (string, string) {
+	i := strings.Index(headerDigest, "=")
+	if i < 0 {
+		return "", headerDigest
+	}
+	return headerDigest[:i], headerDigest[i+1:]
+}
+
+// calculateDigest reads the whole body, at most max bytes when max is not 0,
+// and returns its digest, computed over the body canonicalized by canonicalize
+// when not nil. The body must be length bytes long unless length is negative.
+func calculateDigest(r *http.Request, newHash func() hash.Hash, max int64, length int64, canonicalize BodyCanonicalizer) (string, error) {
+	h := newHash()
+
+	// chunked bodies are read until EOF whatever the Content-Length
+	if r.ContentLength == 0 && !isChunked(r) {
+		return base64.StdEncoding.EncodeToString(h.Sum(nil)), nil
+	}
+
+	var reader io.Reader = r.Body
+	if max > 0 {
+		reader = io.LimitReader(r.Body, max+1)
+	}
+	body, err := ioutil.ReadAll(reader)
+	if isMaxBytesError(err) {
+		return "", ErrBodyTooLarge
+	}
 	if err != nil {
 		return "", err
 	}
-	r.Body = ioutil.NopCloser(bytes.NewBuffer(body))
+	if max > 0 && int64(len(body)) > max {
+		return "", ErrBodyTooLarge
+	}
+	if length >= 0 && int64(len(body)) != length {
+		return "", ErrBodyLengthMismatch
+	}
+	// Restore the body so that it can be read again by the handlers.
+	r.Body = io.NopCloser(bytes.NewReader(body))
 
+	if canonicalize != nil && len(body) > 0 {
+		body, err = canonicalize(body)
+		if err != nil {
+			return "", ErrInvalidBody
+		}
+	}
 	_, err = h.Write(body)
 	if err != nil {
 		return "", err
 	}
 
-	digest := fmt.Sprintf("SHA-256=%s", base64.StdEncoding.EncodeToString(h.Sum(nil)))
-	return digest, nil
+	return base64.StdEncoding.EncodeToString(h.Sum(nil)), nil
+}
+
+// digestReader checks the digest of the body while it is read
+type digestReader struct {
+	body     io.ReadCloser
+	hash     hash.Hash
+	expected string
+	max      int64
+	length   int64
+	n        int64
+	err      error
+}
+
+func (d *digestReader) Read(p []byte) (int, error) {
+	if d.err != nil {
+		return 0, d.err
+	}
+	n, err := d.body.Read(p)
+	d.hash.Write(p[:n])
+	d.n += int64(n)
+	if d.max > 0 && d.n > d.max || isMaxBytesError(err) {
+		d.err = ErrBodyTooLarge
+		return n, d.err
+	}
+	if err == io.EOF {
+		switch {
+		case d.length >= 0 && d.n != d.length:
+			err = ErrBodyLengthMismatch
+		case base64.StdEncoding.EncodeToString(d.hash.Sum(nil)) != d.expected:
+			err = ErrInvalidDigest
+		}
+	}
+	if err != nil {
+		d.err = err
+	}
+	return n, err
+}
+
+func (d *digestReader) Close() error {
+	return d.body.Close()
 }
@@ -0,0 +1,336 @@
+package validator
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/base64"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	sampleBody       = "hello world"
+	sampleSha256     = "SHA-256=uU0nuZNNPgilLlLX2n2r+sSE7+N6U4DukIj3rOLvzek="
+	sampleSha512     = "SHA-512=MJ7MSJwS1utMxA9QyQLytNDtd+5RGnx6m808qG1M2G+YndNbxf9JlnDaNCVbRbDP2DDoH2Bdz33FVC6TrpzXbw=="
+	emptyBodySha256  = "SHA-256=47DEQpj8HBSa+/TImW+5JCeuQeRkm5NMpJWZG3hSuFU="
+	sampleFakeDigest = "SHA-256=fakeDigest="
+)
+
+func TestDigestValidator(t *testing.T) {
+	var tests = []struct {
+		name      string
+		validator *DigestValidator
+		body      string
+		digest    string
+		err       error
+	}{
+		{name: "sha-256", validator: NewDigestValidator(), body: sampleBody, digest: sampleSha256},
+		{name: "sha-512", validator: NewDigestValidator(), body: sampleBody, digest: sampleSha512},
+		{name: "empty body", validator: NewDigestValidator(), digest: emptyBodySha256},
+		{name: "mismatch", validator: NewDigestValidator(), body: sampleBody, digest: sampleFakeDigest, err: ErrInvalidDigest},
+		{name: "unknown algorithm", validator: NewDigestValidator(), body: sampleBody, digest: "MD5=XrY7u+Ae7tCTyyK7j1rNww==", err: ErrDigestAlgorithmNotAllowed},
+		{name: "missing algorithm", validator: NewDigestValidator(), body: sampleBody, digest: "", err: ErrDigestAlgorithmNotAllowed},
+		{name: "disallowed algorithm", validator: NewDigestValidatorWithAlgorithms("SHA-512"), body: sampleBody, digest: sampleSha256, err: ErrDigestAlgorithmNotAllowed},
+		{name: "allowed algorithm", validator: NewDigestValidatorWithAlgorithms("SHA-512"), body: sampleBody, digest: sampleSha512},
+	}
+
+	for _, tc := range tests {
+		r, err := http.NewRequest("POST", "/", strings.NewReader(tc.body))
+		require.NoError(t, err, tc.name)
+		r.Header.Set("Digest", tc.digest)
+
+		assert.Equal(t, tc.err, tc.validator.Validate(r), tc.name)
+
+		body, err := ioutil.ReadAll(r.Body)
+		require.NoError(t, err, tc.name)
+		assert.Equal(t, tc.body, string(body), tc.name)
+	}
+}
+
+func TestStreamingDigestValidator(t *testing.T) {
+	v := NewDigestValidator(WithStreamingDigest())
+
+	r, err := http.NewRequest("POST", "/", strings.NewReader(sampleBody))
+	require.NoError(t, err)
+	r.Header.Set("Digest", sampleSha256)
+	require.NoError(t, v.Validate(r))
+	body, err := ioutil.ReadAll(r.Body)
+	require.NoError(t, err)
+	assert.Equal(t, sampleBody, string(body))
+
+	r, err = http.NewRequest("POST", "/", strings.NewReader(sampleBody))
+	require.NoError(t, err)
+	r.Header.Set("Digest", sampleFakeDigest)
+	require.NoError(t, v.Validate(r), "the digest is only known once the body is read")
+	_, err = ioutil.ReadAll(r.Body)
+	assert.Equal(t, ErrInvalidDigest, err)
+
+	r, err = http.NewRequest("GET", "/", nil)
+	require.NoError(t, err)
+	r.Header.Set("Digest", emptyBodySha256)
+	assert.NoError(t, v.Validate(r))
+	r.Header.Set("Digest", sampleSha256)
+	assert.Equal(t, ErrInvalidDigest, v.Validate(r))
+
+	r.Header.Set("Digest", "MD5=XrY7u+Ae7tCTyyK7j1rNww==")
+	assert.Equal(t, ErrDigestAlgorithmNotAllowed, v.Validate(r))
+}
+
+func TestDigestValidatorMaxBodySize(t *testing.T) {
+	for _, v := range []*DigestValidator{
+		NewDigestValidator(WithMaxBodySize(4)),
+		NewDigestValidator(WithMaxBodySize(4), WithStreamingDigest()),
+	} {
+		r, err := http.NewRequest("POST", "/", strings.NewReader(sampleBody))
+		require.NoError(t, err)
+		r.Header.Set("Digest", sampleSha256)
+		assert.Equal(t, ErrBodyTooLarge, v.Validate(r), "Content-Length is checked first")
+
+		// unknown length, e.g. chunked
+		r.ContentLength = -1
+		if err := v.Validate(r); err == nil {
+			_, err = ioutil.ReadAll(r.Body)
+			assert.Equal(t, ErrBodyTooLarge, err)
+		} else {
+			assert.Equal(t, ErrBodyTooLarge, err)
+		}
+
+		r, err = http.NewRequest("POST", "/", strings.NewReader(sampleBody))
+		require.NoError(t, err)
+		r.Header.Set("Digest", sampleSha256)
+		v.MaxBodySize = int64(len(sampleBody))
+		require.NoError(t, v.Validate(r))
+		body, err := ioutil.ReadAll(r.Body)
+		require.NoError(t, err)
+		assert.Equal(t, sampleBody, string(body))
+	}
+}
+
+func TestDigestValidatorContentLengthCheck(t *testing.T) {
+	// The digest of the truncated body "hello"
+	const truncatedSha256 = "SHA-256=LPJNul+wow4m6DsqxbninhsWHlwfp0JecwQzYpOLmCQ="
+
+	for _, v := range []*DigestValidator{
+		NewDigestValidator(WithContentLengthCheck()),
+		NewDigestValidator(WithContentLengthCheck(), WithStreamingDigest()),
+	} {
+		r, err := http.NewRequest("POST", "/", strings.NewReader(sampleBody[:5]))
+		require.NoError(t, err)
+		r.ContentLength = int64(len(sampleBody))
+		r.Header.Set("Digest", truncatedSha256)
+		if err := v.Validate(r); err == nil {
+			_, err = ioutil.ReadAll(r.Body)
+			assert.Equal(t, ErrBodyLengthMismatch, err, "streaming %v", v.Streaming)
+		} else {
+			assert.Equal(t, ErrBodyLengthMismatch, err, "streaming %v", v.Streaming)
+		}
+
+		// unknown length, e.g. chunked
+		r, err = http.NewRequest("POST", "/", strings.NewReader(sampleBody[:5]))
+		require.NoError(t, err)
+		r.ContentLength = -1
+		r.Header.Set("Digest", truncatedSha256)
+		require.NoError(t, v.Validate(r))
+		_, err = ioutil.ReadAll(r.Body)
+		assert.NoError(t, err, "streaming %v", v.Streaming)
+	}
+
+	r, err := http.NewRequest("POST", "/", strings.NewReader(sampleBody[:5]))
+	require.NoError(t, err)
+	r.ContentLength = int64(len(sampleBody))
+	r.Header.Set("Digest", truncatedSha256)
+	assert.NoError(t, NewDigestValidator().Validate(r), "the length is not checked by default")
+}
+
+func TestDigestValidatorChunkedBody(t *testing.T) {
+	for _, v := range []*DigestValidator{
+		NewDigestValidator(),
+		NewDigestValidator(WithContentLengthCheck()),
+		NewDigestValidator(WithContentLengthCheck(), WithStreamingDigest()),
+	} {
+		// the Content-Length of a chunked request is unknown, or 0 for the
+		// ones built by hand
+		for _, length := range []int64{-1, 0, 5} {
+			r, err := http.NewRequest("POST", "/", ioutil.NopCloser(strings.NewReader(sampleBody)))
+			require.NoError(t, err)
+			r.TransferEncoding = []string{"chunked"}
+			r.ContentLength = length
+			r.Header.Set("Digest", sampleSha256)
+			require.NoError(t, v.Validate(r), "length %d", length)
+
+			body, err := ioutil.ReadAll(r.Body)
+			require.NoError(t, err, "length %d", length)
+			assert.Equal(t, sampleBody, string(body), "the body is restored")
+		}
+	}
+}
+
+func TestDigestAlgorithmSpelling(t *testing.T) {
+	digest := strings.TrimPrefix(sampleSha256, "SHA-256=")
+	var tests = []struct {
+		algorithm string
+		err       error
+	}{
+		{algorithm: "SHA-256"},
+		{algorithm: "sha-256"},
+		{algorithm: "SHA256"},
+		{algorithm: "sha256"},
+		{algorithm: "Sha-256"},
+		{algorithm: "SHA-1", err: ErrDigestAlgorithmNotAllowed},
+		{algorithm: "SHA1", err: ErrDigestAlgorithmNotAllowed},
+		{algorithm: "MD5", err: ErrDigestAlgorithmNotAllowed},
+		{algorithm: "SHA", err: ErrDigestAlgorithmNotAllowed},
+		{algorithm: "SHA-", err: ErrDigestAlgorithmNotAllowed},
+		{algorithm: "SHAKE256", err: ErrDigestAlgorithmNotAllowed},
+	}
+	for _, tc := range tests {
+		r, err := http.NewRequest("POST", "/", strings.NewReader(sampleBody))
+		require.NoError(t, err, tc.algorithm)
+		r.Header.Set("Digest", tc.algorithm+"="+digest)
+		assert.Equal(t, tc.err, NewDigestValidator().Validate(r), tc.algorithm)
+
+		r, err = http.NewRequest("POST", "/", strings.NewReader(sampleBody))
+		require.NoError(t, err, tc.algorithm)
+		r.Header.Set("Content-Digest", strings.ToLower(tc.algorithm)+"=:"+digest+":")
+		assert.Equal(t, tc.err, NewContentDigestValidator().Validate(r), tc.algorithm)
+	}
+
+	r, err := http.NewRequest("POST", "/", strings.NewReader(sampleBody))
+	require.NoError(t, err)
+	r.Header.Set("Digest", sampleSha256)
+	assert.NoError(t, NewDigestValidatorWithAlgorithms("sha256").Validate(r), "the accepted algorithms are normalized too")
+}
+
+func TestDigestValidatorWithHeader(t *testing.T) {
+	v := NewDigestValidatorWithHeader("Content-Digest")
+	assert.Equal(t, "Content-Digest", v.Header())
+	assert.Equal(t, "digest", (&DigestValidator{}).Header())
+
+	r, err := http.NewRequest("POST", "/", strings.NewReader(sampleBody))
+	require.NoError(t, err)
+	r.Header.Set("Content-Digest", sampleSha256)
+	assert.NoError(t, v.Validate(r))
+
+	r, err = http.NewRequest("POST", "/", strings.NewReader(sampleBody))
+	require.NoError(t, err)
+	r.Header.Set("Digest", sampleSha256)
+	assert.Equal(t, ErrDigestAlgorithmNotAllowed, v.Validate(r), "the Digest header is ignored")
+}
+
+func TestContentDigestValidator(t *testing.T) {
+	const (
+		sampleContentSha256 = "sha-256=:uU0nuZNNPgilLlLX2n2r+sSE7+N6U4DukIj3rOLvzek=:"
+		sampleContentSha512 = "sha-512=:MJ7MSJwS1utMxA9QyQLytNDtd+5RGnx6m808qG1M2G+YndNbxf9JlnDaNCVbRbDP2DDoH2Bdz33FVC6TrpzXbw==:"
+	)
+	var tests = []struct {
+		name      string
+		validator *DigestValidator
+		digest    string
+		err       error
+	}{
+		{name: "sha-256", validator: NewContentDigestValidator(), digest: sampleContentSha256},
+		{name: "sha-512", validator: NewContentDigestValidator(), digest: sampleContentSha512},
+		{name: "parameters", validator: NewContentDigestValidator(), digest: sampleContentSha256 + ";foo=bar"},
+		{name: "several digests", validator: NewContentDigestValidator(), digest: "md5=:XrY7u+Ae7tCTyyK7j1rNww==:, " + sampleContentSha512},
+		{name: "first accepted digest", validator: NewContentDigestValidator(), digest: sampleContentSha256 + ", sha-512=:fakeDigest=:"},
+		{name: "mismatch", validator: NewContentDigestValidator(), digest: "sha-256=:fakeDigest=:", err: ErrInvalidDigest},
+		{name: "missing colons", validator: NewContentDigestValidator(), digest: "sha-256=uU0nuZNNPgilLlLX2n2r+sSE7+N6U4DukIj3rOLvzek=", err: ErrInvalidDigest},
+		{name: "unknown algorithm", validator: NewContentDigestValidator(), digest: "md5=:XrY7u+Ae7tCTyyK7j1rNww==:", err: ErrDigestAlgorithmNotAllowed},
+		{name: "disallowed algorithm", validator: NewContentDigestValidator(func(v *DigestValidator) { v.Algorithms = []string{"SHA-512"} }), digest: sampleContentSha256, err: ErrDigestAlgorithmNotAllowed},
+		{name: "any format, structured", validator: NewContentDigestValidator(WithDigestFormat(AnyDigestFormat)), digest: sampleContentSha256},
+		{name: "any format, legacy", validator: NewContentDigestValidator(WithDigestFormat(AnyDigestFormat)), digest: sampleSha256},
+		{name: "any format, mismatch", validator: NewContentDigestValidator(WithDigestFormat(AnyDigestFormat)), digest: "sha-256=:fakeDigest=:", err: ErrInvalidDigest},
+	}
+
+	for _, tc := range tests {
+		for _, streaming := range []bool{false, true} {
+			tc.validator.Streaming = streaming
+			r, err := http.NewRequest("POST", "/", strings.NewReader(sampleBody))
+			require.NoError(t, err, tc.name)
+			r.Header.Set("Content-Digest", tc.digest)
+
+			err = tc.validator.Validate(r)
+			if err == nil {
+				_, err = ioutil.ReadAll(r.Body)
+			}
+			assert.Equal(t, tc.err, err, "%s, streaming %v", tc.name, streaming)
+		}
+	}
+}
+
+func TestDigestValidatorCompressedBody(t *testing.T) {
+	var compressed bytes.Buffer
+	zw := gzip.NewWriter(&compressed)
+	_, err := zw.Write([]byte(sampleBody))
+	require.NoError(t, err)
+	require.NoError(t, zw.Close())
+	sum := sha256.Sum256(compressed.Bytes())
+	compressedSha256 := "SHA-256=" + base64.StdEncoding.EncodeToString(sum[:])
+
+	var tests = []struct {
+		name      string
+		validator *DigestValidator
+		digest    string
+		err       error
+	}{
+		{name: "transferred bytes", validator: NewDigestValidator(), digest: compressedSha256},
+		{name: "decoded bytes", validator: NewDigestValidator(), digest: sampleSha256, err: ErrInvalidDigest},
+		{name: "streaming", validator: NewDigestValidator(WithStreamingDigest()), digest: compressedSha256},
+		{name: "canonicalized", validator: NewDigestValidator(WithBodyCanonicalizer(CanonicalizeJSON)), digest: compressedSha256, err: ErrInvalidBody},
+	}
+	for _, tc := range tests {
+		r, err := http.NewRequest("POST", "/", bytes.NewReader(compressed.Bytes()))
+		require.NoError(t, err, tc.name)
+		r.Header.Set("Content-Encoding", "gzip")
+		r.Header.Set("Digest", tc.digest)
+
+		assert.Equal(t, tc.err, tc.validator.Validate(r), tc.name)
+		if tc.err != nil {
+			continue
+		}
+		body, err := ioutil.ReadAll(r.Body)
+		require.NoError(t, err, tc.name)
+		assert.Equal(t, compressed.Bytes(), body, "%s: the body is restored compressed", tc.name)
+		zr, err := gzip.NewReader(bytes.NewReader(body))
+		require.NoError(t, err, tc.name)
+		decoded, err := ioutil.ReadAll(zr)
+		require.NoError(t, err, tc.name)
+		assert.Equal(t, sampleBody, string(decoded), tc.name)
+	}
+}
+
+func TestDigestValidatorCoveredHeader(t *testing.T) {
+	contentDigest := "sha-256=:uU0nuZNNPgilLlLX2n2r+sSE7+N6U4DukIj3rOLvzek=:"
+	var tests = []struct {
+		name      string
+		validator *DigestValidator
+		covered   []string
+		err       error
+	}{
+		{name: "digest covered", validator: NewDigestValidator(WithCoveredDigestHeader()), covered: []string{"date", "Digest"}, err: ErrInvalidDigest},
+		{name: "content-digest covered", validator: NewDigestValidator(WithCoveredDigestHeader()), covered: []string{"date", "content-digest"}},
+		{name: "first covered", validator: NewDigestValidator(WithCoveredDigestHeader()), covered: []string{"content-digest", "digest"}},
+		{name: "none covered", validator: NewDigestValidator(WithCoveredDigestHeader()), covered: []string{"date"}, err: ErrInvalidDigest},
+		{name: "header name", validator: NewDigestValidator(), covered: []string{"content-digest"}, err: ErrInvalidDigest},
+	}
+	for _, tc := range tests {
+		r, err := http.NewRequest("POST", "/", strings.NewReader(sampleBody))
+		require.NoError(t, err, tc.name)
+		r.Header.Set("Digest", sampleFakeDigest)
+		r.Header.Set("Content-Digest", contentDigest)
+
+		assert.Equal(t, tc.err, tc.validator.ValidateSignature(r, &Signature{Headers: tc.covered}), tc.name)
+	}
+
+	r, err := http.NewRequest("POST", "/", strings.NewReader(sampleBody))
+	require.NoError(t, err)
+	r.Header.Set("Content-Digest", sampleSha256)
+	assert.NoError(t, NewDigestValidator(WithCoveredDigestHeader()).ValidateSignature(r, &Signature{Headers: []string{"content-digest"}}),
+		"the Digest format is accepted in Content-Digest")
+}
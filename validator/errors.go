@@ -0,0 +1,75 @@
+package validator
+
+import "github.com/gin-gonic/gin"
+
+// Codes of the ValidationError returned by the validators
+const (
+	// CodeInvalidDate the date header could not be parsed
+	CodeInvalidDate = iota + 1
+	// CodeDateNotInRange the date header is not in the accepted time range
+	CodeDateNotInRange
+	// CodeInvalidDigest the digest header does not match the body
+	CodeInvalidDigest
+	// CodeDigestAlgorithmNotAllowed the digest algorithm is unknown or not accepted
+	CodeDigestAlgorithmNotAllowed
+	// CodeMissingNonce the nonce header is missing
+	CodeMissingNonce
+	// CodeNonceReused the nonce was already used
+	CodeNonceReused
+	// CodeBodyTooLarge the body is larger than accepted
+	CodeBodyTooLarge
+	// CodeHostNotAllowed the host of the request is not allowed
+	CodeHostNotAllowed
+	// CodeBodyLengthMismatch the body length does not match the Content-Length
+	CodeBodyLengthMismatch
+	// CodeMissingCreated the signature has no created parameter
+	CodeMissingCreated
+	// CodeCreatedNotInRange the created parameter is not in the accepted time range
+	CodeCreatedNotInRange
+	// CodeInvalidBody the body could not be canonicalized
+	CodeInvalidBody
+	// CodeCreatedNotCovered the created parameter is not covered by the
+	// signature
+	CodeCreatedNotCovered
+)
+
+// ValidationError describes the failure of a validator of this package. Code
+// identifies the failed check so that callers, like the authenticator, could
+// map it to a HTTP status. Err is the underlying error, if any.
+//
+// The validators return public *gin.Error, as they always did, wrapping a
+// ValidationError: use errors.As to get it.
+type ValidationError struct {
+	Code    int
+	Message string
+	Err     error
+}
+
+func newValidationError(code int, msg string) *ValidationError {
+	return &ValidationError{Code: code, Message: msg}
+}
+
+// newPublicError returns a public *gin.Error wrapping a ValidationError
+func newPublicError(code int, msg string) *gin.Error {
+	return wrapPublic(newValidationError(code, msg))
+}
+
+// wrapPublic wraps err into a public *gin.Error
+func wrapPublic(err *ValidationError) *gin.Error {
+	return &gin.Error{
+		Err:  err,
+		Type: gin.ErrorTypePublic,
+	}
+}
+
+func (e *ValidationError) Error() string {
+	if e.Err != nil {
+		return e.Message + ": " + e.Err.Error()
+	}
+	return e.Message
+}
+
+// Unwrap returns the underlying error
+func (e *ValidationError) Unwrap() error {
+	return e.Err
+}
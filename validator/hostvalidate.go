@@ -0,0 +1,46 @@
+package validator
+
+import (
+	"net"
+	"net/http"
+	"strings"
+)
+
+// ErrHostNotAllowed error when the host of the request is not an allowed host
+var ErrHostNotAllowed = newPublicError(CodeHostNotAllowed, "Host is not allowed")
+
+// HostValidator checking the host of the request is one of the hosts served,
+// so that signatures covering host could not be replayed to other servers
+// sharing the same keys.
+type HostValidator struct {
+	// AllowedHosts is the list of hosts accepted, compared case-insensitively.
+	// Hosts without port match any port.
+	AllowedHosts []string
+}
+
+// NewHostValidator return pointer of new HostValidator accepting hosts
+func NewHostValidator(hosts ...string) *HostValidator {
+	return &HostValidator{AllowedHosts: hosts}
+}
+
+// Validate return error when the host of the request is not allowed
+func (v *HostValidator) Validate(r *http.Request) error {
+	host := r.Host
+	if host == "" {
+		host = r.URL.Host
+	}
+	hostname := host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		hostname = h
+	}
+
+	for _, allowed := range v.AllowedHosts {
+		if strings.EqualFold(allowed, host) {
+			return nil
+		}
+		if _, _, err := net.SplitHostPort(allowed); err != nil && strings.EqualFold(allowed, hostname) {
+			return nil
+		}
+	}
+	return ErrHostNotAllowed
+}
@@ -0,0 +1,38 @@
+package validator
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHostValidator(t *testing.T) {
+	v := NewHostValidator("api.example.com", "localhost:8080")
+
+	var tests = []struct {
+		host string
+		err  error
+	}{
+		{host: "api.example.com"},
+		{host: "API.Example.com"},
+		{host: "api.example.com:443"},
+		{host: "localhost:8080"},
+		{host: "localhost:9090", err: ErrHostNotAllowed},
+		{host: "localhost", err: ErrHostNotAllowed},
+		{host: "internal.example.com", err: ErrHostNotAllowed},
+		{host: "", err: ErrHostNotAllowed},
+	}
+	for _, tc := range tests {
+		r, err := http.NewRequest("GET", "/", nil)
+		require.NoError(t, err)
+		r.Host = tc.host
+		assert.Equal(t, tc.err, v.Validate(r), tc.host)
+	}
+
+	r, err := http.NewRequest("GET", "http://api.example.com/", nil)
+	require.NoError(t, err)
+	r.Host = ""
+	assert.NoError(t, v.Validate(r), "the URL host is used for client requests")
+}
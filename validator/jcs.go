@@ -0,0 +1,178 @@
+package validator
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"unicode/utf16"
+)
+
+// CanonicalizeJSON is a BodyCanonicalizer returning the JSON Canonicalization
+// Scheme (RFC 8785) form of a JSON body: no whitespace, the object members
+// sorted by name and the numbers and strings serialized like ECMAScript does.
+// Objects with duplicate member names and numbers out of the IEEE 754 double
+// range are rejected.
+func CanonicalizeJSON(body []byte) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+
+	var buf bytes.Buffer
+	if err := canonicalizeValue(dec, &buf); err != nil {
+		return nil, err
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, errors.New("jcs: unexpected data after the JSON value")
+	}
+	return buf.Bytes(), nil
+}
+
+// canonicalizeValue writes the canonical form of the next JSON value of dec
+func canonicalizeValue(dec *json.Decoder, buf *bytes.Buffer) error {
+	token, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	switch t := token.(type) {
+	case json.Delim:
+		if t == '{' {
+			return canonicalizeObject(dec, buf)
+		}
+		return canonicalizeArray(dec, buf)
+	case string:
+		writeJCSString(buf, t)
+	case json.Number:
+		return writeJCSNumber(buf, t)
+	case bool:
+		buf.WriteString(strconv.FormatBool(t))
+	case nil:
+		buf.WriteString("null")
+	}
+	return nil
+}
+
+func canonicalizeObject(dec *json.Decoder, buf *bytes.Buffer) error {
+	type member struct {
+		name  string
+		key   []uint16
+		value []byte
+	}
+	var members []member
+	seen := make(map[string]bool)
+	for dec.More() {
+		token, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		name := token.(string)
+		if seen[name] {
+			return fmt.Errorf("jcs: duplicate member %q", name)
+		}
+		seen[name] = true
+
+		var value bytes.Buffer
+		if err := canonicalizeValue(dec, &value); err != nil {
+			return err
+		}
+		members = append(members, member{name: name, key: utf16.Encode([]rune(name)), value: value.Bytes()})
+	}
+	if _, err := dec.Token(); err != nil {
+		return err
+	}
+
+	// The members are sorted by the UTF-16 code units of their names
+	sort.Slice(members, func(i, j int) bool {
+		a, b := members[i].key, members[j].key
+		for k := 0; k < len(a) && k < len(b); k++ {
+			if a[k] != b[k] {
+				return a[k] < b[k]
+			}
+		}
+		return len(a) < len(b)
+	})
+
+	buf.WriteByte('{')
+	for i, m := range members {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		writeJCSString(buf, m.name)
+		buf.WriteByte(':')
+		buf.Write(m.value)
+	}
+	buf.WriteByte('}')
+	return nil
+}
+
+func canonicalizeArray(dec *json.Decoder, buf *bytes.Buffer) error {
+	buf.WriteByte('[')
+	for i := 0; dec.More(); i++ {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		if err := canonicalizeValue(dec, buf); err != nil {
+			return err
+		}
+	}
+	if _, err := dec.Token(); err != nil {
+		return err
+	}
+	buf.WriteByte(']')
+	return nil
+}
+
+// writeJCSNumber writes n like the ECMAScript Number.prototype.toString, which
+// is what encoding/json does for float64 values.
+func writeJCSNumber(buf *bytes.Buffer, n json.Number) error {
+	f, err := strconv.ParseFloat(string(n), 64)
+	if err != nil {
+		return fmt.Errorf("jcs: invalid number %s: %w", n, err)
+	}
+	if f == 0 {
+		// -0 is serialized as 0
+		f = 0
+	}
+	b, err := json.Marshal(f)
+	if err != nil {
+		return err
+	}
+	buf.Write(b)
+	return nil
+}
+
+// writeJCSString writes s escaping only the quotation mark, the reverse
+// solidus and the control characters, the other characters are written as is.
+func writeJCSString(buf *bytes.Buffer, s string) {
+	const hex = "0123456789abcdef"
+	buf.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"':
+			buf.WriteString(`\"`)
+		case '\\':
+			buf.WriteString(`\\`)
+		case '\b':
+			buf.WriteString(`\b`)
+		case '\f':
+			buf.WriteString(`\f`)
+		case '\n':
+			buf.WriteString(`\n`)
+		case '\r':
+			buf.WriteString(`\r`)
+		case '\t':
+			buf.WriteString(`\t`)
+		default:
+			if r < 0x20 {
+				buf.WriteString(`\u00`)
+				buf.WriteByte(hex[r>>4])
+				buf.WriteByte(hex[r&0xf])
+			} else {
+				buf.WriteRune(r)
+			}
+		}
+	}
+	buf.WriteByte('"')
+}
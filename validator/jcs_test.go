@@ -0,0 +1,78 @@
+package validator
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCanonicalizeJSON(t *testing.T) {
+	var tests = []struct {
+		name      string
+		body      string
+		canonical string
+		err       bool
+	}{
+		{
+			// RFC 8785 section 3.2.2
+			name:      "rfc 8785 example",
+			body:      `{"numbers": [333333333.33333329, 1E30, 4.50, 2e-3, 0.000000000000000000000000001], "string": "\u20ac$\u000F\u000aA'\u0042\u0022\u005c\\\"\/", "literals": [null, true, false]}`,
+			canonical: `{"literals":[null,true,false],"numbers":[333333333.3333333,1e+30,4.5,0.002,1e-27],"string":"€$\u000f\nA'B\"\\\\\"/"}`,
+		},
+		{
+			// RFC 8785 section 3.2.3
+			name:      "sorting",
+			body:      `{"\u20ac": 1, "\r": 2, "\ufb33": 3, "1": 4, "\ud83d\ude00": 5, "\u0080": 6, "\u00f6": 7}`,
+			canonical: "{\"\\r\":2,\"1\":4,\"\u0080\":6,\"ö\":7,\"€\":1,\"😀\":5,\"\ufb33\":3}",
+		},
+		{name: "nested", body: " { \"b\" : [ {\"d\":1, \"c\":-0} ], \"a\" : {} }\n", canonical: `{"a":{},"b":[{"c":0,"d":1}]}`},
+		{name: "scalar", body: `"x"`, canonical: `"x"`},
+		{name: "duplicate member", body: `{"a":1,"a":2}`, err: true},
+		{name: "number out of range", body: `1e400`, err: true},
+		{name: "trailing data", body: `{} {}`, err: true},
+		{name: "invalid", body: `{"a":}`, err: true},
+	}
+
+	for _, tc := range tests {
+		canonical, err := CanonicalizeJSON([]byte(tc.body))
+		if tc.err {
+			assert.Error(t, err, tc.name)
+			continue
+		}
+		require.NoError(t, err, tc.name)
+		assert.Equal(t, tc.canonical, string(canonical), tc.name)
+	}
+}
+
+func TestDigestValidatorBodyCanonicalizer(t *testing.T) {
+	sum := sha256.Sum256([]byte(`{"a":1,"b":[true,null]}`))
+	digest := "SHA-256=" + base64.StdEncoding.EncodeToString(sum[:])
+
+	var tests = []struct {
+		name string
+		body string
+		err  error
+	}{
+		{name: "canonical", body: `{"a":1,"b":[true,null]}`},
+		{name: "reordered", body: "{\n  \"b\": [true, null],\n  \"a\": 1.0\n}"},
+		{name: "different", body: `{"a":2,"b":[true,null]}`, err: ErrInvalidDigest},
+		{name: "not json", body: `a=1`, err: ErrInvalidBody},
+	}
+
+	for _, streaming := range []bool{false, true} {
+		v := NewDigestValidator(WithBodyCanonicalizer(CanonicalizeJSON))
+		v.Streaming = streaming
+		for _, tc := range tests {
+			r, err := http.NewRequest("POST", "/", strings.NewReader(tc.body))
+			require.NoError(t, err, tc.name)
+			r.Header.Set("Digest", digest)
+
+			assert.Equal(t, tc.err, v.Validate(r), "%s streaming %v", tc.name, streaming)
+		}
+	}
+}
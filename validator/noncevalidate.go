@@ -0,0 +1,117 @@
+package validator
+
+import (
+	"net/http"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrMissingNonce error when the nonce header is not present in request
+	ErrMissingNonce = newPublicError(CodeMissingNonce, "Nonce header is missing")
+	// ErrNonceReused error when the nonce was already used by another request
+	ErrNonceReused = newPublicError(CodeNonceReused, "Nonce has already been used")
+)
+
+// NonceStore keeps track of the nonces that were already used
+type NonceStore interface {
+	Seen(nonce string) bool
+	Remember(nonce string, ttl time.Duration)
+}
+
+// NonceValidator rejects requests reusing a nonce to prevent replay attacks
+type NonceValidator struct {
+	// TTL is how long a nonce is remembered. It should be at least as long as
+	// the window accepted by the DateValidator.
+	TTL        time.Duration
+	HeaderName string
+	Store      NonceStore
+
+	mu sync.Mutex
+}
+
+// NewNonceValidator return NonceValidator reading the "nonce" header and
+// remembering nonces for the whole window accepted by NewDateValidator.
+func NewNonceValidator(store NonceStore) *NonceValidator {
+	return NewCustomNonceValidator("nonce", 2*maxTimeGap, store)
+}
+
+// NewCustomNonceValidator return NonceValidator with custom header name and ttl
+func NewCustomNonceValidator(headerName string, ttl time.Duration, store NonceStore) *NonceValidator {
+	return &NonceValidator{
+		TTL:        ttl,
+		HeaderName: headerName,
+		Store:      store,
+	}
+}
+
+// Validate return error when the nonce is missing or was already used
+func (v *NonceValidator) Validate(r *http.Request) error {
+	nonce := r.Header.Get(v.HeaderName)
+	if nonce == "" {
+		return ErrMissingNonce
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if v.Store.Seen(nonce) {
+		return ErrNonceReused
+	}
+	v.Store.Remember(nonce, v.TTL)
+	return nil
+}
+
+// MemoryNonceStore is an in-memory NonceStore. Expired nonces are removed
+// automatically.
+type MemoryNonceStore struct {
+	mu        sync.Mutex
+	nonces    map[string]time.Time
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+// NewMemoryNonceStore return pointer of new MemoryNonceStore
+func NewMemoryNonceStore() *MemoryNonceStore {
+	return &MemoryNonceStore{
+		nonces: make(map[string]time.Time),
+		now:    time.Now,
+	}
+}
+
+// Seen return true when nonce was remembered and has not expired yet
+func (s *MemoryNonceStore) Seen(nonce string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	expiry, ok := s.nonces[nonce]
+	if !ok {
+		return false
+	}
+	if !s.now().Before(expiry) {
+		delete(s.nonces, nonce)
+		return false
+	}
+	return true
+}
+
+// Remember stores nonce for ttl
+func (s *MemoryNonceStore) Remember(nonce string, ttl time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	s.nonces[nonce] = now.Add(ttl)
+
+	// Sweep expired nonces at most once per ttl so that the store does not
+	// grow with nonces that are never seen again.
+	if now.Sub(s.lastSweep) < ttl {
+		return
+	}
+	for n, expiry := range s.nonces {
+		if !now.Before(expiry) {
+			delete(s.nonces, n)
+		}
+	}
+	s.lastSweep = now
+}
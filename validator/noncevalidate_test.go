@@ -0,0 +1,42 @@
+package validator
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNonceValidator(t *testing.T) {
+	v := NewCustomNonceValidator("X-Nonce", time.Minute, NewMemoryNonceStore())
+
+	r, err := http.NewRequest("GET", "/", nil)
+	require.NoError(t, err)
+	assert.Equal(t, ErrMissingNonce, v.Validate(r))
+
+	r.Header.Set("X-Nonce", "abc")
+	assert.NoError(t, v.Validate(r))
+	assert.Equal(t, ErrNonceReused, v.Validate(r))
+
+	r.Header.Set("X-Nonce", "def")
+	assert.NoError(t, v.Validate(r))
+}
+
+func TestMemoryNonceStoreExpiry(t *testing.T) {
+	now := time.Date(2018, time.October, 22, 7, 0, 0, 0, time.UTC)
+	s := NewMemoryNonceStore()
+	s.now = func() time.Time { return now }
+
+	s.Remember("a", time.Minute)
+	assert.True(t, s.Seen("a"))
+
+	now = now.Add(time.Minute)
+	assert.False(t, s.Seen("a"))
+
+	s.Remember("b", time.Minute)
+	now = now.Add(2 * time.Minute)
+	s.Remember("c", time.Minute)
+	assert.Len(t, s.nonces, 1)
+}
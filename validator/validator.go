@@ -1,10 +1,61 @@
 package validator
 
 import (
+	"context"
 	"net/http"
+	"time"
 )
 
 // Validator interface for checking if a request is valid or not
 type Validator interface {
 	Validate(*http.Request) error
 }
+
+// ContextValidator is implemented by the validators which could use the
+// context of the verification, e.g. to bound a call to a remote store. The
+// authenticator calls ValidateContext instead of Validate when available.
+type ContextValidator interface {
+	Validator
+	ValidateContext(ctx context.Context, r *http.Request) error
+}
+
+// Signature holds the parameters of the parsed signature of a request
+type Signature struct {
+	KeyID     string
+	Algorithm string
+	// Label is the label of RFC 9421 signatures, empty for the other formats
+	Label string
+	// Headers are the covered fields
+	Headers []string
+	// Created and Expires are zero when the signature does not have them
+	Created time.Time
+	Expires time.Time
+}
+
+// SignatureValidator is implemented by the validators checking the
+// parameters of the signature. The authenticator calls ValidateSignature
+// instead of Validate when available.
+//
+// A Validator needing the signature keeps its Validate method for the
+// callers without signature, and adds ValidateSignature, see
+// CreatedValidator or DigestValidator. New validators could be written as a
+// SignatureValidatorFunc.
+type SignatureValidator interface {
+	Validator
+	ValidateSignature(r *http.Request, sig *Signature) error
+}
+
+// SignatureValidatorFunc is a function used as SignatureValidator. The
+// authenticator calls it with the parsed signature, Validate does nothing.
+type SignatureValidatorFunc func(r *http.Request, sig *Signature) error
+
+// Validate does nothing, the request is checked by ValidateSignature once the
+// signature is parsed.
+func (f SignatureValidatorFunc) Validate(_ *http.Request) error {
+	return nil
+}
+
+// ValidateSignature calls f(r, sig)
+func (f SignatureValidatorFunc) ValidateSignature(r *http.Request, sig *Signature) error {
+	return f(r, sig)
+}